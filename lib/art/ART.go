@@ -0,0 +1,415 @@
+package art
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	NODE_LEAF = iota
+	NODE_4
+	NODE_16
+	NODE_48
+	NODE_256
+)
+
+// Leaf holds a key of the tree together with its value
+type Leaf struct {
+	key       string
+	value     []byte
+	tombstone bool
+	merge     bool // value holds merge operands instead of a full value
+	pointer   bool // value holds a pointer into the value log instead of the value
+	timestamp int64
+	expiry    int64
+}
+
+func (l *Leaf) Key() string {
+	return l.key
+}
+
+func (l *Leaf) Value() []byte {
+	return l.value
+}
+
+func (l *Leaf) Tombstone() bool {
+	return l.tombstone
+}
+
+func (l *Leaf) Merge() bool {
+	return l.merge
+}
+
+func (l *Leaf) Pointer() bool {
+	return l.pointer
+}
+
+func (l *Leaf) Timestamp() int64 {
+	return l.timestamp
+}
+
+func (l *Leaf) Expiry() int64 {
+	return l.expiry
+}
+
+// node is either a leaf or an inner node with 4, 16, 48 or 256 children.
+// An inner node skips the bytes of its prefix, which all keys below it share, and branches on the byte after it.
+type node struct {
+	kind     int
+	leaf     *Leaf  // the leaf of a leaf node, or the key which ends at an inner node
+	prefix   string // compressed path of an inner node
+	keys     []byte // sorted bytes of the children of node 4 and 16, child index + 1 by byte for node 48
+	children []*node
+}
+
+func newInnerNode(kind int, prefix string) *node {
+	n := &node{kind: kind, prefix: prefix}
+	switch kind {
+	case NODE_4:
+		n.keys, n.children = make([]byte, 0, 4), make([]*node, 0, 4)
+	case NODE_16:
+		n.keys, n.children = make([]byte, 0, 16), make([]*node, 0, 16)
+	case NODE_48:
+		n.keys, n.children = make([]byte, 256), make([]*node, 0, 48)
+	case NODE_256:
+		n.children = make([]*node, 256)
+	}
+	return n
+}
+
+// child returns the slot holding the child for the byte, nil if there is none
+func (n *node) child(b byte) **node {
+	switch n.kind {
+	case NODE_4, NODE_16:
+		i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= b })
+		if i < len(n.keys) && n.keys[i] == b {
+			return &n.children[i]
+		}
+	case NODE_48:
+		if n.keys[b] != 0 {
+			return &n.children[n.keys[b]-1]
+		}
+	case NODE_256:
+		if n.children[b] != nil {
+			return &n.children[b]
+		}
+	}
+	return nil
+}
+
+// addChild adds a child for a byte which has none, growing the node if it is full.
+// It returns the node holding the children afterwards.
+func (n *node) addChild(b byte, child *node) *node {
+	switch n.kind {
+	case NODE_4, NODE_16:
+		if len(n.keys) == cap(n.keys) {
+			return n.grow().addChild(b, child)
+		}
+		i := sort.Search(len(n.keys), func(i int) bool { return n.keys[i] >= b })
+		n.keys = append(n.keys, 0)
+		copy(n.keys[i+1:], n.keys[i:])
+		n.keys[i] = b
+		n.children = append(n.children, nil)
+		copy(n.children[i+1:], n.children[i:])
+		n.children[i] = child
+	case NODE_48:
+		if len(n.children) == cap(n.children) {
+			return n.grow().addChild(b, child)
+		}
+		n.children = append(n.children, child)
+		n.keys[b] = byte(len(n.children))
+	case NODE_256:
+		n.children[b] = child
+	}
+	return n
+}
+
+// grow copies the node into a node of the next larger kind
+func (n *node) grow() *node {
+	grown := newInnerNode(n.kind+1, n.prefix)
+	grown.leaf = n.leaf
+	n.each(func(b byte, child *node) bool {
+		grown.addChild(b, child)
+		return true
+	})
+	return grown
+}
+
+// each calls fn for the children of the node ordered by their byte, until fn returns false
+func (n *node) each(fn func(b byte, child *node) bool) bool {
+	switch n.kind {
+	case NODE_4, NODE_16:
+		for i, b := range n.keys {
+			if !fn(b, n.children[i]) {
+				return false
+			}
+		}
+	case NODE_48:
+		for b, index := range n.keys {
+			if index != 0 && !fn(byte(b), n.children[index-1]) {
+				return false
+			}
+		}
+	case NODE_256:
+		for b, child := range n.children {
+			if child != nil && !fn(byte(b), child) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// nextChild returns the first child at or after the position and the position after it, nil if there is none.
+// Positions are indexes of the children for node 4 and 16 and bytes for node 48 and 256.
+func (n *node) nextChild(position int) (*node, int) {
+	switch n.kind {
+	case NODE_4, NODE_16:
+		if position < len(n.children) {
+			return n.children[position], position + 1
+		}
+	case NODE_48:
+		for b := position; b < len(n.keys); b++ {
+			if n.keys[b] != 0 {
+				return n.children[n.keys[b]-1], b + 1
+			}
+		}
+	case NODE_256:
+		for b := position; b < len(n.children); b++ {
+			if n.children[b] != nil {
+				return n.children[b], b + 1
+			}
+		}
+	}
+	return nil, position
+}
+
+// ART is an adaptive radix tree, its keys are ordered bytewise.
+// Keys sharing a prefix share the nodes of the prefix, and inner nodes only grow as large as their number of children needs.
+type ART struct {
+	root *node
+	size int
+}
+
+func NewART() *ART {
+	return &ART{}
+}
+
+// commonPrefix returns the length of the common prefix of the strings
+func commonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// PutVersion inserts or overwrites a key with the given value, tombstone, merge and pointer flags, timestamp and expiry.
+// It returns the leaf it replaced, nil if the key was not present.
+func (t *ART) PutVersion(key string, value []byte, tombstone, merge, pointer bool, timestamp, expiry int64) *Leaf {
+	leaf := &Leaf{
+		key:       key,
+		value:     value,
+		tombstone: tombstone,
+		merge:     merge,
+		pointer:   pointer,
+		timestamp: timestamp,
+		expiry:    expiry,
+	}
+
+	old := t.insert(&t.root, leaf, 0)
+	if old == nil {
+		t.size++
+	}
+	return old
+}
+
+func (t *ART) insert(ref **node, leaf *Leaf, depth int) *Leaf {
+	n := *ref
+	key := leaf.key
+
+	if n == nil {
+		*ref = &node{kind: NODE_LEAF, leaf: leaf}
+		return nil
+	}
+
+	if n.kind == NODE_LEAF {
+		if n.leaf.key == key {
+			old := n.leaf
+			n.leaf = leaf
+			return old
+		}
+
+		// both keys continue below a new inner node holding the part they share
+		common := commonPrefix(key[depth:], n.leaf.key[depth:])
+		inner := newInnerNode(NODE_4, key[depth:depth+common])
+		inner = inner.place(n, depth+common)
+		*ref = inner.place(&node{kind: NODE_LEAF, leaf: leaf}, depth+common)
+		return nil
+	}
+
+	common := commonPrefix(key[depth:], n.prefix)
+	if common < len(n.prefix) {
+		// the key leaves the prefix of the node, split the prefix where it does
+		inner := newInnerNode(NODE_4, n.prefix[:common])
+		inner = inner.addChild(n.prefix[common], n)
+		n.prefix = n.prefix[common+1:]
+		*ref = inner.place(&node{kind: NODE_LEAF, leaf: leaf}, depth+common)
+		return nil
+	}
+
+	depth += len(n.prefix)
+	if depth == len(key) {
+		old := n.leaf
+		n.leaf = leaf
+		return old
+	}
+
+	if child := n.child(key[depth]); child != nil {
+		return t.insert(child, leaf, depth+1)
+	}
+	*ref = n.addChild(key[depth], &node{kind: NODE_LEAF, leaf: leaf})
+	return nil
+}
+
+// place puts a leaf node below the inner node, whose key ends at depth
+func (n *node) place(leaf *node, depth int) *node {
+	if len(leaf.leaf.key) == depth {
+		n.leaf = leaf.leaf
+		return n
+	}
+	return n.addChild(leaf.leaf.key[depth], leaf)
+}
+
+// Get returns the leaf of the key, nil if it is not present
+func (t *ART) Get(key string) *Leaf {
+	n, depth := t.root, 0
+	for n != nil {
+		if n.kind == NODE_LEAF {
+			if n.leaf.key == key {
+				return n.leaf
+			}
+			return nil
+		}
+
+		if !strings.HasPrefix(key[depth:], n.prefix) {
+			return nil
+		}
+		depth += len(n.prefix)
+		if depth == len(key) {
+			return n.leaf
+		}
+
+		child := n.child(key[depth])
+		if child == nil {
+			return nil
+		}
+		n, depth = *child, depth+1
+	}
+	return nil
+}
+
+// Ascend calls fn for every leaf ordered by key, until fn returns false
+func (t *ART) Ascend(fn func(leaf *Leaf) bool) {
+	if t.root != nil {
+		ascend(t.root, fn)
+	}
+}
+
+// AscendPrefix calls fn for every leaf whose key starts with the prefix ordered by key, until fn returns false
+func (t *ART) AscendPrefix(prefix string, fn func(leaf *Leaf) bool) {
+	n, depth := t.root, 0
+	for n != nil {
+		if n.kind == NODE_LEAF {
+			if strings.HasPrefix(n.leaf.key, prefix) {
+				fn(n.leaf)
+			}
+			return
+		}
+
+		// every key below the node starts with the prefix once it is used up within the prefix of the node
+		rest := prefix[depth:]
+		if len(rest) <= len(n.prefix) {
+			if strings.HasPrefix(n.prefix, rest) {
+				ascend(n, fn)
+			}
+			return
+		}
+		if !strings.HasPrefix(rest, n.prefix) {
+			return
+		}
+		depth += len(n.prefix)
+
+		child := n.child(prefix[depth])
+		if child == nil {
+			return
+		}
+		n, depth = *child, depth+1
+	}
+}
+
+// ascend visits the key ending at the node before the keys of its children, which are longer
+func ascend(n *node, fn func(leaf *Leaf) bool) bool {
+	if n.leaf != nil && !fn(n.leaf) {
+		return false
+	}
+	return n.each(func(_ byte, child *node) bool {
+		return ascend(child, fn)
+	})
+}
+
+func (t *ART) Size() int {
+	return t.size
+}
+
+// Iterator walks the leaves of an ART ordered by key
+type Iterator struct {
+	stack []iteratorFrame
+	leaf  *Leaf
+}
+
+// iteratorFrame is a node on the path to the current leaf and the position of its next child
+type iteratorFrame struct {
+	node     *node
+	position int
+	visited  bool // the key ending at the node was visited
+}
+
+// Iterator returns an iterator positioned before the first leaf
+func (t *ART) Iterator() *Iterator {
+	it := &Iterator{}
+	if t.root != nil {
+		it.stack = append(it.stack, iteratorFrame{node: t.root})
+	}
+	return it
+}
+
+// Next moves to the next leaf, it returns false once there are none left
+func (it *Iterator) Next() bool {
+	for len(it.stack) > 0 {
+		top := &it.stack[len(it.stack)-1]
+
+		// the key ending at a node is shorter than the keys of its children, so it comes first
+		if !top.visited {
+			top.visited = true
+			if top.node.leaf != nil {
+				it.leaf = top.node.leaf
+				return true
+			}
+		}
+
+		child, position := top.node.nextChild(top.position)
+		if child == nil {
+			it.stack = it.stack[:len(it.stack)-1]
+			continue
+		}
+		top.position = position
+		it.stack = append(it.stack, iteratorFrame{node: child})
+	}
+	it.leaf = nil
+	return false
+}
+
+func (it *Iterator) Leaf() *Leaf {
+	return it.leaf
+}
@@ -0,0 +1,101 @@
+package art
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestART(t *testing.T) {
+	tree := NewART()
+
+	// long shared prefixes, keys which are prefixes of others and enough children to grow every node kind
+	var keys []string
+	for i := 0; i < 300; i++ {
+		keys = append(keys, fmt.Sprintf("user/%06d/profile", i), fmt.Sprintf("user/%06d", i))
+	}
+	keys = append(keys, "", "u", "user/", "\x00", "\xff\x00")
+	for i := 0; i < 256; i++ {
+		keys = append(keys, "bytes/"+string([]byte{byte(i)}))
+	}
+
+	for _, i := range rand.Perm(len(keys)) {
+		if old := tree.PutVersion(keys[i], []byte(keys[i]), false, false, false, int64(i), 0); old != nil {
+			t.Fatalf("PutVersion(%q) replaced %q", keys[i], old.Key())
+		}
+	}
+	if tree.Size() != len(keys) {
+		t.Errorf("Size() = %d; want %d", tree.Size(), len(keys))
+	}
+
+	for _, key := range keys {
+		if leaf := tree.Get(key); leaf == nil || string(leaf.Value()) != key {
+			t.Fatalf("Get(%q) = %v", key, leaf)
+		}
+	}
+	for _, key := range []string{"us", "user/000001/", "user/000300", "bytes/", "\xff"} {
+		if leaf := tree.Get(key); leaf != nil {
+			t.Errorf("Get(%q) = %q; want nil", key, leaf.Key())
+		}
+	}
+
+	sort.Strings(keys)
+	var ascended []string
+	tree.Ascend(func(leaf *Leaf) bool {
+		ascended = append(ascended, leaf.Key())
+		return true
+	})
+	if strings.Join(ascended, ",") != strings.Join(keys, ",") {
+		t.Errorf("Ascend() is not ordered bytewise")
+	}
+
+	old := tree.PutVersion("user/000042", nil, true, false, false, 1000, 0)
+	if old == nil || old.Key() != "user/000042" {
+		t.Errorf("PutVersion() over an existing key replaced %v", old)
+	}
+	if leaf := tree.Get("user/000042"); leaf == nil || !leaf.Tombstone() || tree.Size() != len(keys) {
+		t.Errorf("Get(user/000042) = %v after overwriting it with a tombstone", leaf)
+	}
+}
+
+func TestARTAscendPrefix(t *testing.T) {
+	tree := NewART()
+	for _, key := range []string{"apple", "app", "application", "apply", "banana", "ap", "b"} {
+		tree.PutVersion(key, nil, false, false, false, 0, 0)
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"app", []string{"app", "apple", "application", "apply"}},
+		{"appl", []string{"apple", "application", "apply"}},
+		{"applic", []string{"application"}},
+		{"b", []string{"b", "banana"}},
+		{"", []string{"ap", "app", "apple", "application", "apply", "b", "banana"}},
+		{"c", nil},
+		{"applz", nil},
+	}
+
+	for _, test := range tests {
+		var got []string
+		tree.AscendPrefix(test.prefix, func(leaf *Leaf) bool {
+			got = append(got, leaf.Key())
+			return true
+		})
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("AscendPrefix(%q) = %v; want %v", test.prefix, got, test.want)
+		}
+	}
+
+	var first []string
+	tree.AscendPrefix("app", func(leaf *Leaf) bool {
+		first = append(first, leaf.Key())
+		return false
+	})
+	if fmt.Sprint(first) != "[app]" {
+		t.Errorf("AscendPrefix() did not stop after fn returned false: %v", first)
+	}
+}
@@ -1,9 +1,18 @@
 package btree
 
+import (
+	"NoSQLDB/lib/comparator"
+	"slices"
+)
+
 type Entry struct {
 	key       string
 	value     []byte
 	tombstone bool
+	merge     bool // value holds merge operands instead of a full value
+	pointer   bool // value holds a pointer into the value log instead of the value
+	timestamp int64
+	expiry    int64
 }
 
 func (e *Entry) Key() string {
@@ -18,6 +27,22 @@ func (e *Entry) Tombstone() bool {
 	return e.tombstone
 }
 
+func (e *Entry) Merge() bool {
+	return e.merge
+}
+
+func (e *Entry) Pointer() bool {
+	return e.pointer
+}
+
+func (e *Entry) Timestamp() int64 {
+	return e.timestamp
+}
+
+func (e *Entry) Expiry() int64 {
+	return e.expiry
+}
+
 type Node struct {
 	keys     []string
 	values   []*Entry
@@ -54,6 +79,7 @@ type BTree struct {
 	root      *Node
 	minDegree int
 	size      int
+	cmp       comparator.Comparator
 }
 
 func (bt *BTree) Root() *Node {
@@ -61,10 +87,16 @@ func (bt *BTree) Root() *Node {
 }
 
 func NewBTree(minDegree int) *BTree {
+	return NewBTreeWithComparator(minDegree, comparator.Bytewise)
+}
+
+// NewBTreeWithComparator creates a BTree which orders its keys with the comparator
+func NewBTreeWithComparator(minDegree int, cmp comparator.Comparator) *BTree {
 	return &BTree{
 		root:      NewNode(minDegree, true),
 		minDegree: minDegree,
 		size:      0,
+		cmp:       cmp,
 	}
 }
 
@@ -74,7 +106,7 @@ func (b *BTree) Get(key string, node *Node) (*Entry, int) {
 	}
 
 	i := 0
-	for i < len(node.keys) && key > node.keys[i] {
+	for i < len(node.keys) && b.cmp.Compare(key, node.keys[i]) > 0 {
 		i++
 	}
 
@@ -90,10 +122,18 @@ func (b *BTree) Get(key string, node *Node) (*Entry, int) {
 }
 
 func (b *BTree) Update(key string, value []byte, tombstone bool) bool {
+	return b.updateVersion(key, value, tombstone, false, false, 0, 0)
+}
+
+func (b *BTree) updateVersion(key string, value []byte, tombstone, merge, pointer bool, timestamp, expiry int64) bool {
 	entry, _ := b.Get(key, nil)
 	if entry != nil {
 		entry.value = value
 		entry.tombstone = tombstone
+		entry.merge = merge
+		entry.pointer = pointer
+		entry.timestamp = timestamp
+		entry.expiry = expiry
 		return true
 	}
 	return false
@@ -104,11 +144,20 @@ func (b *BTree) splitChild(parent *Node, i int) {
 	child := parent.children[i]
 
 	newNode := NewNode(b.minDegree, child.isLeaf)
-	parent.children = append(parent.children, newNode)
 
-	// Move the median key and value of child to parent
-	parent.keys = append(parent.keys, child.keys[b.minDegree-1])
-	parent.values = append(parent.values, child.values[b.minDegree-1])
+	// newNode becomes the right sibling of child
+	parent.children = append(parent.children, nil)
+	copy(parent.children[i+2:], parent.children[i+1:])
+	parent.children[i+1] = newNode
+
+	// Move the median key and value of child to parent, between child and newNode
+	parent.keys = append(parent.keys, "")
+	copy(parent.keys[i+1:], parent.keys[i:])
+	parent.keys[i] = child.keys[b.minDegree-1]
+
+	parent.values = append(parent.values, nil)
+	copy(parent.values[i+1:], parent.values[i:])
+	parent.values[i] = child.values[b.minDegree-1]
 
 	// split child's keys and values
 	newNode.keys = append(newNode.keys, child.keys[b.minDegree:(2*b.minDegree)-1]...)
@@ -120,18 +169,24 @@ func (b *BTree) splitChild(parent *Node, i int) {
 	// if newNode is not a leaf, move child's children to newNode
 	if !child.isLeaf {
 		newNode.children = append(newNode.children, child.children[b.minDegree:2*b.minDegree]...)
-		child.children = child.children[:b.minDegree-1]
+		child.children = child.children[:b.minDegree]
 	}
 }
 
 func (b *BTree) Put(key string, value []byte, tombstone bool) {
-	if b.Update(key, value, tombstone) {
+	b.PutVersion(key, value, tombstone, false, false, 0, 0)
+}
+
+// PutVersion inserts or updates a key, recording whether the value holds merge operands or a value log pointer,
+// the timestamp of the write and when it expires.
+func (b *BTree) PutVersion(key string, value []byte, tombstone, merge, pointer bool, timestamp, expiry int64) {
+	if b.updateVersion(key, value, tombstone, merge, pointer, timestamp, expiry) {
 		return
 	}
 
 	if !(len(b.root.keys) == (2*b.minDegree - 1)) {
 		b.size++
-		b.putNotFull(b.root, key, value, tombstone)
+		b.putNotFull(b.root, key, value, tombstone, merge, pointer, timestamp, expiry)
 		return
 	}
 	newRoot := NewNode(b.minDegree, false)
@@ -141,34 +196,288 @@ func (b *BTree) Put(key string, value []byte, tombstone bool) {
 	b.root = newRoot
 
 	b.size++
-	b.putNotFull(b.root, key, value, tombstone)
+	b.putNotFull(b.root, key, value, tombstone, merge, pointer, timestamp, expiry)
 }
 
-func (b *BTree) putNotFull(node *Node, key string, value []byte, tombstone bool) {
+func (b *BTree) putNotFull(node *Node, key string, value []byte, tombstone, merge, pointer bool, timestamp, expiry int64) {
 	i := len(node.keys) - 1
 
 	if node.isLeaf {
-		for i >= 0 && key < node.keys[i] {
+		for i >= 0 && b.cmp.Compare(key, node.keys[i]) < 0 {
 			i--
 		}
 		i++
 		node.keys = append(node.keys[:i], append([]string{key}, node.keys[i:]...)...)
-		node.values = append(node.values[:i], append([]*Entry{{key, value, tombstone}}, node.values[i:]...)...)
+		node.values = append(node.values[:i], append([]*Entry{{key, value, tombstone, merge, pointer, timestamp, expiry}}, node.values[i:]...)...)
 	} else {
-		for i >= 0 && key < node.keys[i] {
+		for i >= 0 && b.cmp.Compare(key, node.keys[i]) < 0 {
 			i--
 		}
 		i++
 		if len(node.children[i].keys) == (2*b.minDegree - 1) {
 			b.splitChild(node, i)
-			if key > node.keys[i] {
+			if b.cmp.Compare(key, node.keys[i]) > 0 {
 				i++
 			}
 		}
-		b.putNotFull(node.children[i], key, value, tombstone)
+		b.putNotFull(node.children[i], key, value, tombstone, merge, pointer, timestamp, expiry)
 	}
 }
 
 func (b *BTree) Size() int {
 	return b.size
 }
+
+// search returns the index of the first key of the node not ordered before the key
+func (b *BTree) search(node *Node, key string) int {
+	i := 0
+	for i < len(node.keys) && b.cmp.Compare(key, node.keys[i]) > 0 {
+		i++
+	}
+	return i
+}
+
+// Delete removes the key from the tree, unlike a tombstone nothing of it is kept.
+// It reports whether the key was present.
+func (b *BTree) Delete(key string) bool {
+	if !b.delete(b.root, key) {
+		return false
+	}
+	b.size--
+
+	// the root lost its last key to a merge of its two children
+	if len(b.root.keys) == 0 && !b.root.isLeaf {
+		b.root = b.root.children[0]
+	}
+	return true
+}
+
+// delete removes the key from the subtree of the node.
+// Every node it descends into has at least minDegree keys, so removing a key never leaves it underfull.
+func (b *BTree) delete(node *Node, key string) bool {
+	i := b.search(node, key)
+
+	if i < len(node.keys) && node.keys[i] == key {
+		if node.isLeaf {
+			node.keys = slices.Delete(node.keys, i, i+1)
+			node.values = slices.Delete(node.values, i, i+1)
+			return true
+		}
+
+		// replace the key with its predecessor or successor, then delete that one from the child
+		if len(node.children[i].keys) >= b.minDegree {
+			predecessor := b.last(node.children[i])
+			node.keys[i], node.values[i] = predecessor.key, predecessor
+			return b.delete(node.children[i], predecessor.key)
+		}
+		if len(node.children[i+1].keys) >= b.minDegree {
+			successor := b.first(node.children[i+1])
+			node.keys[i], node.values[i] = successor.key, successor
+			return b.delete(node.children[i+1], successor.key)
+		}
+
+		b.merge(node, i)
+		return b.delete(node.children[i], key)
+	}
+
+	if node.isLeaf {
+		return false
+	}
+
+	if len(node.children[i].keys) < b.minDegree {
+		i = b.fill(node, i)
+	}
+	return b.delete(node.children[i], key)
+}
+
+// fill gives the i-th child of the node at least minDegree keys, borrowing from a sibling or merging with one.
+// It returns the index of the child the keys are in afterwards.
+func (b *BTree) fill(node *Node, i int) int {
+	if i > 0 && len(node.children[i-1].keys) >= b.minDegree {
+		b.borrowFromPrevious(node, i)
+		return i
+	}
+	if i < len(node.keys) && len(node.children[i+1].keys) >= b.minDegree {
+		b.borrowFromNext(node, i)
+		return i
+	}
+	if i < len(node.keys) {
+		b.merge(node, i)
+		return i
+	}
+	b.merge(node, i-1)
+	return i - 1
+}
+
+// borrowFromPrevious moves the separating key down into the i-th child and the last key of its left sibling up
+func (b *BTree) borrowFromPrevious(node *Node, i int) {
+	child, sibling := node.children[i], node.children[i-1]
+	last := len(sibling.keys) - 1
+
+	child.keys = slices.Insert(child.keys, 0, node.keys[i-1])
+	child.values = slices.Insert(child.values, 0, node.values[i-1])
+	if !child.isLeaf {
+		child.children = slices.Insert(child.children, 0, sibling.children[last+1])
+		sibling.children = sibling.children[:last+1]
+	}
+
+	node.keys[i-1], node.values[i-1] = sibling.keys[last], sibling.values[last]
+	sibling.keys = sibling.keys[:last]
+	sibling.values = sibling.values[:last]
+}
+
+// borrowFromNext moves the separating key down into the i-th child and the first key of its right sibling up
+func (b *BTree) borrowFromNext(node *Node, i int) {
+	child, sibling := node.children[i], node.children[i+1]
+
+	child.keys = append(child.keys, node.keys[i])
+	child.values = append(child.values, node.values[i])
+	if !child.isLeaf {
+		child.children = append(child.children, sibling.children[0])
+		sibling.children = slices.Delete(sibling.children, 0, 1)
+	}
+
+	node.keys[i], node.values[i] = sibling.keys[0], sibling.values[0]
+	sibling.keys = slices.Delete(sibling.keys, 0, 1)
+	sibling.values = slices.Delete(sibling.values, 0, 1)
+}
+
+// merge joins the i-th child, the separating key and the right sibling of the child into the child
+func (b *BTree) merge(node *Node, i int) {
+	child, sibling := node.children[i], node.children[i+1]
+
+	child.keys = append(append(child.keys, node.keys[i]), sibling.keys...)
+	child.values = append(append(child.values, node.values[i]), sibling.values...)
+	if !child.isLeaf {
+		child.children = append(child.children, sibling.children...)
+	}
+
+	node.keys = slices.Delete(node.keys, i, i+1)
+	node.values = slices.Delete(node.values, i, i+1)
+	node.children = slices.Delete(node.children, i+1, i+2)
+}
+
+func (b *BTree) first(node *Node) *Entry {
+	for !node.isLeaf {
+		node = node.children[0]
+	}
+	return node.values[0]
+}
+
+func (b *BTree) last(node *Node) *Entry {
+	for !node.isLeaf {
+		node = node.children[len(node.children)-1]
+	}
+	return node.values[len(node.values)-1]
+}
+
+// Ascend calls fn for every entry whose key is not ordered before from, in order, until fn returns false.
+// An empty from starts at the first key.
+func (b *BTree) Ascend(from string, fn func(entry *Entry) bool) {
+	b.ascend(b.root, from, fn)
+}
+
+func (b *BTree) ascend(node *Node, from string, fn func(entry *Entry) bool) bool {
+	i := 0
+	if from != "" {
+		i = b.search(node, from)
+	}
+
+	for ; i < len(node.keys); i++ {
+		if !node.isLeaf && !b.ascend(node.children[i], from, fn) {
+			return false
+		}
+		if !fn(node.values[i]) {
+			return false
+		}
+	}
+
+	if !node.isLeaf {
+		return b.ascend(node.children[len(node.keys)], from, fn)
+	}
+	return true
+}
+
+// Descend calls fn for every entry whose key is not ordered after from, in reverse order, until fn returns false.
+// An empty from starts at the last key.
+func (b *BTree) Descend(from string, fn func(entry *Entry) bool) {
+	b.descend(b.root, from, fn)
+}
+
+func (b *BTree) descend(node *Node, from string, fn func(entry *Entry) bool) bool {
+	// i is the index of the last key not ordered after from
+	i := len(node.keys) - 1
+	if from != "" {
+		for i >= 0 && b.cmp.Compare(node.keys[i], from) > 0 {
+			i--
+		}
+	}
+
+	if !node.isLeaf && !b.descend(node.children[i+1], from, fn) {
+		return false
+	}
+
+	for ; i >= 0; i-- {
+		if !fn(node.values[i]) {
+			return false
+		}
+		if !node.isLeaf && !b.descend(node.children[i], from, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+// Iterator walks the entries of a BTree ordered by key
+type Iterator struct {
+	stack []iteratorFrame
+	entry *Entry
+}
+
+// iteratorFrame is a node on the path to the current entry and the index of its next key
+type iteratorFrame struct {
+	node  *Node
+	index int
+}
+
+// Iterator returns an iterator positioned before the first entry
+func (b *BTree) Iterator() *Iterator {
+	it := &Iterator{}
+	it.pushLeft(b.root)
+	return it
+}
+
+// pushLeft pushes the path from the node down to its first key
+func (it *Iterator) pushLeft(node *Node) {
+	for {
+		it.stack = append(it.stack, iteratorFrame{node: node})
+		if node.isLeaf {
+			return
+		}
+		node = node.children[0]
+	}
+}
+
+// Next moves to the next entry, it returns false once there are none left
+func (it *Iterator) Next() bool {
+	for len(it.stack) > 0 {
+		top := &it.stack[len(it.stack)-1]
+		if top.index < len(top.node.keys) {
+			node, index := top.node, top.index
+			top.index++
+			it.entry = node.values[index]
+			// the keys of the child after the key come next
+			if !node.isLeaf {
+				it.pushLeft(node.children[index+1])
+			}
+			return true
+		}
+		it.stack = it.stack[:len(it.stack)-1]
+	}
+	it.entry = nil
+	return false
+}
+
+func (it *Iterator) Entry() *Entry {
+	return it.entry
+}
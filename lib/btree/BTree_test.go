@@ -1,6 +1,9 @@
 package btree
 
 import (
+	"NoSQLDB/lib/comparator"
+	"fmt"
+	"math/rand"
 	"testing"
 )
 
@@ -57,3 +60,143 @@ func testGet(t *testing.T, tree *BTree, key string, expectedValue []byte, expect
 			key, expectedValue, expectedTombstone)
 	}
 }
+
+func TestBTreeWithComparator(t *testing.T) {
+	for _, cmp := range []comparator.Comparator{comparator.Bytewise, comparator.Reverse} {
+		tree := NewBTreeWithComparator(2, cmp)
+
+		// inserting in an order that is not ascending splits nodes in the middle of their parent
+		for _, i := range rand.Perm(200) {
+			tree.Put(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)), false)
+		}
+
+		for i := 0; i < 200; i++ {
+			testGet(t, tree, fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)), false)
+		}
+	}
+}
+
+func TestBTreeDelete(t *testing.T) {
+	for _, minDegree := range []int{2, 3, 5} {
+		tree := NewBTree(minDegree)
+		present := make(map[string]bool)
+
+		for _, i := range rand.Perm(500) {
+			key := fmt.Sprintf("key-%03d", i)
+			tree.Put(key, []byte(key), false)
+			present[key] = true
+		}
+
+		for n, i := range rand.Perm(600) {
+			key := fmt.Sprintf("key-%03d", i)
+			if deleted := tree.Delete(key); deleted != present[key] {
+				t.Fatalf("Delete(%s) = %v; want %v", key, deleted, present[key])
+			}
+			delete(present, key)
+
+			if n%50 == 0 {
+				checkBTree(t, tree, tree.root, true)
+			}
+		}
+
+		if tree.Size() != 0 || len(tree.root.keys) != 0 || !tree.root.isLeaf {
+			t.Errorf("tree of degree %d is not empty after deleting every key: size %d", minDegree, tree.Size())
+		}
+	}
+}
+
+func TestBTreeDeleteKeepsOtherKeys(t *testing.T) {
+	tree := NewBTree(2)
+	for _, i := range rand.Perm(300) {
+		tree.Put(fmt.Sprintf("key-%03d", i), []byte(fmt.Sprintf("value-%03d", i)), false)
+	}
+
+	for i := 0; i < 300; i += 3 {
+		tree.Delete(fmt.Sprintf("key-%03d", i))
+	}
+	checkBTree(t, tree, tree.root, true)
+
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("key-%03d", i)
+		if i%3 == 0 {
+			testGet(t, tree, key, nil, false)
+		} else {
+			testGet(t, tree, key, []byte(fmt.Sprintf("value-%03d", i)), false)
+		}
+	}
+	if tree.Size() != 200 {
+		t.Errorf("Size() = %d; want 200", tree.Size())
+	}
+}
+
+func TestBTreeAscendDescend(t *testing.T) {
+	for _, cmp := range []comparator.Comparator{comparator.Bytewise, comparator.Reverse} {
+		tree := NewBTreeWithComparator(2, cmp)
+		for _, i := range rand.Perm(100) {
+			tree.Put(fmt.Sprintf("key-%03d", i*2), nil, false)
+		}
+
+		var keys []string
+		tree.Ascend("", func(entry *Entry) bool {
+			keys = append(keys, entry.Key())
+			return true
+		})
+		if len(keys) != 100 {
+			t.Fatalf("Ascend() visited %d keys; want 100", len(keys))
+		}
+		for i := 1; i < len(keys); i++ {
+			if cmp.Compare(keys[i-1], keys[i]) >= 0 {
+				t.Fatalf("Ascend() with %s visited %s before %s", cmp.Name(), keys[i-1], keys[i])
+			}
+		}
+
+		// from a missing key, stopping early
+		var ascended []string
+		tree.Ascend("key-051", func(entry *Entry) bool {
+			ascended = append(ascended, entry.Key())
+			return len(ascended) < 3
+		})
+		var descended []string
+		tree.Descend("key-051", func(entry *Entry) bool {
+			descended = append(descended, entry.Key())
+			return len(descended) < 3
+		})
+
+		after, before := []string{"key-052", "key-054", "key-056"}, []string{"key-050", "key-048", "key-046"}
+		if cmp == comparator.Reverse {
+			after, before = before, after
+		}
+		if fmt.Sprint(ascended) != fmt.Sprint(after) {
+			t.Errorf("Ascend(key-051) with %s = %v; want %v", cmp.Name(), ascended, after)
+		}
+		if fmt.Sprint(descended) != fmt.Sprint(before) {
+			t.Errorf("Descend(key-051) with %s = %v; want %v", cmp.Name(), descended, before)
+		}
+	}
+}
+
+// checkBTree verifies the key counts and the order of the keys in the subtree of the node
+func checkBTree(t *testing.T, tree *BTree, node *Node, root bool) {
+	t.Helper()
+	if !root && len(node.keys) < tree.minDegree-1 || len(node.keys) > 2*tree.minDegree-1 {
+		t.Fatalf("node holds %d keys with minimum degree %d", len(node.keys), tree.minDegree)
+	}
+	for i := 1; i < len(node.keys); i++ {
+		if tree.cmp.Compare(node.keys[i-1], node.keys[i]) >= 0 {
+			t.Fatalf("node keys out of order: %v", node.keys)
+		}
+	}
+	if node.isLeaf {
+		return
+	}
+	if len(node.children) != len(node.keys)+1 {
+		t.Fatalf("node has %d children for %d keys", len(node.children), len(node.keys))
+	}
+	for i, child := range node.children {
+		if i > 0 && tree.cmp.Compare(child.keys[0], node.keys[i-1]) <= 0 ||
+			i < len(node.keys) && tree.cmp.Compare(child.keys[len(child.keys)-1], node.keys[i]) >= 0 {
+			t.Fatalf("child %d with keys %v is not between the keys of its parent %v", i, child.keys, node.keys)
+		}
+		checkBTree(t, tree, child, false)
+	}
+}
@@ -0,0 +1,110 @@
+package cache
+
+import "NoSQLDB/lib/memtable"
+
+// arc is an adaptive replacement cache.
+// Pages used once are kept in recent, pages used again in frequent. The keys of pages evicted from either list
+// are remembered in a ghost list, and a miss on a ghost key shifts the target size of recent towards the list it was evicted from.
+// Sizes are counted in bytes instead of pages.
+type arc struct {
+	capacity       int
+	maxEntries     int // resident pages, ghosts are not counted
+	target         int // bytes recent should hold
+	items          map[string]*Page
+	recent         pageList
+	frequent       pageList
+	recentGhosts   pageList
+	frequentGhosts pageList
+}
+
+func newARC(capacity, maxEntries int) *arc {
+	return &arc{capacity: capacity, maxEntries: maxEntries, items: make(map[string]*Page)}
+}
+
+func (c *arc) resident(page *Page) bool {
+	return page.list == &c.recent || page.list == &c.frequent
+}
+
+func (c *arc) get(key string) *memtable.Entry {
+	page, ok := c.items[key]
+	if !ok || !c.resident(page) {
+		return nil
+	}
+	page.list.remove(page)
+	c.frequent.pushFront(page)
+	return page.entry
+}
+
+func (c *arc) put(page *Page) int {
+	target := &c.frequent
+	fromFrequentGhosts := false
+
+	if old, ok := c.items[page.key]; ok {
+		switch old.list {
+		case &c.recentGhosts:
+			c.target = min(c.capacity, c.target+max(page.size, page.size*c.frequentGhosts.bytes/c.recentGhosts.bytes))
+		case &c.frequentGhosts:
+			c.target = max(0, c.target-max(page.size, page.size*c.recentGhosts.bytes/c.frequentGhosts.bytes))
+			fromFrequentGhosts = true
+		}
+		old.list.remove(old)
+		delete(c.items, old.key)
+	} else {
+		target = &c.recent
+		// recent and its ghosts hold at most the capacity, all lists at most twice the capacity
+		for c.recent.bytes+c.recentGhosts.bytes+page.size > c.capacity && c.recentGhosts.tail != nil {
+			c.forget(&c.recentGhosts)
+		}
+		for c.recent.bytes+c.frequent.bytes+c.recentGhosts.bytes+c.frequentGhosts.bytes+page.size > 2*c.capacity && c.frequentGhosts.tail != nil {
+			c.forget(&c.frequentGhosts)
+		}
+	}
+	if page.size > c.capacity {
+		return 0
+	}
+
+	evicted := 0
+	for c.recent.bytes+c.frequent.bytes+page.size > c.capacity || c.len() >= c.maxEntries {
+		c.replace(fromFrequentGhosts)
+		evicted++
+	}
+
+	c.items[page.key] = page
+	target.pushFront(page)
+	return evicted
+}
+
+// replace evicts the least recently used page of recent or frequent, depending on the target size of recent,
+// and remembers its key in the matching ghost list
+func (c *arc) replace(fromFrequentGhosts bool) {
+	pages, ghosts := &c.frequent, &c.frequentGhosts
+	if c.recent.tail != nil && (c.recent.bytes > c.target || (fromFrequentGhosts && c.recent.bytes == c.target) || c.frequent.tail == nil) {
+		pages, ghosts = &c.recent, &c.recentGhosts
+	}
+
+	page := pages.tail
+	pages.remove(page)
+	page.entry = nil
+	ghosts.pushFront(page)
+}
+
+func (c *arc) forget(ghosts *pageList) {
+	page := ghosts.tail
+	ghosts.remove(page)
+	delete(c.items, page.key)
+}
+
+func (c *arc) remove(key string) {
+	if page, ok := c.items[key]; ok {
+		page.list.remove(page)
+		delete(c.items, key)
+	}
+}
+
+func (c *arc) len() int {
+	return c.recent.len + c.frequent.len
+}
+
+func (c *arc) bytes() int {
+	return c.recent.bytes + c.frequent.bytes
+}
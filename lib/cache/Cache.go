@@ -1,79 +1,183 @@
 package cache
 
-import "NoSQLDB/lib/memtable"
+import (
+	"NoSQLDB/lib/memtable"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+)
 
-// Cache is a struct that manages cached data using the LRU algorithm
+const (
+	// PAGE_OVERHEAD approximates the memory a cached entry takes up besides its key and value
+	PAGE_OVERHEAD = 96
+	// UNLIMITED_ENTRIES leaves the number of cached entries bounded by their bytes only
+	UNLIMITED_ENTRIES = math.MaxInt32
+
+	USE_LRU       = "lru"
+	USE_LFU       = "lfu"
+	USE_ARC       = "arc"
+	USE_W_TINYLFU = "w_tinylfu"
+)
+
+// Cache is a sharded cache of entries, every shard evicts entries on its own according to the eviction policy.
+// A key always lands in the same shard, so lookups of different keys rarely wait for each other.
 type Cache struct {
-	capacity int // The maximum number of items that can be stored in the Cache
-	items    map[string]*Page
-	head     *Page
-	tail     *Page
+	shards []*shard
+}
+
+// policy keeps the pages of a shard within its capacity in bytes and its number of entries.
+// It is only called while the shard is locked.
+type policy interface {
+	get(key string) *memtable.Entry
+	// put adds the page, replacing the page of its key, and returns the number of pages it evicted
+	put(page *Page) int
+	remove(key string)
+	len() int
+	bytes() int
+}
+
+type shard struct {
+	mu        sync.Mutex
+	policy    policy
+	newPolicy func() policy
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// Stats counts the lookups and evictions of a cache
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
 }
 
-// Page is a struct that represents an entry with pointers to the previous and next entries
-type Page struct {
-	entry *memtable.Entry
-	prev  *Page
-	next  *Page
+// NewCache creates an LRU cache of the given number of shards, which split the capacity in bytes evenly
+func NewCache(capacityBytes, shardCount int) *Cache {
+	c, _ := NewCacheWithPolicy(UNLIMITED_ENTRIES, capacityBytes, shardCount, USE_LRU)
+	return c
 }
 
-func NewCache(capacity int) *Cache {
-	return &Cache{
-		capacity: capacity,
-		items:    make(map[string]*Page),
+// NewCacheWithPolicy creates a cache whose shards evict their entries according to the named policy.
+// The shards split both limits evenly, a shard is full once it reaches either of them.
+func NewCacheWithPolicy(maxEntries, capacityBytes, shardCount int, policyName string) (*Cache, error) {
+	if shardCount <= 0 {
+		shardCount = 1
 	}
+	capacity := capacityBytes / shardCount
+	entries := UNLIMITED_ENTRIES
+	if maxEntries < UNLIMITED_ENTRIES {
+		entries = max(1, (maxEntries+shardCount-1)/shardCount)
+	}
+
+	var newPolicy func() policy
+	switch policyName {
+	case USE_LRU:
+		newPolicy = func() policy { return newLRU(capacity, entries) }
+	case USE_LFU:
+		newPolicy = func() policy { return newLFU(capacity, entries) }
+	case USE_ARC:
+		newPolicy = func() policy { return newARC(capacity, entries) }
+	case USE_W_TINYLFU:
+		newPolicy = func() policy { return newTinyLFU(capacity, entries) }
+	default:
+		return nil, fmt.Errorf("invalid cache policy %q", policyName)
+	}
+
+	c := &Cache{shards: make([]*shard, shardCount)}
+	for i := range c.shards {
+		c.shards[i] = &shard{policy: newPolicy(), newPolicy: newPolicy}
+	}
+	return c, nil
+}
+
+func (c *Cache) shardOf(key string) *shard {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(key))
+	return c.shards[hasher.Sum32()%uint32(len(c.shards))]
 }
 
 // Get returns the value of the key if it exists in the cache, otherwise it returns nil
 func (c *Cache) Get(key string) *memtable.Entry {
-	if entry, ok := c.items[key]; ok {
-		c.moveToHead(entry)
-		return entry.entry
+	s := c.shardOf(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.policy.get(key)
+	if entry == nil {
+		s.misses++
+	} else {
+		s.hits++
 	}
-	return nil
+	return entry
 }
 
-func (c *Cache) moveToHead(page *Page) {
-	if page.prev != nil {
-		page.prev.next = page.next
-	}
-	if page.next != nil {
-		page.next.prev = page.prev
+// Put adds an entry to the cache, evicting entries of its shard until it fits.
+// Entries larger than a shard are not cached.
+func (c *Cache) Put(entry *memtable.Entry) {
+	page := &Page{
+		key:   entry.Key(),
+		entry: entry,
+		size:  len(entry.Key()) + len(entry.Value()) + PAGE_OVERHEAD,
 	}
-	page.prev = nil
-	page.next = c.head
-	c.head.prev = page
-	c.head = page
+
+	s := c.shardOf(entry.Key())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictions += uint64(s.policy.put(page))
 }
 
-// Put adds a new key-value pair to the cache. If the cache is full, it removes the least recently used item
-func (c *Cache) Put(entry *memtable.Entry) {
-	if page, ok := c.items[entry.Key()]; ok {
-		page.entry = entry
-		c.moveToHead(page)
-		return
+// Delete removes the key from the cache, if it is cached
+func (c *Cache) Delete(key string) {
+	s := c.shardOf(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.policy.remove(key)
+}
+
+// Clear removes every entry from the cache, the counters are kept
+func (c *Cache) Clear() {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		s.policy = s.newPolicy()
+		s.mu.Unlock()
 	}
-	if len(c.items) >= c.capacity {
-		delete(c.items, c.tail.entry.Key())
-		c.removeTail()
+}
+
+// Size returns the number of cached entries
+func (c *Cache) Size() int {
+	size := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		size += s.policy.len()
+		s.mu.Unlock()
 	}
-	page := &Page{entry: entry}
-	c.items[entry.Key()] = page
-	if c.head == nil {
-		c.head = page
-		c.tail = page
-	} else {
-		page.next = c.head
-		c.head.prev = page
-		c.head = page
+	return size
+}
+
+// SizeBytes returns the memory taken up by the cached entries
+func (c *Cache) SizeBytes() int {
+	used := 0
+	for _, s := range c.shards {
+		s.mu.Lock()
+		used += s.policy.bytes()
+		s.mu.Unlock()
 	}
+	return used
 }
 
-func (c *Cache) removeTail() {
-	if c.tail.prev != nil {
-		c.tail.prev.next = nil
-	} else {
-		c.head = nil
+// Stats returns the hits, misses and evictions of all shards
+func (c *Cache) Stats() Stats {
+	var stats Stats
+	for _, s := range c.shards {
+		s.mu.Lock()
+		stats.Hits += s.hits
+		stats.Misses += s.misses
+		stats.Evictions += s.evictions
+		s.mu.Unlock()
 	}
-	c.tail = c.tail.prev
+	return stats
 }
@@ -0,0 +1,239 @@
+package cache
+
+import (
+	"NoSQLDB/lib/memtable"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	value := make([]byte, 100)
+	pageBytes := len("key0") + len(value) + PAGE_OVERHEAD
+	c := NewCache(3*pageBytes, 1)
+
+	for i := 0; i < 3; i++ {
+		c.Put(memtable.NewEntry(fmt.Sprintf("key%d", i), value, false))
+	}
+	// key0 is used again, so key1 is the least recently used one
+	if c.Get("key0") == nil {
+		t.Fatal("Get(key0) = nil")
+	}
+	c.Put(memtable.NewEntry("key3", value, false))
+
+	if c.Get("key1") != nil {
+		t.Error("key1 was not evicted")
+	}
+	for _, key := range []string{"key0", "key2", "key3"} {
+		if c.Get(key) == nil {
+			t.Errorf("Get(%s) = nil", key)
+		}
+	}
+	if got, want := c.SizeBytes(), 3*pageBytes; got != want {
+		t.Errorf("SizeBytes() = %d; want %d", got, want)
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 4 || stats.Misses != 1 || stats.Evictions != 1 {
+		t.Errorf("Stats() = %+v; want 4 hits, 1 miss and 1 eviction", stats)
+	}
+}
+
+func TestCacheByteCapacity(t *testing.T) {
+	c := NewCache(1000, 1)
+
+	c.Put(memtable.NewEntry("small", []byte("v"), false))
+	c.Put(memtable.NewEntry("large", make([]byte, 1000), false))
+	if c.Get("large") != nil {
+		t.Error("an entry larger than the cache was cached")
+	}
+
+	// overwriting a key replaces its size
+	c.Put(memtable.NewEntry("small", make([]byte, 500), false))
+	if got, want := c.SizeBytes(), len("small")+500+PAGE_OVERHEAD; got != want {
+		t.Errorf("SizeBytes() = %d; want %d", got, want)
+	}
+	if c.Size() != 1 {
+		t.Errorf("Size() = %d; want 1", c.Size())
+	}
+
+	// evicting several small entries makes room for a larger one
+	c = NewCache(1000, 1)
+	for i := 0; i < 5; i++ {
+		c.Put(memtable.NewEntry(fmt.Sprintf("k%d", i), make([]byte, 50), false))
+	}
+	c.Put(memtable.NewEntry("big", make([]byte, 600), false))
+	if c.Get("big") == nil {
+		t.Fatal("Get(big) = nil")
+	}
+	if c.SizeBytes() > 1000 {
+		t.Errorf("SizeBytes() = %d; want at most 1000", c.SizeBytes())
+	}
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	c := NewCache(64*1024, 8)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("key%d", (g*1000+i)%300)
+				c.Put(memtable.NewEntry(key, []byte(key), false))
+				if entry := c.Get(key); entry != nil && string(entry.Value()) != key {
+					t.Errorf("Get(%s) = %q", key, entry.Value())
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	stats := c.Stats()
+	if stats.Hits+stats.Misses != 8000 {
+		t.Errorf("Stats() = %+v; want 8000 lookups", stats)
+	}
+	if c.SizeBytes() > 64*1024 {
+		t.Errorf("SizeBytes() = %d; want at most %d", c.SizeBytes(), 64*1024)
+	}
+}
+
+func TestCacheDeleteAndClear(t *testing.T) {
+	c := NewCache(64*1024, 4)
+	for i := 0; i < 10; i++ {
+		c.Put(memtable.NewEntry(fmt.Sprintf("key%d", i), []byte("value"), false))
+	}
+	// negative entries are cached like any other
+	c.Put(memtable.NewEntry("deleted", nil, true))
+	if entry := c.Get("deleted"); entry == nil || !entry.Tombstone() {
+		t.Errorf("Get(deleted) = %v; want a tombstone", entry)
+	}
+
+	c.Delete("key3")
+	if c.Get("key3") != nil {
+		t.Error("key3 was not deleted")
+	}
+	if c.Size() != 10 {
+		t.Errorf("Size() = %d; want 10", c.Size())
+	}
+
+	c.Clear()
+	if c.Size() != 0 || c.SizeBytes() != 0 {
+		t.Errorf("Size() = %d, SizeBytes() = %d after Clear; want 0", c.Size(), c.SizeBytes())
+	}
+	c.Put(memtable.NewEntry("key0", []byte("value"), false))
+	if c.Get("key0") == nil {
+		t.Error("Get(key0) = nil after Clear")
+	}
+}
+
+var policies = []string{USE_LRU, USE_LFU, USE_ARC, USE_W_TINYLFU}
+
+func TestCachePolicies(t *testing.T) {
+	for _, policy := range policies {
+		t.Run(policy, func(t *testing.T) {
+			c, err := NewCacheWithPolicy(UNLIMITED_ENTRIES, 16*1024, 2, policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("key%d", i%300)
+				if c.Get(key) == nil {
+					c.Put(memtable.NewEntry(key, []byte(key), false))
+				}
+				if c.SizeBytes() > 16*1024 {
+					t.Fatalf("SizeBytes() = %d; want at most %d", c.SizeBytes(), 16*1024)
+				}
+			}
+			if c.Stats().Evictions == 0 {
+				t.Error("no entries were evicted")
+			}
+
+			c.Put(memtable.NewEntry("key", []byte("value"), false))
+			c.Get("key")
+			c.Put(memtable.NewEntry("key", []byte("updated"), false))
+			if entry := c.Get("key"); entry == nil || string(entry.Value()) != "updated" {
+				t.Errorf("Get(key) = %v; want updated", entry)
+			}
+			c.Delete("key")
+			if c.Get("key") != nil {
+				t.Error("key was not deleted")
+			}
+
+			c.Clear()
+			if c.Size() != 0 || c.SizeBytes() != 0 {
+				t.Errorf("Size() = %d, SizeBytes() = %d after Clear; want 0", c.Size(), c.SizeBytes())
+			}
+		})
+	}
+
+	if _, err := NewCacheWithPolicy(UNLIMITED_ENTRIES, 1024, 1, "fifo"); err == nil {
+		t.Error("NewCacheWithPolicy(fifo) succeeded")
+	}
+}
+
+// TestCacheScanResistance reads a few hot keys between the keys of a scan larger than the cache.
+// Every policy but LRU keeps the hot keys cached.
+func TestCacheScanResistance(t *testing.T) {
+	value := make([]byte, 100)
+	capacity := 50 * (len("scan0000") + len(value) + PAGE_OVERHEAD)
+
+	for _, policy := range policies[1:] {
+		t.Run(policy, func(t *testing.T) {
+			c, err := NewCacheWithPolicy(UNLIMITED_ENTRIES, capacity, 1, policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			read := func(key string) {
+				if c.Get(key) == nil {
+					c.Put(memtable.NewEntry(key, value, false))
+				}
+			}
+
+			for round := 0; round < 5; round++ {
+				for i := 0; i < 5; i++ {
+					read(fmt.Sprintf("hot%d", i))
+				}
+			}
+			for i := 0; i < 1000; i++ {
+				read(fmt.Sprintf("scan%04d", i))
+				if i%50 == 0 {
+					for j := 0; j < 5; j++ {
+						read(fmt.Sprintf("hot%d", j))
+					}
+				}
+			}
+
+			for i := 0; i < 5; i++ {
+				if c.Get(fmt.Sprintf("hot%d", i)) == nil {
+					t.Errorf("hot%d was evicted by the scan", i)
+				}
+			}
+		})
+	}
+}
+
+// TestCacheEntryLimit fills the cache with small entries, the number of entries runs out long before the bytes
+func TestCacheEntryLimit(t *testing.T) {
+	for _, policy := range policies {
+		t.Run(policy, func(t *testing.T) {
+			c, err := NewCacheWithPolicy(100, 64*1024, 4, policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("key%d", i)
+				c.Put(memtable.NewEntry(key, []byte(key), false))
+				if c.Size() > 100 {
+					t.Fatalf("Size() = %d after %d puts; want at most 100", c.Size(), i+1)
+				}
+			}
+			if c.Get("key999") == nil {
+				t.Error("Get(key999) = nil; want the last entry cached")
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package cache
+
+import "NoSQLDB/lib/memtable"
+
+// lfu evicts the least frequently used pages, the least recently used one among pages used equally often.
+// Pages are kept in one list per frequency.
+type lfu struct {
+	capacity     int
+	maxEntries   int
+	used         int
+	items        map[string]*Page
+	frequencies  map[int]*pageList
+	minFrequency int // no page is used less often, there may be no page used exactly as often
+}
+
+func newLFU(capacity, maxEntries int) *lfu {
+	return &lfu{
+		capacity:    capacity,
+		maxEntries:  maxEntries,
+		items:       make(map[string]*Page),
+		frequencies: make(map[int]*pageList),
+	}
+}
+
+func (c *lfu) get(key string) *memtable.Entry {
+	page, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	c.unlink(page)
+	page.frequency++
+	c.link(page)
+	return page.entry
+}
+
+func (c *lfu) put(page *Page) int {
+	// an overwritten key keeps its frequency
+	page.frequency = 1
+	if old, ok := c.items[page.key]; ok {
+		page.frequency = old.frequency
+		c.remove(page.key)
+	}
+	if page.size > c.capacity {
+		return 0
+	}
+
+	evicted := 0
+	for c.used+page.size > c.capacity || len(c.items) >= c.maxEntries {
+		for c.frequencies[c.minFrequency] == nil {
+			c.minFrequency++
+		}
+		c.remove(c.frequencies[c.minFrequency].tail.key)
+		evicted++
+	}
+
+	c.items[page.key] = page
+	c.used += page.size
+	c.link(page)
+	return evicted
+}
+
+func (c *lfu) link(page *Page) {
+	pages, ok := c.frequencies[page.frequency]
+	if !ok {
+		pages = &pageList{}
+		c.frequencies[page.frequency] = pages
+	}
+	pages.pushFront(page)
+	if len(c.items) == 1 || page.frequency < c.minFrequency {
+		c.minFrequency = page.frequency
+	}
+}
+
+func (c *lfu) unlink(page *Page) {
+	pages := page.list
+	pages.remove(page)
+	if pages.len == 0 {
+		delete(c.frequencies, page.frequency)
+	}
+}
+
+func (c *lfu) remove(key string) {
+	if page, ok := c.items[key]; ok {
+		c.unlink(page)
+		delete(c.items, key)
+		c.used -= page.size
+	}
+}
+
+func (c *lfu) len() int {
+	return len(c.items)
+}
+
+func (c *lfu) bytes() int {
+	return c.used
+}
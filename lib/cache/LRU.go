@@ -0,0 +1,56 @@
+package cache
+
+import "NoSQLDB/lib/memtable"
+
+// lru evicts the least recently used pages
+type lru struct {
+	capacity   int
+	maxEntries int
+	items      map[string]*Page
+	pages      pageList
+}
+
+func newLRU(capacity, maxEntries int) *lru {
+	return &lru{capacity: capacity, maxEntries: maxEntries, items: make(map[string]*Page)}
+}
+
+func (c *lru) get(key string) *memtable.Entry {
+	page, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	c.pages.moveToFront(page)
+	return page.entry
+}
+
+func (c *lru) put(page *Page) int {
+	c.remove(page.key)
+	if page.size > c.capacity {
+		return 0
+	}
+
+	evicted := 0
+	for c.pages.bytes+page.size > c.capacity || c.pages.len >= c.maxEntries {
+		c.remove(c.pages.tail.key)
+		evicted++
+	}
+
+	c.items[page.key] = page
+	c.pages.pushFront(page)
+	return evicted
+}
+
+func (c *lru) remove(key string) {
+	if page, ok := c.items[key]; ok {
+		c.pages.remove(page)
+		delete(c.items, key)
+	}
+}
+
+func (c *lru) len() int {
+	return c.pages.len
+}
+
+func (c *lru) bytes() int {
+	return c.pages.bytes
+}
@@ -0,0 +1,61 @@
+package cache
+
+import "NoSQLDB/lib/memtable"
+
+// Page is a struct that represents an entry with pointers to the previous and next entries
+type Page struct {
+	key       string
+	entry     *memtable.Entry // nil for pages which only remember a key
+	size      int
+	frequency int       // accesses counted by the LFU policy
+	list      *pageList // the list the page is in
+	prev      *Page
+	next      *Page
+}
+
+// pageList is a doubly linked list of pages, the most recently used page is at the head
+type pageList struct {
+	head  *Page
+	tail  *Page
+	len   int
+	bytes int
+}
+
+func (l *pageList) pushFront(page *Page) {
+	page.list = l
+	page.prev = nil
+	page.next = l.head
+	if l.head != nil {
+		l.head.prev = page
+	}
+	l.head = page
+	if l.tail == nil {
+		l.tail = page
+	}
+	l.len++
+	l.bytes += page.size
+}
+
+func (l *pageList) remove(page *Page) {
+	if page.prev != nil {
+		page.prev.next = page.next
+	} else {
+		l.head = page.next
+	}
+	if page.next != nil {
+		page.next.prev = page.prev
+	} else {
+		l.tail = page.prev
+	}
+	page.prev, page.next, page.list = nil, nil, nil
+	l.len--
+	l.bytes -= page.size
+}
+
+func (l *pageList) moveToFront(page *Page) {
+	if l.head == page {
+		return
+	}
+	l.remove(page)
+	l.pushFront(page)
+}
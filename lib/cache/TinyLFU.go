@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"NoSQLDB/lib/memtable"
+	"NoSQLDB/lib/pds"
+)
+
+const (
+	WINDOW_PERCENT    = 1  // share of the capacity held by the window, the rest is the main cache
+	PROTECTED_PERCENT = 80 // share of the main cache held by the pages used more than once
+
+	SKETCH_EPSILON = 0.001
+	SKETCH_DELTA   = 0.01
+	// the counts are halved after this many accesses per column of the sketch
+	SKETCH_SAMPLES_PER_COUNTER = 10
+)
+
+// tinyLFU is a W-TinyLFU cache. New pages enter a small LRU window, pages leaving the window are only admitted
+// into the main cache if their key was accessed more often than the key of the page they would evict.
+// The main cache is a segmented LRU, pages used again while on probation are protected.
+// Access frequencies are estimated by a count-min sketch, which is halved periodically so old accesses fade out.
+type tinyLFU struct {
+	items     map[string]*Page
+	window    pageList
+	probation pageList
+	protected pageList
+
+	capacity          int
+	windowCapacity    int
+	protectedCapacity int
+	// the number of pages is split between the segments like the bytes
+	maxEntries       int
+	windowEntries    int
+	protectedEntries int
+
+	sketch     *pds.CountMinSketch
+	samples    int
+	sampleSize int
+}
+
+func newTinyLFU(capacity, maxEntries int) *tinyLFU {
+	windowCapacity := capacity * WINDOW_PERCENT / 100
+	windowEntries := max(1, maxEntries*WINDOW_PERCENT/100)
+	sketch := pds.NewCountMinSketch(SKETCH_EPSILON, SKETCH_DELTA)
+	return &tinyLFU{
+		items:             make(map[string]*Page),
+		capacity:          capacity,
+		windowCapacity:    windowCapacity,
+		protectedCapacity: (capacity - windowCapacity) * PROTECTED_PERCENT / 100,
+		maxEntries:        maxEntries,
+		windowEntries:     windowEntries,
+		protectedEntries:  (maxEntries - windowEntries) * PROTECTED_PERCENT / 100,
+		sketch:            sketch,
+		sampleSize:        SKETCH_SAMPLES_PER_COUNTER * int(sketch.Width),
+	}
+}
+
+// record counts an access to the key, hits and misses alike
+func (c *tinyLFU) record(key string) {
+	c.sketch.Insert([]byte(key))
+	c.samples++
+	if c.samples >= c.sampleSize {
+		c.sketch.Halve()
+		c.samples /= 2
+	}
+}
+
+func (c *tinyLFU) frequency(key string) int {
+	return c.sketch.Count([]byte(key))
+}
+
+func (c *tinyLFU) get(key string) *memtable.Entry {
+	c.record(key)
+
+	page, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+
+	switch page.list {
+	case &c.window, &c.protected:
+		page.list.moveToFront(page)
+	case &c.probation:
+		c.probation.remove(page)
+		c.protected.pushFront(page)
+		c.demote()
+	}
+	return page.entry
+}
+
+func (c *tinyLFU) put(page *Page) int {
+	// an overwritten key stays in its segment
+	segment := &c.window
+	if old, ok := c.items[page.key]; ok {
+		segment = old.list
+		c.remove(page.key)
+	}
+	if page.size > c.capacity {
+		return 0
+	}
+
+	c.items[page.key] = page
+	segment.pushFront(page)
+	c.demote()
+
+	evicted := 0
+	for c.window.len > 1 && (c.window.bytes > c.windowCapacity || c.window.len > c.windowEntries) {
+		candidate := c.window.tail
+		c.window.remove(candidate)
+		evicted += c.admit(candidate)
+	}
+	// the newest page stays in the window even if it is larger than the window, the main cache makes room for it
+	for c.bytes() > c.capacity || c.len() > c.maxEntries {
+		victim := c.probation.tail
+		if victim == nil {
+			victim = c.protected.tail
+		}
+		c.remove(victim.key)
+		evicted++
+	}
+	return evicted
+}
+
+// demote moves the least recently used protected pages back on probation until protected fits
+func (c *tinyLFU) demote() {
+	for c.protected.bytes > c.protectedCapacity || c.protected.len > c.protectedEntries {
+		page := c.protected.tail
+		c.protected.remove(page)
+		c.probation.pushFront(page)
+	}
+}
+
+// admit moves a page leaving the window into the main cache if it is used more often than the pages it would evict.
+// It returns the number of pages evicted, the candidate included.
+func (c *tinyLFU) admit(candidate *Page) int {
+	mainCapacity := c.capacity - c.window.bytes
+	mainEntries := c.maxEntries - c.window.len
+	if candidate.size > mainCapacity || mainEntries < 1 {
+		delete(c.items, candidate.key)
+		return 1
+	}
+
+	frequency := c.frequency(candidate.key)
+	evicted := 0
+	for c.probation.bytes+c.protected.bytes+candidate.size > mainCapacity || c.probation.len+c.protected.len >= mainEntries {
+		victim := c.probation.tail
+		if victim == nil {
+			victim = c.protected.tail
+		}
+		if c.frequency(victim.key) >= frequency {
+			delete(c.items, candidate.key)
+			return evicted + 1
+		}
+		victim.list.remove(victim)
+		delete(c.items, victim.key)
+		evicted++
+	}
+
+	c.probation.pushFront(candidate)
+	return evicted
+}
+
+func (c *tinyLFU) remove(key string) {
+	if page, ok := c.items[key]; ok {
+		page.list.remove(page)
+		delete(c.items, key)
+	}
+}
+
+func (c *tinyLFU) len() int {
+	return len(c.items)
+}
+
+func (c *tinyLFU) bytes() int {
+	return c.window.bytes + c.probation.bytes + c.protected.bytes
+}
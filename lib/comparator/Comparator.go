@@ -0,0 +1,73 @@
+package comparator
+
+import (
+	"strings"
+)
+
+// Comparator defines the order of keys in memtables, sstables and compaction.
+type Comparator interface {
+	// Compare returns a negative number if a sorts before b, a positive number if it sorts after b
+	// and 0 only if the keys are identical.
+	Compare(a, b string) int
+	// Name identifies the comparator in the sstable directory, so tables are never read in a different order.
+	Name() string
+}
+
+var (
+	Bytewise        Comparator = bytewise{}
+	Reverse         Comparator = reverse{}
+	CaseInsensitive Comparator = caseInsensitive{}
+)
+
+var comparators = map[string]Comparator{
+	Bytewise.Name():        Bytewise,
+	Reverse.Name():         Reverse,
+	CaseInsensitive.Name(): CaseInsensitive,
+}
+
+// Register makes a comparator available to ByName, so it can be selected in the config.
+func Register(cmp Comparator) {
+	comparators[cmp.Name()] = cmp
+}
+
+// ByName returns the registered comparator with the given name.
+func ByName(name string) (Comparator, bool) {
+	cmp, ok := comparators[name]
+	return cmp, ok
+}
+
+// bytewise orders keys like Go strings
+type bytewise struct{}
+
+func (bytewise) Compare(a, b string) int {
+	return strings.Compare(a, b)
+}
+
+func (bytewise) Name() string {
+	return "bytewise"
+}
+
+// reverse orders keys in descending bytewise order
+type reverse struct{}
+
+func (reverse) Compare(a, b string) int {
+	return strings.Compare(b, a)
+}
+
+func (reverse) Name() string {
+	return "reverse"
+}
+
+// caseInsensitive orders keys ignoring case, keys differing only in case are ordered bytewise
+type caseInsensitive struct{}
+
+func (caseInsensitive) Compare(a, b string) int {
+	if c := strings.Compare(strings.ToLower(a), strings.ToLower(b)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+func (caseInsensitive) Name() string {
+	return "case_insensitive"
+}
@@ -0,0 +1,32 @@
+package comparator
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestComparators(t *testing.T) {
+	tests := []struct {
+		cmp  Comparator
+		want string
+	}{
+		{Bytewise, "B,a,b,c"},
+		{Reverse, "c,b,a,B"},
+		{CaseInsensitive, "a,B,b,c"},
+	}
+
+	for _, test := range tests {
+		keys := []string{"b", "c", "B", "a"}
+		sort.Slice(keys, func(i, j int) bool {
+			return test.cmp.Compare(keys[i], keys[j]) < 0
+		})
+		if got := strings.Join(keys, ","); got != test.want {
+			t.Errorf("%s order = %s; want %s", test.cmp.Name(), got, test.want)
+		}
+
+		if cmp, ok := ByName(test.cmp.Name()); !ok || cmp != test.cmp {
+			t.Errorf("ByName(%s) = %v, %v", test.cmp.Name(), cmp, ok)
+		}
+	}
+}
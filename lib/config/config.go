@@ -1,16 +1,19 @@
 package config
 
 import (
+	"NoSQLDB/lib/comparator"
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"regexp"
+	"time"
 )
 
 const (
-	KB = 1 << (10 * iota) // 1 kilobyte
-	MB                    // 1 megabyte
-	GB                    // 1 gigabyte
-	TB                    // 1 terabyte
+	KB = 1 << (10 * (iota + 1)) // 1 kilobyte
+	MB                          // 1 megabyte
+	GB                          // 1 gigabyte
+	TB                          // 1 terabyte
 )
 
 // configurable values go here
@@ -28,10 +31,25 @@ type Config struct {
 	OutputDir        string `json:"output_dir"`
 	MemtableType     string `json:"memtable_type"`
 
+	// memory a memtable may use for its keys, values and entries, it is full once it reaches either limit
+	MemtableSizeBytes int `json:"memtable_size_bytes"`
+
+	// Key order, the name of a registered comparator
+	Comparator string `json:"comparator"`
+
+	// Versioning, disabled when both are unset
+	VersionsToKeep   int    `json:"versions_to_keep"`
+	VersionRetention string `json:"version_retention"`
+
 	// SStable
 	IndexStride   int `json:"index_stride"`
 	SummaryStride int `json:"summary_stride"`
 
+	// Compaction, 0 disables automatic compaction
+	CompactionThreshold int `json:"compaction_threshold"`
+	// tables an automatic compaction merges: full merges every table, tiered the newest tables of a similar size
+	CompactionStrategy string `json:"compaction_strategy"`
+
 	// Bloom filter
 	BFExpectedElements  int     `json:"bf_expected_elements"`
 	BFFalsePositiveRate float64 `json:"bf_false_positive_rate"`
@@ -41,11 +59,108 @@ type Config struct {
 	TokenBucketRate int    `json:"token_bucket_rate"`
 	FillInterval    string `json:"fill_interval"`
 
-	//Cache
-	CacheSize int `json:"cache_size"`
+	// Cache of the entries read from the sstables, the number of entries it holds
+	CacheSize   int `json:"cache_size"`
+	CacheShards int `json:"cache_shards"`
+	// memory the cache may use for its entries, it is full once it reaches either limit.
+	// Both limits are split evenly between the shards.
+	CacheSizeBytes int `json:"cache_size_bytes"`
+	// eviction policy of the cache: lru, lfu, arc or w_tinylfu
+	CachePolicy string `json:"cache_policy"`
+
+	// Cache of the decoded summary, index and data blocks of the sstables, in bytes, 0 disables it
+	BlockCacheSize int `json:"block_cache_size"`
+
+	// Value log, values longer than the threshold are stored apart from their keys, 0 keeps every value inline
+	ValueThreshold   int    `json:"value_threshold"`
+	ValueLogDir      string `json:"value_log_dir"`
+	ValueLogFileSize int    `json:"value_log_file_size"`
+	// files with a smaller share of live values are rewritten by the garbage collector
+	ValueLogGCRatio float64 `json:"value_log_gc_ratio"`
+
+	// Column families, created when the engine starts
+	ColumnFamilies map[string]ColumnFamilyConfig `json:"column_families"`
+}
+
+// ColumnFamilyConfig overrides the storage settings for a single column family.
+// Unset values are inherited from the global config.
+type ColumnFamilyConfig struct {
+	NumTables         int    `json:"num_tables"`
+	MemtableSize      int    `json:"memtable_size"`
+	MemtableSizeBytes int    `json:"memtable_size_bytes"`
+	SkipListMaxLevel  int    `json:"skip_list_max_level"`
+	BTreeMinDegree    int    `json:"btree_min_degree"`
+	OutputDir         string `json:"output_dir"` // defaults to a subdirectory of the global output dir
+	MemtableType      string `json:"memtable_type"`
+	Comparator        string `json:"comparator"`
+
+	IndexStride   int `json:"index_stride"`
+	SummaryStride int `json:"summary_stride"`
+
+	// negative disables automatic compaction
+	CompactionThreshold int    `json:"compaction_threshold"`
+	CompactionStrategy  string `json:"compaction_strategy"`
+
+	BFExpectedElements  int     `json:"bf_expected_elements"`
+	BFFalsePositiveRate float64 `json:"bf_false_positive_rate"`
+}
+
+// Resolve returns a copy of the global config with the column family settings applied.
+func (cf ColumnFamilyConfig) Resolve(name string, config *Config) *Config {
+	resolved := *config
+	resolved.OutputDir = filepath.Join(config.OutputDir, name) + "/"
+
+	if cf.NumTables > 0 {
+		resolved.NumTables = cf.NumTables
+	}
+	if cf.MemtableSize > 0 {
+		resolved.MemtableSize = cf.MemtableSize
+	}
+	if cf.MemtableSizeBytes > 0 {
+		resolved.MemtableSizeBytes = cf.MemtableSizeBytes
+	}
+	if cf.SkipListMaxLevel > 0 {
+		resolved.SkipListMaxLevel = cf.SkipListMaxLevel
+	}
+	if cf.BTreeMinDegree > 0 {
+		resolved.BTreeMinDegree = cf.BTreeMinDegree
+	}
+	if cf.OutputDir != "" {
+		resolved.OutputDir = cf.OutputDir
+	}
+	if isMemtableTypeValid(cf.MemtableType) {
+		resolved.MemtableType = cf.MemtableType
+	}
+	if _, ok := comparator.ByName(cf.Comparator); ok {
+		resolved.Comparator = cf.Comparator
+	}
+	if cf.IndexStride > 0 {
+		resolved.IndexStride = cf.IndexStride
+	}
+	if cf.SummaryStride > 0 {
+		resolved.SummaryStride = cf.SummaryStride
+	}
+	if cf.CompactionThreshold > 0 {
+		resolved.CompactionThreshold = cf.CompactionThreshold
+	} else if cf.CompactionThreshold < 0 {
+		resolved.CompactionThreshold = 0
+	}
+	if isCompactionStrategyValid(cf.CompactionStrategy) {
+		resolved.CompactionStrategy = cf.CompactionStrategy
+	}
+	if cf.BFExpectedElements > 0 {
+		resolved.BFExpectedElements = cf.BFExpectedElements
+	}
+	if cf.BFFalsePositiveRate > 0 && cf.BFFalsePositiveRate < 1 {
+		resolved.BFFalsePositiveRate = cf.BFFalsePositiveRate
+	}
+
+	return &resolved
 }
 
 // default values go here
+// KB used to be a single byte, fixing the units migrated the WAL segment default from 64 B to 64 KB
+// and the value log file default from 64 KB to 64 MB. Existing segments and files are read whatever size they were written with.
 var DefaultConfig = Config{
 	WALSegmentSize: 64 * KB,
 	WALDir:         "data/wal/",
@@ -57,9 +172,19 @@ var DefaultConfig = Config{
 	OutputDir:        "data/sstable/",
 	MemtableType:     "map",
 
+	MemtableSizeBytes: 4 * MB,
+
+	Comparator: "bytewise",
+
+	VersionsToKeep:   0,
+	VersionRetention: "",
+
 	IndexStride:   5,
 	SummaryStride: 4,
 
+	CompactionThreshold: 4,
+	CompactionStrategy:  "full",
+
 	BFExpectedElements:  100,
 	BFFalsePositiveRate: 0.2,
 
@@ -67,7 +192,17 @@ var DefaultConfig = Config{
 	TokenBucketRate: 10,
 	FillInterval:    "500ms",
 
-	CacheSize: 100,
+	CacheSize:      100,
+	CacheShards:    16,
+	CacheSizeBytes: 8 * MB,
+	CachePolicy:    "lru",
+
+	BlockCacheSize: 16 * MB,
+
+	ValueThreshold:   0,
+	ValueLogDir:      "data/vlog/",
+	ValueLogFileSize: 64 * MB,
+	ValueLogGCRatio:  0.5,
 }
 
 func GetDefaultConfig() *Config {
@@ -82,9 +217,19 @@ func GetDefaultConfig() *Config {
 		OutputDir:        "data/sstable/",
 		MemtableType:     "map",
 
+		MemtableSizeBytes: 4 * MB,
+
+		Comparator: "bytewise",
+
+		VersionsToKeep:   0,
+		VersionRetention: "",
+
 		IndexStride:   5,
 		SummaryStride: 4,
 
+		CompactionThreshold: 4,
+		CompactionStrategy:  "full",
+
 		BFExpectedElements:  100,
 		BFFalsePositiveRate: 0.2,
 
@@ -92,7 +237,17 @@ func GetDefaultConfig() *Config {
 		TokenBucketRate: 10,
 		FillInterval:    "500ms",
 
-		CacheSize: 100,
+		CacheSize:      100,
+		CacheShards:    16,
+		CacheSizeBytes: 8 * MB,
+		CachePolicy:    "lru",
+
+		BlockCacheSize: 16 * MB,
+
+		ValueThreshold:   0,
+		ValueLogDir:      "data/vlog/",
+		ValueLogFileSize: 64 * MB,
+		ValueLogGCRatio:  0.5,
 	}
 }
 
@@ -119,7 +274,7 @@ func LoadConfig(filepath string) (*Config, error) {
 		}
 	*/
 
-	if config.WALSegmentSize <= KB {
+	if config.WALSegmentSize <= 0 {
 		config.WALSegmentSize = DefaultConfig.WALSegmentSize
 	}
 
@@ -135,6 +290,10 @@ func LoadConfig(filepath string) (*Config, error) {
 		config.MemtableSize = DefaultConfig.MemtableSize
 	}
 
+	if config.MemtableSizeBytes < 0 {
+		config.MemtableSizeBytes = DefaultConfig.MemtableSizeBytes
+	}
+
 	if config.SkipListMaxLevel <= 0 {
 		config.SkipListMaxLevel = DefaultConfig.SkipListMaxLevel
 	}
@@ -147,12 +306,31 @@ func LoadConfig(filepath string) (*Config, error) {
 		config.OutputDir = DefaultConfig.OutputDir
 	}
 
-	if config.MemtableType != "map" &&
-		config.MemtableType != "btree" &&
-		config.MemtableType != "skip_list" {
+	if !isMemtableTypeValid(config.MemtableType) {
 		config.MemtableType = DefaultConfig.MemtableType
 	}
 
+	// custom comparators have to be registered before loading the config
+	if _, ok := comparator.ByName(config.Comparator); !ok {
+		config.Comparator = DefaultConfig.Comparator
+	}
+
+	if config.VersionsToKeep < 0 {
+		config.VersionsToKeep = DefaultConfig.VersionsToKeep
+	}
+
+	if _, err := time.ParseDuration(config.VersionRetention); err != nil {
+		config.VersionRetention = DefaultConfig.VersionRetention
+	}
+
+	if config.CompactionThreshold < 0 {
+		config.CompactionThreshold = DefaultConfig.CompactionThreshold
+	}
+
+	if !isCompactionStrategyValid(config.CompactionStrategy) {
+		config.CompactionStrategy = DefaultConfig.CompactionStrategy
+	}
+
 	if config.TokenBucketSize <= 0 {
 		config.TokenBucketSize = DefaultConfig.TokenBucketSize
 	}
@@ -169,9 +347,62 @@ func LoadConfig(filepath string) (*Config, error) {
 		config.CacheSize = DefaultConfig.CacheSize
 	}
 
+	if config.CacheShards <= 0 {
+		config.CacheShards = DefaultConfig.CacheShards
+	}
+
+	if config.CacheSizeBytes <= 0 {
+		config.CacheSizeBytes = DefaultConfig.CacheSizeBytes
+	}
+
+	if !isCachePolicyValid(config.CachePolicy) {
+		config.CachePolicy = DefaultConfig.CachePolicy
+	}
+
+	if config.BlockCacheSize < 0 {
+		config.BlockCacheSize = DefaultConfig.BlockCacheSize
+	}
+
+	if config.ValueThreshold < 0 {
+		config.ValueThreshold = DefaultConfig.ValueThreshold
+	}
+
+	if config.ValueLogDir == "" {
+		config.ValueLogDir = DefaultConfig.ValueLogDir
+	}
+
+	if config.ValueLogFileSize <= 0 {
+		config.ValueLogFileSize = DefaultConfig.ValueLogFileSize
+	}
+
+	if config.ValueLogGCRatio <= 0 || config.ValueLogGCRatio > 1 {
+		config.ValueLogGCRatio = DefaultConfig.ValueLogGCRatio
+	}
+
 	return &config, err
 }
 
+func isMemtableTypeValid(memtableType string) bool {
+	return memtableType == "map" ||
+		memtableType == "btree" ||
+		memtableType == "skip_list" ||
+		memtableType == "arena_skip_list" ||
+		memtableType == "concurrent_skip_list" ||
+		memtableType == "art"
+}
+
+func isCompactionStrategyValid(strategy string) bool {
+	return strategy == "full" ||
+		strategy == "tiered"
+}
+
+func isCachePolicyValid(policy string) bool {
+	return policy == "lru" ||
+		policy == "lfu" ||
+		policy == "arc" ||
+		policy == "w_tinylfu"
+}
+
 func isFillIntervalValid(duration string) bool {
 	// Regular expression to match valid duration formats
 	// This regex matches:
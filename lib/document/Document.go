@@ -0,0 +1,148 @@
+package document
+
+import (
+	"NoSQLDB/lib/engine"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidJSON = errors.New("value is not valid JSON")
+
+// Store keeps JSON documents in the engine and queries them by path.
+type Store struct {
+	engine *engine.Engine
+}
+
+// Document is a decoded value together with its key
+type Document struct {
+	Key   string
+	Value interface{}
+}
+
+func NewStore(e *engine.Engine) *Store {
+	return &Store{engine: e}
+}
+
+// Put stores the document after checking that it is valid JSON.
+func (s *Store) Put(key string, doc []byte) error {
+	if !json.Valid(doc) {
+		return ErrInvalidJSON
+	}
+	return s.engine.Put(key, doc)
+}
+
+// Get returns the decoded document, nil if the key is absent.
+func (s *Store) Get(key string) (interface{}, error) {
+	data, err := s.engine.Get(key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	return decode(data)
+}
+
+// GetPath returns the value at the path within the document, e.g. $.address.city.
+func (s *Store) GetPath(key, path string) (interface{}, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := s.engine.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, ErrPathNotFound
+	}
+
+	doc, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return getPath(doc, segments)
+}
+
+// SetPath replaces the value at the path, creating missing objects along the way.
+// The update is retried with compare and swap, so concurrent updates of other paths are not lost.
+func (s *Store) SetPath(key, path string, value interface{}) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+
+	// round trip the value so it holds the same types as a decoded document
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	for {
+		data, err := s.engine.Get(key)
+		if err != nil {
+			return err
+		}
+
+		var doc interface{}
+		if data != nil {
+			doc, err = decode(data)
+			if err != nil {
+				return err
+			}
+		}
+
+		decoded, err := decode(encoded)
+		if err != nil {
+			return err
+		}
+		doc, err = setPath(doc, segments, decoded)
+		if err != nil {
+			return err
+		}
+
+		updated, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+
+		swapped, err := s.engine.CompareAndSwap(key, data, updated)
+		if err != nil || swapped {
+			return err
+		}
+	}
+}
+
+// Find returns the documents under the key prefix which satisfy the filter, ordered by key.
+// Filters compare a path with a literal, e.g. $.status == 'open' or $.age >= 18.
+func (s *Store) Find(prefix, expression string) ([]Document, error) {
+	f, err := parseFilter(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.engine.ScanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []Document
+	for _, entry := range entries {
+		doc, err := decode(entry.Value())
+		if err != nil {
+			// values written without the document layer are skipped
+			continue
+		}
+		if f.matches(doc) {
+			docs = append(docs, Document{entry.Key(), doc})
+		}
+	}
+
+	return docs, nil
+}
+
+func decode(data []byte) (interface{}, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, ErrInvalidJSON
+	}
+	return doc, nil
+}
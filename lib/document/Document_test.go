@@ -0,0 +1,90 @@
+package document
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func mustDecode(t *testing.T, data string) interface{} {
+	doc, err := decode([]byte(data))
+	if err != nil {
+		t.Fatalf("decode(%s) = %v", data, err)
+	}
+	return doc
+}
+
+func TestGetPath(t *testing.T) {
+	doc := mustDecode(t, `{"address":{"city":"Novi Sad"},"items":[{"name":"a"},{"name":"b"}],"first name":"Ana"}`)
+
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"$.address.city", "Novi Sad"},
+		{"$.items[1].name", "b"},
+		{"$['first name']", "Ana"},
+	}
+
+	for _, test := range tests {
+		segments, err := parsePath(test.path)
+		if err != nil {
+			t.Fatalf("parsePath(%s) = %v", test.path, err)
+		}
+		got, err := getPath(doc, segments)
+		if err != nil || got != test.want {
+			t.Errorf("getPath(%s) = %v, %v; want %v", test.path, got, err, test.want)
+		}
+	}
+
+	segments, _ := parsePath("$.items[2]")
+	if _, err := getPath(doc, segments); err != ErrPathNotFound {
+		t.Errorf("getPath($.items[2]) = %v; want ErrPathNotFound", err)
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	doc := mustDecode(t, `{"address":{"city":"Novi Sad"}}`)
+
+	segments, _ := parsePath("$.address.zip")
+	doc, err := setPath(doc, segments, "21000")
+	if err != nil {
+		t.Fatalf("setPath() = %v", err)
+	}
+	segments, _ = parsePath("$.status.code")
+	doc, err = setPath(doc, segments, 1.0)
+	if err != nil {
+		t.Fatalf("setPath() = %v", err)
+	}
+
+	data, _ := json.Marshal(doc)
+	want := `{"address":{"city":"Novi Sad","zip":"21000"},"status":{"code":1}}`
+	if string(data) != want {
+		t.Errorf("setPath() = %s; want %s", data, want)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	doc := mustDecode(t, `{"status":"open","priority":3,"tags":["a"]}`)
+
+	tests := []struct {
+		expression string
+		want       bool
+	}{
+		{"$.status == 'open'", true},
+		{`$.status != "open"`, false},
+		{"$.priority >= 3", true},
+		{"$.priority < 3", false},
+		{"$.tags == 'a'", false},
+		{"$.missing == null", false},
+	}
+
+	for _, test := range tests {
+		f, err := parseFilter(test.expression)
+		if err != nil {
+			t.Fatalf("parseFilter(%s) = %v", test.expression, err)
+		}
+		if got := f.matches(doc); got != test.want {
+			t.Errorf("%s = %v; want %v", test.expression, got, test.want)
+		}
+	}
+}
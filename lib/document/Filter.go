@@ -0,0 +1,135 @@
+package document
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// filter compares the value at a path with a literal, e.g. $.status == 'open'
+type filter struct {
+	path     []segment
+	operator string
+	literal  interface{}
+}
+
+// operators, longer ones first so <= is not read as <
+var operators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// parseFilter parses a filter of the form <path> <operator> <literal>.
+// Literals are quoted strings, numbers, true, false and null.
+func parseFilter(expression string) (*filter, error) {
+	for i := 0; i < len(expression); i++ {
+		// operators inside quoted member names do not count
+		if expression[i] == '\'' || expression[i] == '"' {
+			if end := strings.IndexByte(expression[i+1:], expression[i]); end != -1 {
+				i += end + 1
+				continue
+			}
+		}
+
+		for _, operator := range operators {
+			if !strings.HasPrefix(expression[i:], operator) {
+				continue
+			}
+
+			path, err := parsePath(strings.TrimSpace(expression[:i]))
+			if err != nil {
+				return nil, err
+			}
+			literal, err := parseLiteral(strings.TrimSpace(expression[i+len(operator):]))
+			if err != nil {
+				return nil, err
+			}
+			return &filter{path, operator, literal}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no operator in filter %q", expression)
+}
+
+func parseLiteral(literal string) (interface{}, error) {
+	if s, ok := unquote(literal); ok {
+		return s, nil
+	}
+
+	switch literal {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+
+	number, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid literal %q", literal)
+	}
+	return number, nil
+}
+
+// matches reports whether the decoded document satisfies the filter.
+// Documents without the path never match.
+func (f *filter) matches(doc interface{}) bool {
+	value, err := getPath(doc, f.path)
+	if err != nil {
+		return false
+	}
+
+	switch f.operator {
+	case "==":
+		return equal(value, f.literal)
+	case "!=":
+		return !equal(value, f.literal)
+	}
+
+	cmp, ok := compare(value, f.literal)
+	if !ok {
+		return false
+	}
+
+	switch f.operator {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// equal compares a decoded value with a literal, objects and arrays never equal a literal
+func equal(value, literal interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return value == literal
+}
+
+// compare orders two numbers or two strings
+func compare(a, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		if a < b {
+			return -1, true
+		} else if a > b {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	}
+	return 0, false
+}
@@ -0,0 +1,130 @@
+package document
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrPathNotFound = errors.New("path not found")
+
+// segment is a single step of a path, either an object member or an array index
+type segment struct {
+	name    string
+	index   int
+	isIndex bool
+}
+
+// parsePath parses paths like $.address.city, $.items[0].name and $['first name'].
+func parsePath(path string) ([]segment, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path %q must start with $", path)
+	}
+
+	var segments []segment
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("empty member name in path %q", path)
+			}
+			segments = append(segments, segment{name: name})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unclosed bracket in path %q", path)
+			}
+			inner := rest[1:end]
+			if name, ok := unquote(inner); ok {
+				segments = append(segments, segment{name: name})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid index %q in path %q", inner, path)
+				}
+				segments = append(segments, segment{index: index, isIndex: true})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q in path %q", rest[0], path)
+		}
+	}
+
+	return segments, nil
+}
+
+// unquote strips matching single or double quotes
+func unquote(s string) (string, bool) {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1], true
+	}
+	return "", false
+}
+
+// getPath returns the value at the path within the decoded document
+func getPath(doc interface{}, segments []segment) (interface{}, error) {
+	current := doc
+	for _, seg := range segments {
+		if seg.isIndex {
+			array, ok := current.([]interface{})
+			if !ok || seg.index >= len(array) {
+				return nil, ErrPathNotFound
+			}
+			current = array[seg.index]
+		} else {
+			object, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, ErrPathNotFound
+			}
+			current, ok = object[seg.name]
+			if !ok {
+				return nil, ErrPathNotFound
+			}
+		}
+	}
+	return current, nil
+}
+
+// setPath returns the document with the value at the path replaced.
+// Missing object members are created, array indexes have to exist.
+func setPath(doc interface{}, segments []segment, value interface{}) (interface{}, error) {
+	if len(segments) == 0 {
+		return value, nil
+	}
+
+	seg := segments[0]
+	if seg.isIndex {
+		array, ok := doc.([]interface{})
+		if !ok || seg.index >= len(array) {
+			return nil, ErrPathNotFound
+		}
+		element, err := setPath(array[seg.index], segments[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		array[seg.index] = element
+		return array, nil
+	}
+
+	object, ok := doc.(map[string]interface{})
+	if !ok {
+		if doc != nil {
+			return nil, ErrPathNotFound
+		}
+		object = make(map[string]interface{})
+	}
+	member, err := setPath(object[seg.name], segments[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	object[seg.name] = member
+	return object, nil
+}
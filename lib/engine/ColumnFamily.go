@@ -0,0 +1,216 @@
+package engine
+
+import (
+	"NoSQLDB/lib/comparator"
+	cfg "NoSQLDB/lib/config"
+	mt "NoSQLDB/lib/memtable"
+	writeaheadlog "NoSQLDB/lib/write-ahead-log"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	ErrColumnFamilyExists      = errors.New("column family already exists")
+	ErrInvalidColumnFamilyName = errors.New("invalid column family name")
+	ErrUnknownColumnFamily     = errors.New("unknown column family")
+)
+
+// ColumnFamily is a named keyspace with its own mempool and sstables.
+// All column families share the WAL, token bucket and lock of the engine.
+type ColumnFamily struct {
+	Name       string
+	Config     *cfg.Config
+	Comparator comparator.Comparator
+	Mempool    *mt.Mempool
+	SSReader   *mt.SSReader
+	SSWriter   *mt.SSWriter
+
+	engine *Engine
+}
+
+// CreateColumnFamily creates a column family, settings missing from opts are taken from the engine config.
+// Column families have to be created before restoring the WAL, so their entries can be replayed.
+func (e *Engine) CreateColumnFamily(name string, opts cfg.ColumnFamilyConfig) (*ColumnFamily, error) {
+	if !isNameValid(name) {
+		return nil, ErrInvalidColumnFamilyName
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.ColumnFamilies[name]; ok {
+		return nil, ErrColumnFamilyExists
+	}
+
+	cf, err := e.newColumnFamily(name, opts)
+	if err != nil {
+		return nil, err
+	}
+	e.ColumnFamilies[name] = cf
+
+	return cf, nil
+}
+
+// isNameValid reports whether the name can be used for a column family or an index
+func isNameValid(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "\x00/\\.")
+}
+
+// newColumnFamily creates the storage of a column family without registering it in the engine.
+func (e *Engine) newColumnFamily(name string, opts cfg.ColumnFamilyConfig) (*ColumnFamily, error) {
+	config := opts.Resolve(name, e.Config)
+	if err := os.MkdirAll(config.OutputDir, 0755); err != nil {
+		return nil, err
+	}
+
+	cmp, err := keyComparator(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// versioning and merge operators only apply to the default keyspace
+	mempool, reader, writer, err := newStorage(config, nil, cmp, e.ValueLog, e.BlockCache)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ColumnFamily{
+		Name:       name,
+		Config:     config,
+		Comparator: cmp,
+		Mempool:    mempool,
+		SSReader:   reader,
+		SSWriter:   writer,
+		engine:     e,
+	}, nil
+}
+
+// ColumnFamily returns the column family with the given name, nil if it does not exist.
+func (e *Engine) ColumnFamily(name string) *ColumnFamily {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.ColumnFamilies[name]
+}
+
+// columnFamilyNamed returns the column family a WAL or value log record was written to, index names start with INDEX_PREFIX.
+// Records of a column family or index which is not registered can't be placed, they fail with ErrUnknownColumnFamily.
+func (e *Engine) columnFamilyNamed(name string) (*ColumnFamily, error) {
+	if indexName, ok := strings.CutPrefix(name, INDEX_PREFIX); ok {
+		if index, ok := e.Indexes[indexName]; ok {
+			return index.cf, nil
+		}
+	} else if cf, ok := e.ColumnFamilies[name]; ok {
+		return cf, nil
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownColumnFamily, name)
+}
+
+func (cf *ColumnFamily) Put(key string, value []byte) error {
+	if !cf.engine.getToken() {
+		return fmt.Errorf("timed out while putting key %s", key)
+	}
+
+	cf.engine.mu.RLock()
+	defer cf.engine.mu.RUnlock()
+
+	return cf.write(mt.NewEntry(key, value, false))
+}
+
+func (cf *ColumnFamily) Get(key string) ([]byte, error) {
+	if !cf.engine.getToken() {
+		return nil, fmt.Errorf("timed out while getting key %s", key)
+	}
+
+	cf.engine.mu.RLock()
+	defer cf.engine.mu.RUnlock()
+
+	value, err := cf.Mempool.Get(key)
+	if value == nil || err != nil {
+		value, err = cf.SSReader.Get(key)
+	}
+
+	if value == nil || err != nil || value.Tombstone() || value.Expired() {
+		return nil, err
+	}
+	return cf.engine.valueOf(value)
+}
+
+func (cf *ColumnFamily) Delete(key string) error {
+	if !cf.engine.getToken() {
+		return fmt.Errorf("timed out while deleting key %s", key)
+	}
+
+	cf.engine.mu.RLock()
+	defer cf.engine.mu.RUnlock()
+
+	return cf.write(mt.NewEntry(key, nil, true))
+}
+
+// Scan returns the live entries of the column family with keys in [start, end), ordered by key.
+// An empty end means the scan is not bounded from above.
+func (cf *ColumnFamily) Scan(start, end string) ([]*mt.Entry, error) {
+	if !cf.engine.getToken() {
+		return nil, fmt.Errorf("timed out while scanning range [%s, %s)", start, end)
+	}
+
+	cf.engine.mu.RLock()
+	defer cf.engine.mu.RUnlock()
+
+	return cf.scan(start, end)
+}
+
+func (cf *ColumnFamily) scan(start, end string) ([]*mt.Entry, error) {
+	stored, err := cf.SSReader.Scan(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolve(append(cf.Mempool.Scan(start, end), stored...), nil, nil, cf.Comparator, cf.engine.valueLog())
+}
+
+// Compact merges all sstables of the column family into one.
+func (cf *ColumnFamily) Compact() error {
+	cf.engine.mu.Lock()
+	defer cf.engine.mu.Unlock()
+
+	return cf.SSWriter.Compact()
+}
+
+// write logs the entry to the shared WAL under the column family name and puts it into the mempool,
+// with the timestamp the WAL ordered it by
+func (cf *ColumnFamily) write(entry *mt.Entry) error {
+	entry, err := cf.engine.separate(cf.Name, entry)
+	if err != nil {
+		return err
+	}
+
+	record, err := cf.walRecord(entry)
+	if err != nil {
+		return err
+	}
+	return cf.engine.WAL.LogOrdered([]*writeaheadlog.WriteAheadLogEntry{record}, func(timestamp int64) error {
+		return cf.Mempool.Put(entry.WrittenAt(timestamp))
+	})
+}
+
+// walRecord returns the WAL record of the entry marked with the column family name
+func (cf *ColumnFamily) walRecord(entry *mt.Entry) (*writeaheadlog.WriteAheadLogEntry, error) {
+	record, err := walRecord(entry)
+	if err != nil {
+		return nil, err
+	}
+	record.Family = []byte(cf.Name)
+	return record, nil
+}
+
+// restore puts an entry recovered from the WAL into the mempool
+func (cf *ColumnFamily) restore(key string, walEntry *writeaheadlog.WriteAheadLogEntry) error {
+	entry := mt.NewEntryAt(key, walEntry.Value, walEntry.Tombstone, walEntry.Timestamp.UnixNano(), walEntry.Expiry)
+	if walEntry.Pointer {
+		entry = mt.NewPointerEntryAt(key, walEntry.Value, walEntry.Timestamp.UnixNano(), walEntry.Expiry)
+	}
+	return cf.Mempool.Put(entry)
+}
@@ -0,0 +1,231 @@
+package engine
+
+import (
+	cfg "NoSQLDB/lib/config"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openWithColumnFamily opens an engine on the config with the column family created before restoring the WAL
+func openWithColumnFamily(t *testing.T, config *cfg.Config, name string) (*Engine, *ColumnFamily) {
+	e, err := NewEngine(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cf, err := e.CreateColumnFamily(name, cfg.ColumnFamilyConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Restore(*config); err != nil {
+		t.Fatal(err)
+	}
+	return e, cf
+}
+
+func checkFamilyGet(t *testing.T, cf *ColumnFamily, key, want string) {
+	t.Helper()
+	if value, err := cf.Get(key); err != nil || string(value) != want {
+		t.Errorf("%s.Get(%q) = %q, %v; want %q", cf.Name, key, value, err, want)
+	}
+}
+
+// TestRestoreKeyLikeFamilyRecord restores a key of the default keyspace which starts like a record of a column family would have before
+func TestRestoreKeyLikeFamilyRecord(t *testing.T) {
+	config := testConfig(t)
+	e, cf := openWithColumnFamily(t, config, "s")
+
+	key := []byte{'s', 0, 0xab, 0xcd}
+	if err := e.PutBytes(key, []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cf.Put(string(key[2:]), []byte("family value")); err != nil {
+		t.Fatal(err)
+	}
+	crash(e)
+
+	e, cf = openWithColumnFamily(t, config, "s")
+	checkGet(t, e, string(key), "v")
+	checkGet(t, e, string(key[2:]), "")
+	checkFamilyGet(t, cf, string(key[2:]), "family value")
+	checkFamilyGet(t, cf, string(key), "")
+}
+
+func TestRestoreUnknownFamily(t *testing.T) {
+	config := testConfig(t)
+	e, cf := openWithColumnFamily(t, config, "family")
+	if err := cf.Put("key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	crash(e)
+
+	e, err := NewEngine(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Restore(*config); !errors.Is(err, ErrUnknownColumnFamily) {
+		t.Errorf("Restore() without the column family = %v; want %v", err, ErrUnknownColumnFamily)
+	}
+}
+
+func TestRestoreUnregisteredIndex(t *testing.T) {
+	config := testConfig(t)
+	e := openWithCityIndex(t, config)
+	if err := e.Put("alice", []byte("alice,paris")); err != nil {
+		t.Fatal(err)
+	}
+	crash(e)
+
+	e, err := NewEngine(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Restore(*config); !errors.Is(err, ErrUnknownColumnFamily) {
+		t.Errorf("Restore() without the index = %v; want %v", err, ErrUnknownColumnFamily)
+	}
+}
+
+func TestCreateColumnFamily(t *testing.T) {
+	e := openEngine(t, testConfig(t))
+
+	for _, name := range []string{"", "a/b", ".hidden", "a\x00b"} {
+		if _, err := e.CreateColumnFamily(name, cfg.ColumnFamilyConfig{}); !errors.Is(err, ErrInvalidColumnFamilyName) {
+			t.Errorf("CreateColumnFamily(%q) = %v; want %v", name, err, ErrInvalidColumnFamilyName)
+		}
+	}
+
+	sessions, err := e.CreateColumnFamily("sessions", cfg.ColumnFamilyConfig{MemtableType: "btree", BFFalsePositiveRate: 0.01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.CreateColumnFamily("sessions", cfg.ColumnFamilyConfig{}); !errors.Is(err, ErrColumnFamilyExists) {
+		t.Errorf("CreateColumnFamily(sessions) twice = %v; want %v", err, ErrColumnFamilyExists)
+	}
+	if e.ColumnFamily("sessions") != sessions || e.ColumnFamily("audit") != nil {
+		t.Error("ColumnFamily() does not return the created column families")
+	}
+	if sessions.Config.MemtableType != "btree" || sessions.Config.BFFalsePositiveRate != 0.01 || sessions.Config.NumTables != e.Config.NumTables {
+		t.Errorf("sessions config = %+v; want the overrides on top of the engine config", sessions.Config)
+	}
+
+	// the keyspaces are independent
+	e.Put("key", []byte("default"))
+	sessions.Put("key", []byte("session"))
+	sessions.Put("deleted", []byte("session"))
+	sessions.Delete("deleted")
+	checkGet(t, e, "key", "default")
+	checkFamilyGet(t, sessions, "key", "session")
+	checkFamilyGet(t, sessions, "deleted", "")
+	checkFamilyGet(t, sessions, "absent", "")
+
+	if err := sessions.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	checkFamilyGet(t, sessions, "key", "session")
+	checkFamilyGet(t, sessions, "deleted", "")
+	checkGet(t, e, "key", "default")
+}
+
+func TestColumnFamilyRestored(t *testing.T) {
+	config := testConfig(t)
+	e, cf := openWithColumnFamily(t, config, "sessions")
+
+	cf.Put("flushed", []byte("v1"))
+	if err := cf.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	cf.Put("logged", []byte("v2"))
+	cf.Put("flushed", []byte("v3"))
+	cf.Delete("gone")
+	crash(e)
+
+	e, cf = openWithColumnFamily(t, config, "sessions")
+	checkFamilyGet(t, cf, "flushed", "v3")
+	checkFamilyGet(t, cf, "logged", "v2")
+	checkFamilyGet(t, cf, "gone", "")
+	checkGet(t, e, "logged", "")
+}
+
+// tableCount returns the number of sstables of the column family
+func tableCount(t *testing.T, cf *ColumnFamily) int {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(cf.Config.OutputDir, "usertable-*-Data.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(files)
+}
+
+// flushBatch writes the values and flushes them into a table of their own
+func flushBatch(t *testing.T, cf *ColumnFamily, values map[string]string) {
+	t.Helper()
+	for key, value := range values {
+		var err error
+		if value == "" {
+			err = cf.Delete(key)
+		} else {
+			err = cf.Put(key, []byte(value))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cf.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompactionStrategies(t *testing.T) {
+	e := openEngine(t, testConfig(t))
+	opts := cfg.ColumnFamilyConfig{CompactionThreshold: 3, CompactionStrategy: "tiered"}
+	tiered, err := e.CreateColumnFamily("tiered", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.CompactionStrategy = "full"
+	full, err := e.CreateColumnFamily("full", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Config.CompactionStrategy != "full" || tiered.Config.CompactionStrategy != "tiered" {
+		t.Fatalf("compaction strategies = %s, %s; want full, tiered", e.Config.CompactionStrategy, tiered.Config.CompactionStrategy)
+	}
+
+	old := make(map[string]string)
+	for i := 0; i < 40; i++ {
+		old[fmt.Sprintf("key%02d", i)] = strings.Repeat("old", 10)
+	}
+	for _, cf := range []*ColumnFamily{tiered, full} {
+		flushBatch(t, cf, old)
+		flushBatch(t, cf, map[string]string{"key00": "", "key01": "new"})
+		flushBatch(t, cf, map[string]string{"key02": "new"})
+	}
+
+	// the two small tables are merged on their own, the large one is left alone
+	if n := tableCount(t, tiered); n != 2 {
+		t.Errorf("tiered compaction left %d tables; want 2", n)
+	}
+	if n := tableCount(t, full); n != 1 {
+		t.Errorf("full compaction left %d tables; want 1", n)
+	}
+
+	for _, cf := range []*ColumnFamily{tiered, full} {
+		// the deletion in the merged tables still hides the value in the older one
+		checkFamilyGet(t, cf, "key00", "")
+		checkFamilyGet(t, cf, "key01", "new")
+		checkFamilyGet(t, cf, "key02", "new")
+		checkFamilyGet(t, cf, "key03", strings.Repeat("old", 10))
+	}
+
+	if err := tiered.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if n := tableCount(t, tiered); n != 1 {
+		t.Errorf("Compact() left %d tables; want 1", n)
+	}
+	checkFamilyGet(t, tiered, "key00", "")
+	checkFamilyGet(t, tiered, "key01", "new")
+	checkFamilyGet(t, tiered, "key03", strings.Repeat("old", 10))
+}
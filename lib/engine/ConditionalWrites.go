@@ -0,0 +1,63 @@
+package engine
+
+import (
+	mt "NoSQLDB/lib/memtable"
+	"bytes"
+	"fmt"
+)
+
+// CompareAndSwap sets the key to newValue only if its current value equals expected.
+// A nil expected value matches an absent (deleted or expired) key.
+// It reports whether the swap happened.
+func (e *Engine) CompareAndSwap(key string, expected, newValue []byte) (bool, error) {
+	if !e.getToken() {
+		return false, fmt.Errorf("timed out while swapping key %s", key)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.writeIfEquals(key, expected, mt.NewEntry(key, newValue, false))
+}
+
+// PutIfAbsent puts the key only if it does not exist yet and reports whether it was put.
+func (e *Engine) PutIfAbsent(key string, value []byte) (bool, error) {
+	if !e.getToken() {
+		return false, fmt.Errorf("timed out while putting key %s", key)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.writeIfEquals(key, nil, mt.NewEntry(key, value, false))
+}
+
+// DeleteIfEquals deletes the key only if its current value equals expected and reports whether it was deleted.
+func (e *Engine) DeleteIfEquals(key string, expected []byte) (bool, error) {
+	if !e.getToken() {
+		return false, fmt.Errorf("timed out while deleting key %s", key)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.writeIfEquals(key, expected, mt.NewEntry(key, nil, true))
+}
+
+// writeIfEquals writes the entry if the current value of the key equals expected.
+// The caller must hold the engine lock, so no other write can happen in between.
+func (e *Engine) writeIfEquals(key string, expected []byte, entry *mt.Entry) (bool, error) {
+	current, err := e.get(key)
+	if err != nil {
+		return false, err
+	}
+
+	if (current == nil) != (expected == nil) || !bytes.Equal(current, expected) {
+		return false, nil
+	}
+
+	if err := e.write(entry); err != nil {
+		return false, err
+	}
+	return true, nil
+}
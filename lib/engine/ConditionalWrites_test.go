@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func checkGet(t *testing.T, e *Engine, key, want string) {
+	t.Helper()
+	if value, err := e.Get(key); err != nil || string(value) != want {
+		t.Errorf("Get(%s) = %q, %v; want %q", key, value, err, want)
+	}
+}
+
+func checkSwapped(t *testing.T, operation string, swapped bool, err error, want bool) {
+	t.Helper()
+	if err != nil || swapped != want {
+		t.Errorf("%s = %v, %v; want %v", operation, swapped, err, want)
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	e := openEngine(t, testConfig(t))
+
+	swapped, err := e.PutIfAbsent("key", []byte("v1"))
+	checkSwapped(t, "PutIfAbsent(key)", swapped, err, true)
+	swapped, err = e.PutIfAbsent("key", []byte("v2"))
+	checkSwapped(t, "PutIfAbsent(key) of a present key", swapped, err, false)
+	checkGet(t, e, "key", "v1")
+
+	swapped, err = e.CompareAndSwap("key", []byte("v2"), []byte("v3"))
+	checkSwapped(t, "CompareAndSwap(key, v2) on a mismatch", swapped, err, false)
+	checkGet(t, e, "key", "v1")
+	swapped, err = e.CompareAndSwap("key", []byte("v1"), []byte("v2"))
+	checkSwapped(t, "CompareAndSwap(key, v1)", swapped, err, true)
+	checkGet(t, e, "key", "v2")
+
+	// a nil expected value only matches an absent key
+	swapped, err = e.CompareAndSwap("key", nil, []byte("v3"))
+	checkSwapped(t, "CompareAndSwap(key, nil) of a present key", swapped, err, false)
+	swapped, err = e.CompareAndSwap("absent", []byte("v1"), []byte("v3"))
+	checkSwapped(t, "CompareAndSwap(absent, v1)", swapped, err, false)
+	swapped, err = e.CompareAndSwap("absent", nil, []byte("v1"))
+	checkSwapped(t, "CompareAndSwap(absent, nil)", swapped, err, true)
+	checkGet(t, e, "absent", "v1")
+
+	swapped, err = e.DeleteIfEquals("key", []byte("v1"))
+	checkSwapped(t, "DeleteIfEquals(key, v1) on a mismatch", swapped, err, false)
+	swapped, err = e.DeleteIfEquals("key", []byte("v2"))
+	checkSwapped(t, "DeleteIfEquals(key, v2)", swapped, err, true)
+	checkGet(t, e, "key", "")
+	swapped, err = e.DeleteIfEquals("key", []byte("v2"))
+	checkSwapped(t, "DeleteIfEquals(key, v2) of a deleted key", swapped, err, false)
+	swapped, err = e.PutIfAbsent("key", []byte("v4"))
+	checkSwapped(t, "PutIfAbsent(key) of a deleted key", swapped, err, true)
+	checkGet(t, e, "key", "v4")
+}
+
+func TestConditionalWriteFails(t *testing.T) {
+	e := openEngine(t, testConfig(t))
+	if err := e.Put("key", []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+
+	// the WAL does not log empty values
+	if swapped, err := e.CompareAndSwap("key", []byte("v1"), []byte{}); swapped || err == nil {
+		t.Errorf("CompareAndSwap(key, v1, empty) = %v, %v; want an error", swapped, err)
+	}
+	checkGet(t, e, "key", "v1")
+}
+
+// TestConcurrentCompareAndSwap increments a counter from many goroutines, every increment retries until its swap happens
+func TestConcurrentCompareAndSwap(t *testing.T) {
+	e := openEngine(t, testConfig(t))
+	if err := e.Put("counter", []byte("0")); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				for {
+					current, err := e.Get("counter")
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					n, _ := strconv.Atoi(string(current))
+					swapped, err := e.CompareAndSwap("counter", current, []byte(strconv.Itoa(n+1)))
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					if swapped {
+						break
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	checkGet(t, e, "counter", "400")
+}
+
+func TestConditionalWritesRestored(t *testing.T) {
+	config := testConfig(t)
+	e := openEngine(t, config)
+
+	e.PutIfAbsent("put", []byte("v1"))
+	e.PutIfAbsent("put", []byte("v2"))
+	e.Put("swapped", []byte("v1"))
+	e.CompareAndSwap("swapped", []byte("v1"), []byte("v2"))
+	e.CompareAndSwap("swapped", []byte("v1"), []byte("v3"))
+	e.Put("deleted", []byte("v1"))
+	e.DeleteIfEquals("deleted", []byte("v1"))
+	crash(e)
+
+	e = openEngine(t, config)
+	checkGet(t, e, "put", "v1")
+	checkGet(t, e, "swapped", "v2")
+	checkGet(t, e, "deleted", "")
+}
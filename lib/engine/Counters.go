@@ -0,0 +1,97 @@
+package engine
+
+import (
+	mt "NoSQLDB/lib/memtable"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// counters are stored as 8 byte big endian two's complement integers
+const COUNTER_SIZE = 8
+
+var (
+	ErrNotACounter     = errors.New("value is not a counter")
+	ErrCounterOverflow = errors.New("counter overflow")
+)
+
+func EncodeCounter(value int64) []byte {
+	data := make([]byte, COUNTER_SIZE)
+	binary.BigEndian.PutUint64(data, uint64(value))
+	return data
+}
+
+func DecodeCounter(data []byte) (int64, error) {
+	if len(data) != COUNTER_SIZE {
+		return 0, ErrNotACounter
+	}
+	return int64(binary.BigEndian.Uint64(data)), nil
+}
+
+// Incr atomically adds delta to the counter stored under the key and returns the new value.
+// An absent key counts as 0.
+func (e *Engine) Incr(key string, delta int64) (int64, error) {
+	if !e.getToken() {
+		return 0, fmt.Errorf("timed out while incrementing key %s", key)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.update(key, func(current int64) (int64, bool) {
+		updated := current + delta
+		return updated, !(delta > 0 && updated < current) && !(delta < 0 && updated > current)
+	})
+}
+
+// Decr atomically subtracts delta from the counter stored under the key and returns the new value.
+func (e *Engine) Decr(key string, delta int64) (int64, error) {
+	if !e.getToken() {
+		return 0, fmt.Errorf("timed out while decrementing key %s", key)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	// delta is not negated, since the negation of the smallest delta overflows even if the result does not
+	return e.update(key, func(current int64) (int64, bool) {
+		updated := current - delta
+		return updated, !(delta > 0 && updated > current) && !(delta < 0 && updated < current)
+	})
+}
+
+// GetCounter returns the value of the counter stored under the key, 0 if it is absent.
+func (e *Engine) GetCounter(key string) (int64, error) {
+	value, err := e.Get(key)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	return DecodeCounter(value)
+}
+
+// update reads the counter and writes the value apply computes from it, apply reports false if the value overflowed.
+// The caller must hold the engine lock.
+func (e *Engine) update(key string, apply func(current int64) (int64, bool)) (int64, error) {
+	var current int64
+	value, err := e.get(key)
+	if err != nil {
+		return 0, err
+	}
+	if value != nil {
+		current, err = DecodeCounter(value)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	updated, ok := apply(current)
+	if !ok {
+		return 0, ErrCounterOverflow
+	}
+
+	err = e.write(mt.NewEntry(key, EncodeCounter(updated), false))
+	if err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+)
+
+func checkCounter(t *testing.T, operation string, value int64, err error, want int64) {
+	t.Helper()
+	if err != nil || value != want {
+		t.Errorf("%s = %d, %v; want %d", operation, value, err, want)
+	}
+}
+
+func TestCounters(t *testing.T) {
+	e := openEngine(t, testConfig(t))
+
+	value, err := e.GetCounter("counter")
+	checkCounter(t, "GetCounter(counter) of an absent key", value, err, 0)
+	value, err = e.Incr("counter", 5)
+	checkCounter(t, "Incr(counter, 5)", value, err, 5)
+	value, err = e.Decr("counter", 7)
+	checkCounter(t, "Decr(counter, 7)", value, err, -2)
+	value, err = e.Incr("counter", -3)
+	checkCounter(t, "Incr(counter, -3)", value, err, -5)
+	value, err = e.Decr("counter", -5)
+	checkCounter(t, "Decr(counter, -5)", value, err, 0)
+	value, err = e.GetCounter("counter")
+	checkCounter(t, "GetCounter(counter)", value, err, 0)
+
+	value, err = e.Decr("absent", 1)
+	checkCounter(t, "Decr(absent, 1)", value, err, -1)
+}
+
+func TestNotACounter(t *testing.T) {
+	e := openEngine(t, testConfig(t))
+	if err := e.Put("key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := e.Incr("key", 1); !errors.Is(err, ErrNotACounter) {
+		t.Errorf("Incr(key) of a string = %v; want %v", err, ErrNotACounter)
+	}
+	if _, err := e.GetCounter("key"); !errors.Is(err, ErrNotACounter) {
+		t.Errorf("GetCounter(key) of a string = %v; want %v", err, ErrNotACounter)
+	}
+	checkGet(t, e, "key", "value")
+}
+
+func TestCounterOverflow(t *testing.T) {
+	e := openEngine(t, testConfig(t))
+
+	e.Incr("max", math.MaxInt64)
+	if _, err := e.Incr("max", 1); !errors.Is(err, ErrCounterOverflow) {
+		t.Errorf("Incr(max, 1) = %v; want %v", err, ErrCounterOverflow)
+	}
+	if _, err := e.Decr("max", -1); !errors.Is(err, ErrCounterOverflow) {
+		t.Errorf("Decr(max, -1) = %v; want %v", err, ErrCounterOverflow)
+	}
+
+	e.Incr("min", math.MinInt64)
+	if _, err := e.Decr("min", 1); !errors.Is(err, ErrCounterOverflow) {
+		t.Errorf("Decr(min, 1) = %v; want %v", err, ErrCounterOverflow)
+	}
+	if _, err := e.Incr("min", -1); !errors.Is(err, ErrCounterOverflow) {
+		t.Errorf("Incr(min, -1) = %v; want %v", err, ErrCounterOverflow)
+	}
+
+	// a failed update leaves the counter as it was
+	value, err := e.GetCounter("max")
+	checkCounter(t, "GetCounter(max)", value, err, math.MaxInt64)
+	value, err = e.GetCounter("min")
+	checkCounter(t, "GetCounter(min)", value, err, math.MinInt64)
+
+	// subtracting the smallest delta is fine as long as the result fits
+	e.Decr("negative", 1)
+	value, err = e.Decr("negative", math.MinInt64)
+	checkCounter(t, "Decr(negative, MinInt64)", value, err, math.MaxInt64)
+	if _, err := e.Decr("zero", math.MinInt64); !errors.Is(err, ErrCounterOverflow) {
+		t.Errorf("Decr(zero, MinInt64) = %v; want %v", err, ErrCounterOverflow)
+	}
+}
+
+func TestConcurrentIncr(t *testing.T) {
+	e := openEngine(t, testConfig(t))
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if _, err := e.Incr("counter", 2); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := e.Decr("counter", 1); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	value, err := e.GetCounter("counter")
+	checkCounter(t, "GetCounter(counter)", value, err, 400)
+}
+
+func TestCountersRestored(t *testing.T) {
+	config := testConfig(t)
+	e := openEngine(t, config)
+
+	e.Incr("counter", 10)
+	e.Decr("counter", 3)
+	e.Incr("other", -4)
+	crash(e)
+
+	e = openEngine(t, config)
+	value, err := e.GetCounter("counter")
+	checkCounter(t, "GetCounter(counter)", value, err, 7)
+	value, err = e.GetCounter("other")
+	checkCounter(t, "GetCounter(other)", value, err, -4)
+	value, err = e.Incr("counter", 1)
+	checkCounter(t, "Incr(counter, 1)", value, err, 8)
+}
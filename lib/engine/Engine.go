@@ -2,23 +2,57 @@ package engine
 
 import (
 	cache "NoSQLDB/lib/cache"
+	"NoSQLDB/lib/comparator"
 	cfg "NoSQLDB/lib/config"
 	mt "NoSQLDB/lib/memtable"
 	tokenbucket "NoSQLDB/lib/token-bucket"
+	"NoSQLDB/lib/utils"
+	valuelog "NoSQLDB/lib/value-log"
 	writeaheadlog "NoSQLDB/lib/write-ahead-log"
+	"errors"
 	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
 )
 
 type Engine struct {
+	mu          sync.RWMutex // shared by reads and plain writes, held alone by conditional writes so they are atomic
 	WAL         *writeaheadlog.WriteAheadLog
 	Mempool     *mt.Mempool
 	TokenBucket *tokenbucket.TokenBucket
 	Cache       *cache.Cache
 	SSReader    *mt.SSReader
+	SSWriter    *mt.SSWriter
+	Versions    *mt.VersionPolicy // nil when versioning is disabled
+	Merger      mt.MergeOperator  // nil until a merge operator is registered
+	Comparator  comparator.Comparator
+	ValueLog    *valuelog.ValueLog // nil when every value is kept inline
+	BlockCache  *mt.BlockCache     // nil when sstable blocks are not cached
+
+	Config         *cfg.Config
+	ColumnFamilies map[string]*ColumnFamily
+	Indexes        map[string]*Index
+
+	cleanShutdown   bool            // the previous run was closed, so its WAL was discarded instead of replayed
+	compactionHooks map[string]bool // names of the registered compaction hooks
+
+	// a read only caches what it found in the sstables if no write invalidated the cache since the read started
+	cacheMu sync.Mutex
+	writes  uint64 // number of writes which invalidated the cache
 }
 
+var ErrCompactionHookExists = errors.New("compaction hook already registered")
+
 func NewEngine(config *cfg.Config) (*Engine, error) {
 
+	// everything the log of a cleanly closed engine holds is already in the sstables
+	clean, err := writeaheadlog.DiscardIfClean(config.WALDir)
+	if err != nil {
+		return nil, err
+	}
+
 	wal, err := writeaheadlog.NewWriteAheadLog(config.WALDir, config.WALSegmentSize)
 
 	if err != nil {
@@ -26,22 +60,30 @@ func NewEngine(config *cfg.Config) (*Engine, error) {
 		return nil, err
 	}
 
-	writer, err := mt.NewSSWriter(config.OutputDir, config.IndexStride, config.SummaryStride, config.BFExpectedElements, config.BFFalsePositiveRate)
+	retention, _ := time.ParseDuration(config.VersionRetention)
+	versions := mt.NewVersionPolicy(config.VersionsToKeep, retention)
+
+	cmp, err := keyComparator(config)
 	if err != nil {
-		fmt.Println("error creating ss writer")
 		return nil, err
 	}
 
-	mempool, err := mt.NewMempool(
-		config.NumTables,
-		config.MemtableSize,
-		config.SkipListMaxLevel,
-		config.BTreeMinDegree,
-		writer,
-		config.MemtableType)
+	var vlog *valuelog.ValueLog
+	if config.ValueThreshold > 0 {
+		vlog, err = valuelog.NewValueLog(config.ValueLogDir, config.ValueLogFileSize)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the column families share the block cache, their tables are told apart by their directories
+	var blocks *mt.BlockCache
+	if config.BlockCacheSize > 0 {
+		blocks = mt.NewBlockCache(config.BlockCacheSize)
+	}
 
+	mempool, reader, writer, err := newStorage(config, versions, cmp, vlog, blocks)
 	if err != nil {
-		fmt.Println("Error creating Mempool")
 		return nil, err
 	}
 
@@ -50,20 +92,102 @@ func NewEngine(config *cfg.Config) (*Engine, error) {
 		config.TokenBucketRate,
 		config.FillInterval)
 
-	cache := cache.NewCache(config.CacheSize)
+	cache, err := cache.NewCacheWithPolicy(config.CacheSize, config.CacheSizeBytes, config.CacheShards, config.CachePolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Engine{
+		WAL:            wal,
+		Mempool:        mempool,
+		TokenBucket:    tokenBucket,
+		Cache:          cache,
+		SSReader:       reader,
+		SSWriter:       writer,
+		Versions:       versions,
+		Comparator:     cmp,
+		ValueLog:       vlog,
+		BlockCache:     blocks,
+		Config:         config,
+		ColumnFamilies: make(map[string]*ColumnFamily),
+		Indexes:        make(map[string]*Index),
+		cleanShutdown:  clean,
+
+		compactionHooks: make(map[string]bool),
+	}
 
-	reader, err := mt.NewSSReader(config.OutputDir)
+	for name, cfConfig := range config.ColumnFamilies {
+		if _, err := e.CreateColumnFamily(name, cfConfig); err != nil {
+			return nil, err
+		}
+	}
 
-	return &Engine{
-		WAL:         wal,
-		Mempool:     mempool,
-		TokenBucket: tokenBucket,
-		Cache:       cache,
-		SSReader:    reader,
-	}, err
+	return e, nil
 }
 
-func (e Engine) Restore(cfg cfg.Config) error {
+// keyComparator returns the comparator selected in the config
+func keyComparator(config *cfg.Config) (comparator.Comparator, error) {
+	cmp, ok := comparator.ByName(config.Comparator)
+	if !ok {
+		return nil, fmt.Errorf("unknown comparator %q", config.Comparator)
+	}
+	return cmp, nil
+}
+
+// newStorage creates the mempool and the sstable reader and writer described by the config.
+// Separated values are resolved from the value log and sstable blocks are cached in the block cache, if there are ones.
+func newStorage(config *cfg.Config, versions *mt.VersionPolicy, cmp comparator.Comparator, vlog *valuelog.ValueLog, blocks *mt.BlockCache) (*mt.Mempool, *mt.SSReader, *mt.SSWriter, error) {
+	writer, err := mt.NewSSWriter(
+		config.OutputDir,
+		config.IndexStride,
+		config.SummaryStride,
+		config.BFExpectedElements,
+		config.BFFalsePositiveRate,
+		config.CompactionThreshold,
+		versions,
+		cmp)
+	if err != nil {
+		fmt.Println("error creating ss writer")
+		return nil, nil, nil, err
+	}
+
+	mempool, err := mt.NewMempool(
+		config.NumTables,
+		config.MemtableSize,
+		config.SkipListMaxLevel,
+		config.BTreeMinDegree,
+		writer,
+		config.MemtableType,
+		versions,
+		cmp)
+
+	if err != nil {
+		fmt.Println("Error creating Mempool")
+		return nil, nil, nil, err
+	}
+
+	reader, err := mt.NewSSReader(config.OutputDir, cmp)
+	if blocks != nil {
+		reader.SetBlockCache(blocks)
+	}
+	reader.SetTableTombstones(writer.TableTombstones())
+	reader.SetTablesLock(writer.TablesLock())
+
+	writer.SetCompactionStrategy(config.CompactionStrategy)
+	mempool.SetTableSizeBytes(config.MemtableSizeBytes)
+	if vlog != nil {
+		mempool.SetValueLog(vlog)
+		writer.SetValueLog(vlog)
+	}
+
+	return mempool, reader, writer, err
+}
+
+func (e *Engine) Restore(cfg cfg.Config) error {
+	if e.cleanShutdown {
+		return nil
+	}
+
 	walreader, err := writeaheadlog.NewWALReader(
 		cfg.WALDir,
 		cfg.WALSegmentSize,
@@ -80,15 +204,41 @@ func (e Engine) Restore(cfg cfg.Config) error {
 		return err
 	}
 
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	for _, walEntry := range walEntries {
-		entry := mt.NewEntry(string(walEntry.Key), walEntry.Value, walEntry.Tombstone)
+		if len(walEntry.Family) > 0 {
+			cf, err := e.columnFamilyNamed(string(walEntry.Family))
+			if err != nil {
+				return err
+			}
+			if err := cf.restore(string(walEntry.Key), walEntry); err != nil {
+				return err
+			}
+			continue
+		}
+		if walEntry.RangeDelete {
+			e.Mempool.DeleteRange(mt.NewRangeTombstoneAt(string(walEntry.Key), string(walEntry.Value), walEntry.Timestamp.UnixNano()))
+			continue
+		}
+		// every recovered entry has its own key buffer
+		key := utils.BytesToString(walEntry.Key)
+		entry := mt.NewEntryAt(key, walEntry.Value, walEntry.Tombstone, walEntry.Timestamp.UnixNano(), walEntry.Expiry)
+		if walEntry.Merge {
+			entry = mt.NewMergeEntryAt(key, walEntry.Value, walEntry.Timestamp.UnixNano())
+		} else if walEntry.Pointer {
+			entry = mt.NewPointerEntryAt(key, walEntry.Value, walEntry.Timestamp.UnixNano(), walEntry.Expiry)
+		}
+
+		// index entries were logged along with the entry and are restored by their own column family
 		e.Mempool.Put(entry)
 	}
 
 	return nil
 }
 
-func (e Engine) getToken() bool {
+func (e *Engine) getToken() bool {
 	return e.TokenBucket.RemoveToken()
 }
 
@@ -97,51 +247,347 @@ func (e *Engine) Put(key string, value []byte) error {
 		return fmt.Errorf("timed out while putting key %s", key)
 	}
 
-	err := e.WAL.Log([]byte(key), value, writeaheadlog.WAL_PUT)
+	unlock := e.lockWrite()
+	defer unlock()
+
+	return e.write(mt.NewEntry(key, value, false))
+}
+
+// PutBytes puts a key given as a byte slice.
+// The key is copied once here, since the memtables keep it, the caller may reuse the slice afterwards.
+func (e *Engine) PutBytes(key, value []byte) error {
+	if !e.getToken() {
+		return fmt.Errorf("timed out while putting key %q", key)
+	}
+
+	unlock := e.lockWrite()
+	defer unlock()
+
+	return e.write(mt.NewEntry(string(key), value, false))
+}
+
+// PutWithTTL puts a key which is treated as absent once the ttl runs out.
+// Expired keys are physically removed during compaction.
+func (e *Engine) PutWithTTL(key string, value []byte, ttl time.Duration) error {
+	if !e.getToken() {
+		return fmt.Errorf("timed out while putting key %s", key)
+	}
+
+	unlock := e.lockWrite()
+	defer unlock()
+
+	return e.write(mt.NewExpiringEntry(key, value, ttl))
+}
+
+// lockWrite locks the engine for a plain write and returns the function unlocking it.
+// Plain writes share the lock, unless an index has to read the value they replace, which another write could change meanwhile.
+func (e *Engine) lockWrite() func() {
+	e.mu.RLock()
+	if len(e.Indexes) == 0 {
+		return e.mu.RUnlock
+	}
+	e.mu.RUnlock()
+
+	e.mu.Lock()
+	return e.mu.Unlock
+}
+
+// write logs the entry to the WAL and puts it into the mempool.
+// The index entries derived from it are logged in the same write, so they are restored along with it.
+// The entry is written with the timestamp the WAL ordered it by.
+func (e *Engine) write(entry *mt.Entry) error {
+	written := entry
+	entry, err := e.separate("", entry)
+	if err != nil {
+		return err
+	}
+
+	record, err := walRecord(entry)
+	if err != nil {
+		return err
+	}
+	records := []*writeaheadlog.WriteAheadLogEntry{record}
 
+	indexed, err := e.indexWrites(written)
 	if err != nil {
 		return err
 	}
+	for _, write := range indexed {
+		record, err := write.cf.walRecord(write.entry)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
 
-	entry := mt.NewEntry(key, value, false)
+	return e.WAL.LogOrdered(records, func(timestamp int64) error {
+		err := e.Mempool.Put(entry.WrittenAt(timestamp))
+		if err != nil {
+			return err
+		}
+		// the mempool shadows the cache until the entry is flushed, the next read from the sstables caches it again
+		e.invalidate(entry.Key())
 
-	return e.Mempool.Put(entry)
+		for _, write := range indexed {
+			if err := write.cf.Mempool.Put(write.entry.WrittenAt(timestamp)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
-func (e *Engine) testPut(key string, value []byte) error {
-	err := e.WAL.Log([]byte(key), value, writeaheadlog.WAL_PUT)
+// invalidate drops the key from the cache once a write to it reached the mempool
+func (e *Engine) invalidate(key string) {
+	e.cacheMu.Lock()
+	defer e.cacheMu.Unlock()
+
+	e.writes++
+	e.Cache.Delete(key)
+}
 
+// cachedWrites returns the number of writes which invalidated the cache so far, taken before a read starts
+func (e *Engine) cachedWrites() uint64 {
+	e.cacheMu.Lock()
+	defer e.cacheMu.Unlock()
+
+	return e.writes
+}
+
+// cachePut caches an entry read from the sstables, unless a write invalidated the cache since the read started.
+// The entry could be older than that write, which is already in the mempool.
+func (e *Engine) cachePut(writes uint64, entry *mt.Entry) {
+	e.cacheMu.Lock()
+	defer e.cacheMu.Unlock()
+
+	if e.writes == writes {
+		e.Cache.Put(entry)
+	}
+}
+
+// walRecord returns the WAL record of the entry in the default keyspace
+func walRecord(entry *mt.Entry) (*writeaheadlog.WriteAheadLogEntry, error) {
+	operation := writeaheadlog.WAL_PUT
+	value := entry.Value()
+	if entry.Pointer() {
+		operation = writeaheadlog.WAL_POINTER
+	} else if entry.Tombstone() {
+		operation = writeaheadlog.WAL_DELETE
+	} else if entry.Merge() {
+		// the WAL holds the raw operand, it is merged again when restored
+		operation = writeaheadlog.WAL_MERGE
+		operands, err := entry.Operands()
+		if err != nil {
+			return nil, err
+		}
+		value = operands[0]
+	}
+
+	record, err := writeaheadlog.NewEntry(entry.KeyBytes(), value, operation)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	// replaying the record restores the same version, instead of a copy of it written at another time
+	record.Timestamp = time.Unix(0, entry.Timestamp())
+	record.Expiry = entry.Expiry()
+	return record, nil
+}
 
-	entry := mt.NewEntry(key, value, false)
+func (e *Engine) testPut(key string, value []byte) error {
+	unlock := e.lockWrite()
+	defer unlock()
 
-	return e.Mempool.Put(entry)
+	return e.write(mt.NewEntry(key, value, false))
 }
 
 func (e *Engine) Get(key string) ([]byte, error) {
 	if !e.getToken() {
 		return nil, fmt.Errorf("timed out while getting key %s", key)
 	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.get(key)
+}
+
+// GetBytes gets a key given as a byte slice without copying it.
+func (e *Engine) GetBytes(key []byte) ([]byte, error) {
+	if !e.getToken() {
+		return nil, fmt.Errorf("timed out while getting key %q", key)
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.get(utils.BytesToString(key))
+}
+
+func (e *Engine) get(key string) ([]byte, error) {
+	writes := e.cachedWrites()
 	value, err := e.Mempool.Get(key)
 
 	if value != nil && err == nil {
-		return value.Value(), nil
+		if value.Merge() {
+			return e.getMerged(key)
+		}
+		if value.Expired() || mt.IsCovered(e.Comparator, e.Mempool.RangeTombstones(), value) {
+			return nil, nil
+		}
+		return e.valueOf(value)
 	}
 
-	value = e.Cache.Get(key)
-	if value != nil && err == nil {
-		return value.Value(), nil
+	if cached := e.Cache.Get(key); cached != nil {
+		if cached.Tombstone() || cached.Expired() {
+			return nil, nil
+		}
+		return cached.Value(), nil
 	}
 
 	value, err = e.SSReader.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		e.cacheAbsent(writes, key)
+		return nil, nil
+	}
 
-	if value != nil && err == nil {
-		return value.Value(), nil
+	if value.Merge() {
+		return e.getMerged(key)
+	}
+	tombstones, err := e.rangeTombstones()
+	if err != nil {
+		return nil, err
+	}
+	// deleted keys are cached as tombstones, so reading them again doesn't go to the sstables
+	if value.Tombstone() || value.Expired() || mt.IsCovered(e.Comparator, tombstones, value) {
+		e.cacheAbsent(writes, key)
+		return nil, nil
 	}
 
-	return nil, err
+	resolved, err := value.Resolve(e.valueLog())
+	if err != nil {
+		return nil, err
+	}
+	e.cachePut(writes, resolved)
+	return resolved.Value(), nil
+}
+
+// cacheAbsent caches the key as deleted.
+// The key is copied, since the key passed to GetBytes is a buffer of the caller which may be reused.
+func (e *Engine) cacheAbsent(writes uint64, key string) {
+	e.cachePut(writes, mt.NewEntry(strings.Clone(key), nil, true))
+}
+
+// History returns the retained versions of the key, newest first.
+// Deletions are included as tombstone entries.
+// Overwritten versions whose values were collected from the value log are left out.
+func (e *Engine) History(key string) ([]*mt.Entry, error) {
+	if !e.getToken() {
+		return nil, fmt.Errorf("timed out while getting history of key %s", key)
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	versions, err := e.history(key)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := make([]*mt.Entry, 0, len(versions))
+	for i, version := range versions {
+		version, err := version.Resolve(e.valueLog())
+		if i > 0 && errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, version)
+	}
+	return resolved, nil
+}
+
+func (e *Engine) history(key string) ([]*mt.Entry, error) {
+	versions := e.Mempool.History(key)
+
+	stored, err := e.SSReader.History(key)
+	if err != nil {
+		return nil, err
+	}
+	versions = append(versions, stored...)
+
+	mt.SortVersions(versions)
+	return e.Versions.Retain(mt.DedupVersions(versions)), nil
+}
+
+// GetAt returns the value the key held at the given point in time.
+func (e *Engine) GetAt(key string, timestamp time.Time) ([]byte, error) {
+	if !e.getToken() {
+		return nil, fmt.Errorf("timed out while getting key %s", key)
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	versions, err := e.history(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// only range deletions issued up to the given time hide older versions
+	tombstones, err := e.rangeTombstones()
+	if err != nil {
+		return nil, err
+	}
+	var issued []*mt.RangeTombstone
+	for _, rt := range tombstones {
+		if rt.Timestamp() <= timestamp.UnixNano() {
+			issued = append(issued, rt)
+		}
+	}
+
+	for _, version := range versions {
+		if version.Timestamp() <= timestamp.UnixNano() {
+			if version.Tombstone() || version.ExpiredAt(timestamp.UnixNano()) || mt.IsCovered(e.Comparator, issued, version) {
+				return nil, nil
+			}
+			return e.valueOf(version)
+		}
+	}
+
+	return nil, nil
+}
+
+// RegisterCompactionHook adds a hook which derives entries while the sstables are compacted.
+// Every hook is registered under its own name, registering a name again fails with ErrCompactionHookExists.
+func (e *Engine) RegisterCompactionHook(name string, hook mt.CompactionHook) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.compactionHooks[name] {
+		return fmt.Errorf("%w: %s", ErrCompactionHookExists, name)
+	}
+	e.compactionHooks[name] = true
+
+	// derived entries replace the versions of their keys in the sstables
+	e.SSWriter.AddCompactionHook(func(live []*mt.Entry) []*mt.Entry {
+		derived := hook(live)
+		for _, entry := range derived {
+			e.invalidate(entry.Key())
+		}
+		return derived
+	})
+	return nil
+}
+
+// Compact merges all sstables into one, dropping expired and deleted entries.
+func (e *Engine) Compact() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.SSWriter.Compact()
 }
 
 /*
@@ -170,13 +616,71 @@ func (e *Engine) Delete(key string) error {
 	if !e.getToken() {
 		return fmt.Errorf("timed out while deleting key %s", key)
 	}
-	err := e.WAL.Log([]byte(key), nil, writeaheadlog.WAL_DELETE)
 
+	unlock := e.lockWrite()
+	defer unlock()
+
+	return e.write(mt.NewEntry(key, nil, true))
+}
+
+// DeleteBytes deletes a key given as a byte slice.
+// The key is copied once here, so the caller may reuse the slice afterwards.
+func (e *Engine) DeleteBytes(key []byte) error {
+	if !e.getToken() {
+		return fmt.Errorf("timed out while deleting key %q", key)
+	}
+
+	unlock := e.lockWrite()
+	defer unlock()
+
+	return e.write(mt.NewEntry(string(key), nil, true))
+}
+
+// DeleteRange deletes every key in [start, end).
+// Covered keys are hidden on reads and physically removed during compaction.
+func (e *Engine) DeleteRange(start, end string) error {
+	if !e.getToken() {
+		return fmt.Errorf("timed out while deleting range [%s, %s)", start, end)
+	}
+
+	if e.Comparator.Compare(start, end) >= 0 {
+		return fmt.Errorf("invalid range [%s, %s)", start, end)
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	record, err := writeaheadlog.NewEntry([]byte(start), []byte(end), writeaheadlog.WAL_RANGE_DELETE)
 	if err != nil {
 		return err
 	}
 
-	return e.Mempool.Delete(key)
+	return e.WAL.LogOrdered([]*writeaheadlog.WriteAheadLogEntry{record}, func(timestamp int64) error {
+		if err := e.Mempool.DeleteRange(mt.NewRangeTombstoneAt(start, end, timestamp)); err != nil {
+			return err
+		}
+		// the cache is not ordered, so the covered keys can't be found in it
+		e.invalidateAll()
+		return nil
+	})
+}
+
+// invalidateAll clears the cache once a write to keys which can't be looked up in it reached the mempool
+func (e *Engine) invalidateAll() {
+	e.cacheMu.Lock()
+	defer e.cacheMu.Unlock()
+
+	e.writes++
+	e.Cache.Clear()
+}
+
+// rangeTombstones returns the range deletions held in memory and in sstables.
+func (e *Engine) rangeTombstones() ([]*mt.RangeTombstone, error) {
+	stored, err := e.SSReader.RangeTombstones()
+	if err != nil {
+		return nil, err
+	}
+	return append(e.Mempool.RangeTombstones(), stored...), nil
 }
 
 /*
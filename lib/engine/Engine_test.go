@@ -0,0 +1,400 @@
+package engine
+
+import (
+	cfg "NoSQLDB/lib/config"
+	mt "NoSQLDB/lib/memtable"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testConfig(t *testing.T) *cfg.Config {
+	dir := t.TempDir()
+	config := cfg.GetDefaultConfig()
+	config.WALDir = dir + "/wal/"
+	config.OutputDir = dir + "/sstable/"
+	config.ValueLogDir = dir + "/vlog/"
+	config.WALSegmentSize = 64 // records span several segments
+	config.TokenBucketSize = 1 << 30
+	return config
+}
+
+// openEngine opens an engine on the config and restores its WAL
+func openEngine(t *testing.T, config *cfg.Config) *Engine {
+	e, err := NewEngine(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Restore(*config); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// crash drops the engine without closing it, only the WAL segment it writes to is closed
+func crash(e *Engine) {
+	e.WAL.CurrentFile.Close()
+}
+
+// TestGetBytesReusedBuffer reuses the key buffer after a miss was cached, the cached key must not change with it
+func TestGetBytesReusedBuffer(t *testing.T) {
+	e, err := NewEngine(testConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffer := []byte("key1")
+	if value, err := e.GetBytes(buffer); err != nil || value != nil {
+		t.Fatalf("GetBytes(key1) = %q, %v; want nil", value, err)
+	}
+	copy(buffer, "key2")
+
+	// the write evicts the cached miss of key1
+	if err := e.Put("key1", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+	if e.Cache.Size() != 0 {
+		t.Errorf("Cache.Size() = %d after the write; want 0", e.Cache.Size())
+	}
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string]string{"key1": "value", "key2": ""} {
+		if value, err := e.GetBytes([]byte(key)); err != nil || string(value) != want {
+			t.Errorf("GetBytes(%s) = %q, %v; want %q", key, value, err, want)
+		}
+	}
+}
+
+// TestPutBytesReusedBuffer reuses the key buffer after every write, the written keys must not change with it
+func TestPutBytesReusedBuffer(t *testing.T) {
+	config := testConfig(t)
+	e := openEngine(t, config)
+
+	buffer := []byte("key1")
+	if err := e.PutBytes(buffer, []byte("v1")); err != nil {
+		t.Fatal(err)
+	}
+	copy(buffer, "key2")
+	if err := e.PutBytes(buffer, []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	copy(buffer, "key3")
+	if err := e.PutBytes(buffer, []byte("v3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.DeleteBytes(buffer); err != nil {
+		t.Fatal(err)
+	}
+	copy(buffer, "key9")
+
+	want := map[string]string{"key1": "v1", "key2": "v2", "key3": "", "key9": ""}
+	for key, value := range want {
+		checkGet(t, e, key, value)
+	}
+
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	copy(buffer, "key0")
+	for key, value := range want {
+		checkGet(t, e, key, value)
+	}
+
+	e.PutBytes(buffer, []byte("v0"))
+	copy(buffer, "key8")
+	crash(e)
+	e = openEngine(t, config)
+	checkGet(t, e, "key0", "v0")
+	checkGet(t, e, "key8", "")
+}
+
+// TestConcurrentWriters writes from many goroutines into concurrent skip list memtables small enough to be flushed,
+// run it with -race
+func TestConcurrentWriters(t *testing.T) {
+	config := testConfig(t)
+	config.MemtableType = "concurrent_skip_list"
+	config.MemtableSize = 50
+	e, err := NewEngine(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for writer := 0; writer < 8; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("writer%d-key%03d", writer, i)
+				if err := e.Put(key, []byte(key)); err != nil {
+					t.Error(err)
+					return
+				}
+				if i%10 == 0 {
+					if err := e.Delete(key); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+				if value, err := e.Get(key); err != nil || (i%10 != 0 && string(value) != key) {
+					t.Errorf("Get(%s) = %q, %v", key, value, err)
+					return
+				}
+			}
+		}(writer)
+	}
+	wg.Wait()
+
+	for writer := 0; writer < 8; writer++ {
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("writer%d-key%03d", writer, i)
+			want := key
+			if i%10 == 0 {
+				want = ""
+			}
+			if value, err := e.Get(key); err != nil || string(value) != want {
+				t.Errorf("Get(%s) = %q, %v; want %q", key, value, err, want)
+			}
+		}
+	}
+}
+
+// TestConcurrentWritesOrdered overwrites the same keys from many goroutines,
+// the value read, the newest version and the value restored from the WAL have to come from the same write
+func TestConcurrentWritesOrdered(t *testing.T) {
+	config := testConfig(t)
+	config.MemtableType = "concurrent_skip_list"
+	config.MemtableSize = 30
+	e := openEngine(t, config)
+
+	keys := []string{"a", "b", "c", "d"}
+	var wg sync.WaitGroup
+	for writer := 0; writer < 8; writer++ {
+		wg.Add(1)
+		go func(writer int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				value := fmt.Sprintf("writer%d-%02d", writer, i)
+				if err := e.Put(keys[i%len(keys)], []byte(value)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(writer)
+	}
+	wg.Wait()
+
+	values := make(map[string]string)
+	for _, key := range keys {
+		value, err := e.Get(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values[key] = string(value)
+		if versions, err := e.History(key); err != nil || len(versions) == 0 || string(versions[0].Value()) != string(value) {
+			t.Errorf("History(%s) = %v, %v; want the read value %q first", key, versions, err, value)
+		}
+	}
+	crash(e)
+
+	e = openEngine(t, config)
+	for key, value := range values {
+		checkGet(t, e, key, value)
+	}
+}
+
+// TestWritesStampedInLogOrder writes an entry created before another write to its key was logged, as a concurrent writer may.
+// It is written last, so it stays the value of the key once the sstables are compacted.
+func TestWritesStampedInLogOrder(t *testing.T) {
+	e := openEngine(t, testConfig(t))
+
+	created := mt.NewEntry("key", []byte("second"), false)
+	e.Put("key", []byte("first"))
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.write(created); err != nil {
+		t.Fatal(err)
+	}
+	checkGet(t, e, "key", "second")
+	checkHistory(t, e, "key", "second", "first")
+
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	checkGet(t, e, "key", "second")
+}
+
+// TestCacheSkipsOverwrittenRead caches nothing a read found before a write to the key came in
+func TestCacheSkipsOverwrittenRead(t *testing.T) {
+	e := openEngine(t, testConfig(t))
+	e.Put("key", []byte("old"))
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a read found the old value in the sstables, then the key is written before the read caches it
+	writes := e.cachedWrites()
+	e.Put("key", []byte("new"))
+	e.cachePut(writes, mt.NewEntry("key", []byte("old"), false))
+
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	checkGet(t, e, "key", "new")
+}
+
+func checkHistory(t *testing.T, e *Engine, key string, want ...string) []*mt.Entry {
+	t.Helper()
+	versions, err := e.History(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []string
+	for _, version := range versions {
+		values = append(values, string(version.Value()))
+	}
+	if fmt.Sprint(values) != fmt.Sprint(want) {
+		t.Errorf("History(%s) = %q; want %q", key, values, want)
+	}
+	return versions
+}
+
+func checkGetAt(t *testing.T, e *Engine, key string, at time.Time, want string) {
+	t.Helper()
+	if value, err := e.GetAt(key, at); err != nil || string(value) != want {
+		t.Errorf("GetAt(%s, %v) = %q, %v; want %q", key, at, value, err, want)
+	}
+}
+
+// TestVersionsAfterRestore replays the WAL over the flushed versions, they must not be duplicated
+func TestVersionsAfterRestore(t *testing.T) {
+	config := testConfig(t)
+	config.VersionsToKeep = 10
+	e := openEngine(t, config)
+
+	before := time.Now()
+	for _, value := range []string{"v1", "v2"} {
+		if err := e.Put("key", []byte(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	versions := checkHistory(t, e, "key", "v2", "v1")
+	first := time.Unix(0, versions[1].Timestamp())
+	checkGetAt(t, e, "key", before, "")
+	checkGetAt(t, e, "key", first, "v1")
+
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	crash(e)
+
+	e = openEngine(t, config)
+	restored := checkHistory(t, e, "key", "v2", "v1")
+	for i := 0; i < len(restored) && i < len(versions); i++ {
+		if restored[i].Timestamp() != versions[i].Timestamp() {
+			t.Errorf("version %d restored at %d; want %d", i, restored[i].Timestamp(), versions[i].Timestamp())
+		}
+	}
+	checkGetAt(t, e, "key", first, "v1")
+
+	// the replayed copies are flushed into a second table, compaction keeps one of each
+	if err := e.Delete("key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	checkHistory(t, e, "key", "", "v2", "v1")
+	if err := e.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	checkHistory(t, e, "key", "", "v2", "v1")
+	checkGetAt(t, e, "key", first, "v1")
+	checkGetAt(t, e, "key", time.Now(), "")
+}
+
+// putAll writes every key with its name as the value
+func putAll(t *testing.T, e *Engine, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		if err := e.Put(key, []byte(key)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	config := testConfig(t)
+	e := openEngine(t, config)
+
+	putAll(t, e, "a", "b", "c", "d", "e")
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	checkGet(t, e, "b", "b")
+
+	if err := e.DeleteRange("b", "d"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.DeleteRange("d", "b"); err == nil {
+		t.Error("DeleteRange(d, b) of an empty range succeeded")
+	}
+	checkGet(t, e, "b", "")
+
+	// the tombstone is flushed after the stored tombstones were loaded
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	// keys written after the range deletion are not covered by it
+	putAll(t, e, "b2")
+	e.Put("c", []byte("rewritten"))
+	for key, want := range map[string]string{"a": "a", "b": "", "b2": "b2", "c": "rewritten", "d": "d", "e": "e"} {
+		checkGet(t, e, key, want)
+	}
+	crash(e)
+
+	e = openEngine(t, config)
+	for key, want := range map[string]string{"a": "a", "b": "", "b2": "b2", "c": "rewritten", "d": "d", "e": "e"} {
+		checkGet(t, e, key, want)
+	}
+}
+
+func TestCompactionDropsCoveredKeys(t *testing.T) {
+	e := openEngine(t, testConfig(t))
+
+	putAll(t, e, "a", "b", "c", "d")
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	checkGet(t, e, "a", "a")
+	e.DeleteRange("b", "d")
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	if tombstones, err := e.SSReader.RangeTombstones(); err != nil || len(tombstones) != 1 {
+		t.Fatalf("RangeTombstones() = %v, %v after the flush; want one tombstone", tombstones, err)
+	}
+
+	if err := e.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	// the covered keys are dropped together with the tombstone
+	if tombstones, err := e.SSReader.RangeTombstones(); err != nil || len(tombstones) != 0 {
+		t.Errorf("RangeTombstones() = %v, %v after the compaction; want none", tombstones, err)
+	}
+	for _, key := range []string{"b", "c"} {
+		if versions, err := e.SSReader.History(key); err != nil || len(versions) != 0 {
+			t.Errorf("SSReader.History(%s) = %v, %v after the compaction; want no versions", key, versions, err)
+		}
+	}
+	for key, want := range map[string]string{"a": "a", "b": "", "c": "", "d": "d"} {
+		checkGet(t, e, key, want)
+	}
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"NoSQLDB/lib/comparator"
+	cfg "NoSQLDB/lib/config"
+	mt "NoSQLDB/lib/memtable"
+	"errors"
+	"fmt"
+)
+
+const INDEX_PREFIX = ".index-"
+
+// INDEX_KEY_SEPARATOR separates the extracted value from the key in the keys of index entries
+const INDEX_KEY_SEPARATOR = "\x00"
+
+// INDEX_ENTRY_VALUE is the value of every index entry, the WAL does not log empty values
+var INDEX_ENTRY_VALUE = []byte{1}
+
+var (
+	ErrIndexExists   = errors.New("index already exists")
+	ErrIndexNotFound = errors.New("index not found")
+)
+
+// IndexExtractor derives the indexed value from a key and its value.
+// It returns false if the value should not be indexed.
+type IndexExtractor func(key string, value []byte) (string, bool)
+
+// Index maps extracted values to the keys holding them.
+// Its entries live in a hidden column family keyed by the extracted value followed by the key.
+type Index struct {
+	Name    string
+	extract IndexExtractor
+	cf      *ColumnFamily
+}
+
+// RegisterIndex creates a secondary index which is kept up to date on every write to the default keyspace.
+// It has to be registered before restoring the WAL, since its entries are logged under its column family.
+// Keys written before the index was registered are not indexed.
+func (e *Engine) RegisterIndex(name string, extract IndexExtractor) error {
+	if !isNameValid(name) {
+		return fmt.Errorf("invalid index name %q", name)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.Indexes[name]; ok {
+		return ErrIndexExists
+	}
+
+	// the dot keeps the name apart from user column families,
+	// and lookups scan the entries of a value as a contiguous bytewise range
+	cf, err := e.newColumnFamily(INDEX_PREFIX+name, cfg.ColumnFamilyConfig{Comparator: comparator.Bytewise.Name()})
+	if err != nil {
+		return err
+	}
+
+	e.Indexes[name] = &Index{
+		Name:    name,
+		extract: extract,
+		cf:      cf,
+	}
+	return nil
+}
+
+// LookupByIndex returns the keys whose values the index extracts the given value from, ordered by key.
+func (e *Engine) LookupByIndex(name, value string) ([]string, error) {
+	if !e.getToken() {
+		return nil, fmt.Errorf("timed out while looking up index %s", name)
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	index, ok := e.Indexes[name]
+	if !ok {
+		return nil, ErrIndexNotFound
+	}
+
+	prefix := value + INDEX_KEY_SEPARATOR
+	entries, err := index.cf.scan(prefix, value+"\x01")
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, entry := range entries {
+		key := entry.Key()[len(prefix):]
+
+		// range deletions do not update the index, so entries are checked against the current value
+		current, err := e.get(key)
+		if err != nil {
+			return nil, err
+		}
+		if extracted, ok := index.extractFrom(key, current); ok && extracted == value {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+func (index *Index) extractFrom(key string, value []byte) (string, bool) {
+	if value == nil {
+		return "", false
+	}
+	return index.extract(key, value)
+}
+
+// indexKey returns the key of the index entry pointing from the extracted value to the key
+func indexKey(value, key string) string {
+	return value + INDEX_KEY_SEPARATOR + key
+}
+
+// indexWrite is an index entry derived from a write to the default keyspace
+type indexWrite struct {
+	cf    *ColumnFamily
+	entry *mt.Entry
+}
+
+// indexWrites returns the index entries which move the key of the entry from its current value to the value it writes.
+// They are logged along with the entry, so restoring the WAL replays them instead of deriving them again.
+func (e *Engine) indexWrites(entry *mt.Entry) ([]indexWrite, error) {
+	if len(e.Indexes) == 0 {
+		return nil, nil
+	}
+
+	key := entry.Key()
+	old, err := e.get(key)
+	if err != nil {
+		return nil, err
+	}
+	new, err := e.valueAfter(entry, old)
+	if err != nil {
+		return nil, err
+	}
+
+	var writes []indexWrite
+	for _, index := range e.Indexes {
+		oldValue, hadOld := index.extractFrom(key, old)
+		newValue, hasNew := index.extractFrom(key, new)
+		if hadOld == hasNew && oldValue == newValue {
+			continue
+		}
+
+		if hadOld {
+			writes = append(writes, indexWrite{index.cf, mt.NewEntry(indexKey(oldValue, key), nil, true)})
+		}
+		if hasNew {
+			writes = append(writes, indexWrite{index.cf, mt.NewEntry(indexKey(newValue, key), INDEX_ENTRY_VALUE, false)})
+		}
+	}
+	return writes, nil
+}
+
+// valueAfter returns the value the key holds once the entry is written over the old value
+func (e *Engine) valueAfter(entry *mt.Entry, old []byte) ([]byte, error) {
+	if entry.Merge() {
+		merged, err := mt.Fold(e.Merger, []*mt.Entry{entry, mt.NewEntry(entry.Key(), old, old == nil)})
+		if err != nil {
+			return nil, err
+		}
+		entry = merged
+	}
+
+	if entry.Tombstone() || entry.Expired() {
+		return nil, nil
+	}
+	return entry.Value(), nil
+}
@@ -0,0 +1,70 @@
+package engine
+
+import (
+	cfg "NoSQLDB/lib/config"
+	"strings"
+	"testing"
+)
+
+// openWithCityIndex opens an engine with an index on the city of values formatted as "name,city" and restores its WAL
+func openWithCityIndex(t *testing.T, config *cfg.Config) *Engine {
+	e, err := NewEngine(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.RegisterIndex("city", func(key string, value []byte) (string, bool) {
+		_, city, ok := strings.Cut(string(value), ",")
+		return city, ok
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Restore(*config); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func checkLookup(t *testing.T, e *Engine, city string, want ...string) {
+	keys, err := e.LookupByIndex("city", city)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(keys, " ") != strings.Join(want, " ") {
+		t.Errorf("LookupByIndex(%s) = %v; want %v", city, keys, want)
+	}
+}
+
+// TestLookupAfterCrash flushes the records but not their index entries before the engine crashes,
+// the index entries are restored from the WAL.
+func TestLookupAfterCrash(t *testing.T) {
+	config := testConfig(t)
+	e := openWithCityIndex(t, config)
+
+	writes := [][2]string{{"user1", "ana,novi sad"}, {"user2", "marko,beograd"}, {"user3", "ivan,novi sad"}, {"user1", "ana,beograd"}}
+	for _, write := range writes {
+		if err := e.Put(write[0], []byte(write[1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Delete("user2"); err != nil {
+		t.Fatal(err)
+	}
+	checkLookup(t, e, "beograd", "user1")
+	checkLookup(t, e, "novi sad", "user3")
+
+	// only the default keyspace is flushed, then the engine is dropped without closing it
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	e.WAL.CurrentFile.Close()
+
+	e = openWithCityIndex(t, config)
+	checkLookup(t, e, "beograd", "user1")
+	checkLookup(t, e, "novi sad", "user3")
+
+	if err := e.Put("user4", []byte("jovan,beograd")); err != nil {
+		t.Fatal(err)
+	}
+	checkLookup(t, e, "beograd", "user1", "user4")
+}
@@ -0,0 +1,66 @@
+package engine
+
+import (
+	mt "NoSQLDB/lib/memtable"
+	"fmt"
+)
+
+// RegisterMergeOperator sets the operator used to fold the operands written by Merge.
+// It should be registered before restoring the WAL, so logged operands can be folded.
+func (e *Engine) RegisterMergeOperator(op mt.MergeOperator) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.Merger = op
+	e.Mempool.SetMergeOperator(op)
+	e.SSWriter.SetMergeOperator(op)
+}
+
+// Merge writes an operand which is combined with the value of the key by the registered merge operator.
+// The key is not read, the operands are folded lazily on Get and eagerly during flush and compaction.
+func (e *Engine) Merge(key string, operand []byte) error {
+	if !e.getToken() {
+		return fmt.Errorf("timed out while merging key %s", key)
+	}
+
+	unlock := e.lockWrite()
+	defer unlock()
+
+	if e.Merger == nil {
+		return mt.ErrNoMergeOperator
+	}
+
+	return e.write(mt.NewMergeEntry(key, operand))
+}
+
+// getMerged folds the merge operands of the key onto the newest full value below them.
+func (e *Engine) getMerged(key string) ([]byte, error) {
+	versions := e.Mempool.History(key)
+
+	stored, err := e.SSReader.History(key)
+	if err != nil {
+		return nil, err
+	}
+	versions = append(versions, stored...)
+	mt.SortVersions(versions)
+	versions = mt.DedupVersions(versions)
+
+	tombstones, err := e.rangeTombstones()
+	if err != nil {
+		return nil, err
+	}
+	versions = mt.DropCovered(e.Comparator, tombstones, versions)
+
+	if err := mt.ResolveMergeBases(e.valueLog(), versions); err != nil {
+		return nil, err
+	}
+	entry, err := mt.Fold(e.Merger, versions)
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	if entry.Tombstone() || entry.Expired() {
+		return nil, nil
+	}
+	return e.valueOf(entry)
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"NoSQLDB/lib/comparator"
+	mt "NoSQLDB/lib/memtable"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Scan returns the live entries with keys in [start, end), ordered by key.
+// Empty bounds leave the range open.
+func (e *Engine) Scan(start, end string) ([]*mt.Entry, error) {
+	if !e.getToken() {
+		return nil, fmt.Errorf("timed out while scanning range [%s, %s)", start, end)
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.scan(start, end)
+}
+
+// ScanPrefix returns the live entries with keys starting with the prefix, ordered by key.
+func (e *Engine) ScanPrefix(prefix string) ([]*mt.Entry, error) {
+	if !e.getToken() {
+		return nil, fmt.Errorf("timed out while scanning prefix %s", prefix)
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.Comparator.Name() == comparator.Bytewise.Name() {
+		return e.scan(prefix, prefixEnd(prefix))
+	}
+
+	// other comparators do not keep the keys with a prefix together
+	entries, err := e.scan("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	var matching []*mt.Entry
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Key(), prefix) {
+			matching = append(matching, entry)
+		}
+	}
+	return matching, nil
+}
+
+func (e *Engine) scan(start, end string) ([]*mt.Entry, error) {
+	stored, err := e.SSReader.Scan(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	tombstones, err := e.rangeTombstones()
+	if err != nil {
+		return nil, err
+	}
+
+	return resolve(append(e.Mempool.Scan(start, end), stored...), tombstones, e.Merger, e.Comparator, e.valueLog())
+}
+
+// resolve groups the scanned versions by key and returns the live value of every key, ordered by key.
+// Separated values are loaded from the value log.
+func resolve(scanned []*mt.Entry, tombstones []*mt.RangeTombstone, op mt.MergeOperator, cmp comparator.Comparator, vlog mt.ValueLog) ([]*mt.Entry, error) {
+	versionsOf := make(map[string][]*mt.Entry)
+	for _, entry := range scanned {
+		versionsOf[entry.Key()] = append(versionsOf[entry.Key()], entry)
+	}
+
+	keys := make([]string, 0, len(versionsOf))
+	for key := range versionsOf {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return cmp.Compare(keys[i], keys[j]) < 0
+	})
+
+	var entries []*mt.Entry
+	for _, key := range keys {
+		versions := versionsOf[key]
+		mt.SortVersions(versions)
+		versions = mt.DedupVersions(versions)
+
+		versions = mt.DropCovered(cmp, tombstones, versions)
+		if err := mt.ResolveMergeBases(vlog, versions); err != nil {
+			return nil, err
+		}
+
+		entry, err := mt.Fold(op, versions)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil || entry.Tombstone() || entry.Expired() {
+			continue
+		}
+
+		entry, err = entry.Resolve(vlog)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// prefixEnd returns the first key after all keys with the prefix in bytewise order, "" if there is none
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
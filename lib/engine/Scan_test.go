@@ -0,0 +1,50 @@
+package engine
+
+import (
+	mt "NoSQLDB/lib/memtable"
+	"strings"
+	"testing"
+)
+
+func checkScan(t *testing.T, operation string, entries []*mt.Entry, err error, want ...string) {
+	t.Helper()
+	keys := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		keys = append(keys, entry.Key())
+	}
+	if err != nil || strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("%s = %v, %v; want %v", operation, keys, err, want)
+	}
+}
+
+func TestScanDeleteRange(t *testing.T) {
+	config := testConfig(t)
+	e := openEngine(t, config)
+
+	putAll(t, e, "a", "b1", "b2", "c", "d")
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := e.Scan("", "")
+	checkScan(t, "Scan()", entries, err, "a", "b1", "b2", "c", "d")
+
+	e.DeleteRange("b", "c")
+	entries, err = e.Scan("", "")
+	checkScan(t, "Scan() with the tombstone in memory", entries, err, "a", "c", "d")
+
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	putAll(t, e, "b3")
+	entries, err = e.Scan("", "")
+	checkScan(t, "Scan() with the tombstone stored", entries, err, "a", "b3", "c", "d")
+	entries, err = e.Scan("b", "d")
+	checkScan(t, "Scan(b, d)", entries, err, "b3", "c")
+	entries, err = e.ScanPrefix("b")
+	checkScan(t, "ScanPrefix(b)", entries, err, "b3")
+	crash(e)
+
+	e = openEngine(t, config)
+	entries, err = e.Scan("", "")
+	checkScan(t, "Scan() after the restore", entries, err, "a", "b3", "c", "d")
+}
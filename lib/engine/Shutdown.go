@@ -0,0 +1,54 @@
+package engine
+
+import mt "NoSQLDB/lib/memtable"
+
+// Close flushes every memtable holding entries, syncs the sstables, the value log and the WAL, and records a clean shutdown.
+// The next engine opened on the same folders discards the WAL instead of replaying it.
+// The engine must not be used afterwards.
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := flushAndSync(e.Mempool, e.SSWriter); err != nil {
+		return err
+	}
+	for _, cf := range e.ColumnFamilies {
+		if err := flushAndSync(cf.Mempool, cf.SSWriter); err != nil {
+			return err
+		}
+	}
+	for _, index := range e.Indexes {
+		if err := flushAndSync(index.cf.Mempool, index.cf.SSWriter); err != nil {
+			return err
+		}
+	}
+
+	// the flushed sstables point into the value log
+	if e.ValueLog != nil {
+		if err := e.ValueLog.Sync(); err != nil {
+			return err
+		}
+		if err := e.ValueLog.Close(); err != nil {
+			return err
+		}
+	}
+
+	if err := e.WAL.Close(); err != nil {
+		return err
+	}
+	return e.WAL.MarkCleanShutdown()
+}
+
+// flushAndSync flushes the memtables and syncs the sstables,
+// which have to be on the disk before the WAL holding their entries is discarded on the next start
+func flushAndSync(mempool *mt.Mempool, writer *mt.SSWriter) error {
+	if err := mempool.FlushAll(); err != nil {
+		return err
+	}
+	return writer.Sync()
+}
+
+// CleanShutdown reports whether the previous engine was closed, in which case there is nothing to restore
+func (e *Engine) CleanShutdown() bool {
+	return e.cleanShutdown
+}
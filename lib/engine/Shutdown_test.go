@@ -0,0 +1,46 @@
+package engine
+
+import (
+	cfg "NoSQLDB/lib/config"
+	"testing"
+)
+
+// TestReopenAfterClose closes an engine holding writes to the default keyspace, a column family and an index.
+// The next engine discards the WAL and reads all of them from the sstables.
+func TestReopenAfterClose(t *testing.T) {
+	config := testConfig(t)
+	config.ColumnFamilies = map[string]cfg.ColumnFamilyConfig{"family": {}}
+	e := openWithCityIndex(t, config)
+
+	if err := e.Put("user1", []byte("ana,paris")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ColumnFamily("family").Put("key", []byte("family value")); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	e = openWithCityIndex(t, config)
+	if !e.CleanShutdown() {
+		t.Error("CleanShutdown() = false after Close")
+	}
+	checkGet(t, e, "user1", "ana,paris")
+	checkFamilyGet(t, e.ColumnFamily("family"), "key", "family value")
+	checkLookup(t, e, "paris", "user1")
+
+	// the engine logs to a new WAL, which is replayed over the closed one after a crash
+	if err := e.Put("user2", []byte("ivan,paris")); err != nil {
+		t.Fatal(err)
+	}
+	crash(e)
+
+	e = openWithCityIndex(t, config)
+	if e.CleanShutdown() {
+		t.Error("CleanShutdown() = true after a crash")
+	}
+	checkGet(t, e, "user2", "ivan,paris")
+	checkFamilyGet(t, e.ColumnFamily("family"), "key", "family value")
+	checkLookup(t, e, "paris", "user1", "user2")
+}
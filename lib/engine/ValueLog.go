@@ -0,0 +1,203 @@
+package engine
+
+import (
+	mt "NoSQLDB/lib/memtable"
+	valuelog "NoSQLDB/lib/value-log"
+	writeaheadlog "NoSQLDB/lib/write-ahead-log"
+	"bytes"
+	"errors"
+)
+
+var ErrVersionedValueLog = errors.New("value log garbage collection is not supported while versioning is enabled")
+
+// valueLog returns the value log entries are resolved from, nil if values are kept inline
+func (e *Engine) valueLog() mt.ValueLog {
+	if e.ValueLog == nil {
+		return nil
+	}
+	return e.ValueLog
+}
+
+// valueOf returns the value of the entry, loading it from the value log if it was separated
+func (e *Engine) valueOf(entry *mt.Entry) ([]byte, error) {
+	resolved, err := entry.Resolve(e.valueLog())
+	if err != nil {
+		return nil, err
+	}
+	return resolved.Value(), nil
+}
+
+// separate writes a value longer than the threshold to the value log and returns an entry pointing to it.
+// The value log record is written under the column family and key, so the collector can find the entry again.
+// The family is empty for the default keyspace.
+func (e *Engine) separate(family string, entry *mt.Entry) (*mt.Entry, error) {
+	if e.ValueLog == nil || entry.Tombstone() || entry.Merge() || entry.Pointer() || len(entry.Value()) <= e.Config.ValueThreshold {
+		return entry, nil
+	}
+
+	pointer, err := e.ValueLog.Append([]byte(family), entry.KeyBytes(), entry.Value())
+	if err != nil {
+		return nil, err
+	}
+	return mt.NewPointerEntryAt(entry.Key(), pointer, entry.Timestamp(), entry.Expiry()), nil
+}
+
+// CollectValueLog rewrites the sealed value log files in which the share of live values dropped below the configured ratio.
+// Live values are appended to the current file, their entries are written again pointing to the new location,
+// and the old file is removed.
+func (e *Engine) CollectValueLog() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.ValueLog == nil {
+		return nil
+	}
+
+	// older versions would keep pointing into the removed files
+	if e.Versions != nil {
+		return ErrVersionedValueLog
+	}
+
+	files, err := e.ValueLog.SealedFiles()
+	if err != nil {
+		return err
+	}
+
+	tombstones, err := e.rangeTombstones()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := e.collectValueLogFile(file, tombstones); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// liveValue is a value log record which is still the value of its key
+type liveValue struct {
+	family   []byte
+	value    []byte
+	key      string
+	mempool  *mt.Mempool
+	versions []*mt.Entry // versions of the key, newest first, the record is the base of the leading merge operands
+	base     int
+}
+
+func (e *Engine) collectValueLogFile(file int, tombstones []*mt.RangeTombstone) error {
+	records, err := e.ValueLog.Records(file)
+	if err != nil {
+		return err
+	}
+
+	size, err := e.ValueLog.Size(file)
+	if err != nil {
+		return err
+	}
+
+	var live []liveValue
+	var liveSize int64
+	for _, record := range records {
+		value, ok, err := e.liveValueOf(record, tombstones)
+		if err != nil {
+			return err
+		}
+		if ok {
+			value.value = record.Value
+			live = append(live, value)
+			liveSize += int64(record.Size)
+		}
+	}
+
+	if size > 0 && float64(liveSize)/float64(size) >= e.Config.ValueLogGCRatio {
+		return nil
+	}
+
+	for _, value := range live {
+		if err := e.rewriteValue(value); err != nil {
+			return err
+		}
+	}
+
+	// the new pointers have to be durable before the old values are gone
+	if err := e.ValueLog.Sync(); err != nil {
+		return err
+	}
+	if err := e.WAL.Sync(); err != nil {
+		return err
+	}
+	return e.ValueLog.Remove(file)
+}
+
+// liveValueOf checks whether the value log record is still read for the key it was written under.
+// That is the case if it is the newest full version of the key, below any merge operands.
+func (e *Engine) liveValueOf(record valuelog.Record, tombstones []*mt.RangeTombstone) (liveValue, bool, error) {
+	key, mempool, reader, cmp := string(record.Key), e.Mempool, e.SSReader, e.Comparator
+	if len(record.Family) > 0 {
+		cf, err := e.columnFamilyNamed(string(record.Family))
+		if err != nil {
+			return liveValue{}, false, err
+		}
+		mempool, reader, cmp = cf.Mempool, cf.SSReader, cf.Comparator
+		tombstones = nil
+	}
+
+	versions := mempool.History(key)
+	stored, err := reader.History(key)
+	if err != nil {
+		return liveValue{}, false, err
+	}
+	versions = append(versions, stored...)
+	mt.SortVersions(versions)
+	versions = mt.DropCovered(cmp, tombstones, mt.DedupVersions(versions))
+
+	i := 0
+	for i < len(versions) && versions[i].Merge() {
+		i++
+	}
+	if i == len(versions) {
+		return liveValue{}, false, nil
+	}
+
+	base := versions[i]
+	if !base.Pointer() || base.Expired() || !bytes.Equal(base.Value(), record.Pointer) {
+		return liveValue{}, false, nil
+	}
+
+	return liveValue{family: record.Family, key: key, mempool: mempool, versions: versions, base: i}, true, nil
+}
+
+// rewriteValue appends a live value to the current value log file and writes the entry again.
+// Merge operands on top of the value are folded into it, the entry keeps the timestamp of the newest version.
+func (e *Engine) rewriteValue(value liveValue) error {
+	base := value.versions[value.base]
+	entry := mt.NewEntryAt(value.key, value.value, false, base.Timestamp(), base.Expiry())
+
+	if value.base > 0 {
+		versions := append(append([]*mt.Entry{}, value.versions[:value.base]...), entry)
+		folded, err := mt.Fold(e.Merger, versions)
+		if err != nil {
+			return err
+		}
+		entry = folded
+	}
+
+	pointer, err := e.ValueLog.Append(value.family, entry.KeyBytes(), entry.Value())
+	if err != nil {
+		return err
+	}
+	rewritten := mt.NewPointerEntryAt(value.key, pointer, entry.Timestamp(), entry.Expiry())
+
+	record, err := walRecord(rewritten)
+	if err != nil {
+		return err
+	}
+	record.Family = value.family
+	if err := e.WAL.LogBatch([]*writeaheadlog.WriteAheadLogEntry{record}); err != nil {
+		return err
+	}
+
+	return value.mempool.Put(rewritten)
+}
@@ -0,0 +1,131 @@
+package engine
+
+import (
+	cfg "NoSQLDB/lib/config"
+	"strings"
+	"testing"
+)
+
+// largeValue returns a value of the key which is always separated into the value log
+func largeValue(key string) string {
+	return strings.Repeat(key+";", 16)
+}
+
+// openWithValueLog opens an engine separating every value into small value log files, with a column family
+func openWithValueLog(t *testing.T, config *cfg.Config) (*Engine, *ColumnFamily) {
+	config.ValueThreshold = 8
+	config.ValueLogFileSize = 256
+	config.ValueLogGCRatio = 1
+	return openWithColumnFamily(t, config, "family")
+}
+
+// checkSealedRecords checks that the sealed value log files only hold the values read for their keys
+func checkSealedRecords(t *testing.T, e *Engine, live map[string]string) {
+	t.Helper()
+	files, err := e.ValueLog.SealedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		records, err := e.ValueLog.Records(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range records {
+			key := string(record.Family) + "/" + string(record.Key)
+			if want, ok := live[key]; !ok || string(record.Value) != want {
+				t.Errorf("value log file %d holds %q = %q after the collection; want only live values", file, key, record.Value)
+			}
+		}
+	}
+}
+
+func TestCollectValueLog(t *testing.T) {
+	config := testConfig(t)
+	e, cf := openWithValueLog(t, config)
+
+	put := func(key, value string) {
+		t.Helper()
+		if err := e.Put(key, []byte(value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	put("overwritten", "old "+largeValue("overwritten"))
+	put("deleted", largeValue("deleted"))
+	put("ranged", largeValue("ranged"))
+	put("kept", largeValue("kept"))
+	if err := cf.Put("key\x00part", []byte(largeValue("family"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := cf.Put("dead", []byte(largeValue("dead"))); err != nil {
+		t.Fatal(err)
+	}
+	// half of the versions are read from the tables
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := cf.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	put("overwritten", largeValue("overwritten"))
+	if err := e.Delete("deleted"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.DeleteRange("r", "s"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cf.Delete("dead"); err != nil {
+		t.Fatal(err)
+	}
+	// seals the file holding the last value
+	put("filler", strings.Repeat("f", 256))
+
+	before, err := e.ValueLog.SealedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.CollectValueLog(); err != nil {
+		t.Fatal(err)
+	}
+	after, err := e.ValueLog.SealedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range after {
+		if file == before[0] {
+			t.Errorf("SealedFiles() = %v after the collection; want file %d holding dead values removed", after, before[0])
+		}
+	}
+
+	live := map[string]string{
+		"/overwritten":       largeValue("overwritten"),
+		"/kept":              largeValue("kept"),
+		"/filler":            strings.Repeat("f", 256),
+		"family/key\x00part": largeValue("family"),
+	}
+	checkSealedRecords(t, e, live)
+
+	check := func() {
+		t.Helper()
+		for key, want := range map[string]string{"overwritten": largeValue("overwritten"), "kept": largeValue("kept"), "deleted": "", "ranged": ""} {
+			checkGet(t, e, key, want)
+		}
+		checkFamilyGet(t, cf, "key\x00part", largeValue("family"))
+		checkFamilyGet(t, cf, "dead", "")
+	}
+	check()
+
+	// the rewritten entries are restored from the WAL pointing to their new location
+	crash(e)
+	e, cf = openWithValueLog(t, config)
+	check()
+
+	// nothing is left to collect
+	if err := e.CollectValueLog(); err != nil {
+		t.Fatal(err)
+	}
+	checkSealedRecords(t, e, live)
+	check()
+}
@@ -0,0 +1,273 @@
+package keyenc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+/*
+Every element of a tuple starts with a tag byte, followed by its encoding:
+
+	string    - bytes with 0x00 escaped as 0x00 0xFF, terminated by 0x00 0x01
+	int       - 8 byte big endian with the sign bit flipped
+	uint      - 8 byte big endian
+	float     - 8 byte big endian IEEE 754, all bits flipped for negative numbers, only the sign bit otherwise
+	timestamp - unix nanoseconds encoded like an int
+	bool      - a single 0 or 1 byte
+
+Comparing two encoded keys byte by byte gives the same order as comparing the tuples element by element.
+Elements of different types are ordered by their tags.
+*/
+const (
+	TAG_BOOL      = 0x01
+	TAG_INT       = 0x02
+	TAG_UINT      = 0x03
+	TAG_FLOAT     = 0x04
+	TAG_TIMESTAMP = 0x05
+	TAG_STRING    = 0x06
+
+	NUMBER_SIZE = 8
+
+	ESCAPE     = 0x00
+	ESCAPED_00 = 0xFF
+	TERMINATOR = 0x01
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported key element type")
+	ErrCorruptKey      = errors.New("corrupt key")
+)
+
+// Encode encodes the tuple into an order preserving key.
+// Supported elements are strings, byte slices, signed and unsigned integers, floats, time.Time and bools.
+func Encode(elements ...interface{}) ([]byte, error) {
+	var key []byte
+	for _, element := range elements {
+		switch v := element.(type) {
+		case string:
+			key = AppendString(key, v)
+		case []byte:
+			key = AppendString(key, string(v))
+		case int:
+			key = AppendInt(key, int64(v))
+		case int8:
+			key = AppendInt(key, int64(v))
+		case int16:
+			key = AppendInt(key, int64(v))
+		case int32:
+			key = AppendInt(key, int64(v))
+		case int64:
+			key = AppendInt(key, v)
+		case uint:
+			key = AppendUint(key, uint64(v))
+		case uint8:
+			key = AppendUint(key, uint64(v))
+		case uint16:
+			key = AppendUint(key, uint64(v))
+		case uint32:
+			key = AppendUint(key, uint64(v))
+		case uint64:
+			key = AppendUint(key, v)
+		case float32:
+			key = AppendFloat(key, float64(v))
+		case float64:
+			key = AppendFloat(key, v)
+		case time.Time:
+			key = AppendTimestamp(key, v)
+		case bool:
+			key = AppendBool(key, v)
+		default:
+			return nil, fmt.Errorf("%w: %T", ErrUnsupportedType, element)
+		}
+	}
+	return key, nil
+}
+
+// Decode decodes every element of the key.
+// Elements are returned as string, int64, uint64, float64, time.Time or bool.
+func Decode(key []byte) ([]interface{}, error) {
+	var elements []interface{}
+	d := NewDecoder(key)
+	for d.More() {
+		element, err := d.Next()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+	}
+	return elements, nil
+}
+
+func AppendString(key []byte, s string) []byte {
+	key = append(key, TAG_STRING)
+	for i := 0; i < len(s); i++ {
+		if s[i] == ESCAPE {
+			key = append(key, ESCAPE, ESCAPED_00)
+		} else {
+			key = append(key, s[i])
+		}
+	}
+	return append(key, ESCAPE, TERMINATOR)
+}
+
+func AppendInt(key []byte, i int64) []byte {
+	return appendNumber(append(key, TAG_INT), uint64(i)^(1<<63))
+}
+
+func AppendUint(key []byte, u uint64) []byte {
+	return appendNumber(append(key, TAG_UINT), u)
+}
+
+func AppendFloat(key []byte, f float64) []byte {
+	bits := math.Float64bits(f)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits ^= 1 << 63
+	}
+	return appendNumber(append(key, TAG_FLOAT), bits)
+}
+
+func AppendTimestamp(key []byte, t time.Time) []byte {
+	return appendNumber(append(key, TAG_TIMESTAMP), uint64(t.UnixNano())^(1<<63))
+}
+
+func AppendBool(key []byte, b bool) []byte {
+	if b {
+		return append(key, TAG_BOOL, 1)
+	}
+	return append(key, TAG_BOOL, 0)
+}
+
+func appendNumber(key []byte, u uint64) []byte {
+	return binary.BigEndian.AppendUint64(key, u)
+}
+
+// Decoder reads the elements of an encoded key in order.
+type Decoder struct {
+	key []byte
+}
+
+func NewDecoder(key []byte) *Decoder {
+	return &Decoder{key: key}
+}
+
+// More reports whether there are elements left to decode.
+func (d *Decoder) More() bool {
+	return len(d.key) > 0
+}
+
+// Next decodes the next element whatever its type is.
+func (d *Decoder) Next() (interface{}, error) {
+	if !d.More() {
+		return nil, ErrCorruptKey
+	}
+
+	switch d.key[0] {
+	case TAG_STRING:
+		return d.String()
+	case TAG_INT:
+		return d.Int()
+	case TAG_UINT:
+		return d.Uint()
+	case TAG_FLOAT:
+		return d.Float()
+	case TAG_TIMESTAMP:
+		return d.Timestamp()
+	case TAG_BOOL:
+		return d.Bool()
+	}
+	return nil, ErrCorruptKey
+}
+
+func (d *Decoder) String() (string, error) {
+	if err := d.tag(TAG_STRING); err != nil {
+		return "", err
+	}
+
+	var s []byte
+	for i := 0; i < len(d.key)-1; i++ {
+		if d.key[i] != ESCAPE {
+			s = append(s, d.key[i])
+			continue
+		}
+
+		switch d.key[i+1] {
+		case TERMINATOR:
+			d.key = d.key[i+2:]
+			return string(s), nil
+		case ESCAPED_00:
+			s = append(s, ESCAPE)
+			i++
+		default:
+			return "", ErrCorruptKey
+		}
+	}
+	return "", ErrCorruptKey
+}
+
+func (d *Decoder) Int() (int64, error) {
+	u, err := d.number(TAG_INT)
+	return int64(u ^ (1 << 63)), err
+}
+
+func (d *Decoder) Uint() (uint64, error) {
+	return d.number(TAG_UINT)
+}
+
+func (d *Decoder) Float() (float64, error) {
+	bits, err := d.number(TAG_FLOAT)
+	if err != nil {
+		return 0, err
+	}
+	if bits&(1<<63) != 0 {
+		bits ^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits), nil
+}
+
+func (d *Decoder) Timestamp() (time.Time, error) {
+	u, err := d.number(TAG_TIMESTAMP)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(u^(1<<63))), nil
+}
+
+func (d *Decoder) Bool() (bool, error) {
+	if err := d.tag(TAG_BOOL); err != nil {
+		return false, err
+	}
+	if len(d.key) < 1 || d.key[0] > 1 {
+		return false, ErrCorruptKey
+	}
+	b := d.key[0] == 1
+	d.key = d.key[1:]
+	return b, nil
+}
+
+// tag consumes the tag of the next element, which has to match the expected one
+func (d *Decoder) tag(expected byte) error {
+	if len(d.key) == 0 || d.key[0] != expected {
+		return ErrCorruptKey
+	}
+	d.key = d.key[1:]
+	return nil
+}
+
+func (d *Decoder) number(tag byte) (uint64, error) {
+	if err := d.tag(tag); err != nil {
+		return 0, err
+	}
+	if len(d.key) < NUMBER_SIZE {
+		return 0, ErrCorruptKey
+	}
+	u := binary.BigEndian.Uint64(d.key[:NUMBER_SIZE])
+	d.key = d.key[NUMBER_SIZE:]
+	return u, nil
+}
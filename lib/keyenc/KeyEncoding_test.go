@@ -0,0 +1,93 @@
+package keyenc
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// tuples are listed in ascending order
+var ordered = [][]interface{}{
+	{false},
+	{true},
+	{int64(math.MinInt64)},
+	{int64(-300)},
+	{int64(-1)},
+	{int64(0)},
+	{int64(2)},
+	{int64(256)},
+	{uint64(0)},
+	{uint64(1 << 40)},
+	{math.Inf(-1)},
+	{-2.5},
+	{-0.5},
+	{0.0},
+	{0.25},
+	{1e300},
+	{time.Unix(-5, 0)},
+	{time.Unix(1700000000, 0)},
+	{""},
+	{"", int64(1)},
+	{"a"},
+	{"a", int64(-1)},
+	{"a", int64(10)},
+	{"a\x00"},
+	{"a\x00b"},
+	{"ab"},
+	{"b"},
+}
+
+func TestEncodeOrder(t *testing.T) {
+	var previous []byte
+	for i, tuple := range ordered {
+		key, err := Encode(tuple...)
+		if err != nil {
+			t.Fatalf("Encode(%v) = %v", tuple, err)
+		}
+		if i > 0 && bytes.Compare(previous, key) >= 0 {
+			t.Errorf("Encode(%v) does not sort after Encode(%v)", tuple, ordered[i-1])
+		}
+		previous = key
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tuple := []interface{}{"user\x00id", int64(-42), uint64(7), -1.5, time.Unix(3, 4), true}
+
+	key, err := Encode(tuple...)
+	if err != nil {
+		t.Fatalf("Encode() = %v", err)
+	}
+	decoded, err := Decode(key)
+	if err != nil {
+		t.Fatalf("Decode() = %v", err)
+	}
+
+	if !reflect.DeepEqual(decoded[:4], tuple[:4]) || decoded[5] != true {
+		t.Errorf("Decode() = %v; want %v", decoded, tuple)
+	}
+	if !decoded[4].(time.Time).Equal(tuple[4].(time.Time)) {
+		t.Errorf("Decode() timestamp = %v; want %v", decoded[4], tuple[4])
+	}
+
+	if _, err := Decode(key[:len(key)-1]); err != ErrCorruptKey {
+		t.Errorf("Decode() of a truncated key = %v; want ErrCorruptKey", err)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	key := AppendInt(AppendString(nil, "orders"), 99)
+
+	d := NewDecoder(key)
+	table, _ := d.String()
+	id, err := d.Int()
+	if table != "orders" || id != 99 || err != nil || d.More() {
+		t.Errorf("Decoder read %s, %d, %v; want orders, 99, nil", table, id, err)
+	}
+
+	if _, err := NewDecoder(key).Int(); err != ErrCorruptKey {
+		t.Errorf("Int() of a string element = %v; want ErrCorruptKey", err)
+	}
+}
@@ -0,0 +1,122 @@
+package memtable
+
+import (
+	"NoSQLDB/lib/art"
+	"NoSQLDB/lib/comparator"
+	"NoSQLDB/lib/utils"
+)
+
+// ARTMemtable keeps its entries in an adaptive radix tree, which stores the shared prefixes of the keys once.
+type ARTMemtable struct {
+	rangeTombstones
+	memoryUsage
+	data       *art.ART
+	threshhold int
+}
+
+func NewARTMemtable(threshold int) *ARTMemtable {
+	return NewARTMemtableWithComparator(threshold, comparator.Bytewise)
+}
+
+// NewARTMemtableWithComparator creates an ART memtable whose keys are sorted with the comparator.
+// The tree itself is ordered bytewise, other comparators sort the keys when they are read.
+func NewARTMemtableWithComparator(threshold int, cmp comparator.Comparator) *ARTMemtable {
+	return &ARTMemtable{
+		rangeTombstones: rangeTombstones{cmp: cmp},
+		data:            art.NewART(),
+		threshhold:      threshold,
+	}
+}
+
+func (am *ARTMemtable) Put(key string, value []byte) error {
+	return am.PutEntry(NewEntry(key, value, false))
+}
+
+func (am *ARTMemtable) PutEntry(entry *Entry) error {
+	old := am.data.PutVersion(entry.key, entry.value, entry.tombstone, entry.merge, entry.pointer, entry.timestamp, entry.expiry)
+	am.replace(leafToEntry(old), entry)
+	return nil
+}
+
+func (am *ARTMemtable) Get(key string) (*Entry, error) {
+	return leafToEntry(am.data.Get(key)), nil
+}
+
+func (am *ARTMemtable) PutBytes(key, value []byte) error {
+	return am.Put(utils.BytesToString(key), value)
+}
+
+func (am *ARTMemtable) GetBytes(key []byte) (*Entry, error) {
+	return am.Get(utils.BytesToString(key))
+}
+
+func (am *ARTMemtable) Delete(key string) error {
+	return am.PutEntry(NewEntry(key, nil, true))
+}
+
+func (am *ARTMemtable) Size() int {
+	return am.data.Size()
+}
+
+func (am *ARTMemtable) IsFull() bool {
+	return am.Size() >= am.threshhold || am.bytesFull()
+}
+
+func (am *ARTMemtable) SortKeys() []string {
+	keys := make([]string, 0, am.data.Size())
+	am.data.Ascend(func(leaf *art.Leaf) bool {
+		keys = append(keys, leaf.Key())
+		return true
+	})
+
+	if am.cmp.Name() != comparator.Bytewise.Name() {
+		sortKeys(am.cmp, keys)
+	}
+	return keys
+}
+
+// Iterator walks the tree directly if it is ordered by the comparator, otherwise it sorts the entries first
+func (am *ARTMemtable) Iterator() Iterator {
+	if am.cmp.Name() != comparator.Bytewise.Name() {
+		entries := make([]*Entry, 0, am.data.Size())
+		for _, key := range am.SortKeys() {
+			entries = append(entries, leafToEntry(am.data.Get(key)))
+		}
+		return newSliceIterator(entries)
+	}
+
+	it := am.data.Iterator()
+	return &cursorIterator{
+		next:  it.Next,
+		entry: func() *Entry { return leafToEntry(it.Leaf()) },
+	}
+}
+
+// KeysWithPrefix returns the keys starting with the prefix, sorted with the comparator of the memtable
+func (am *ARTMemtable) KeysWithPrefix(prefix string) []string {
+	var keys []string
+	am.data.AscendPrefix(prefix, func(leaf *art.Leaf) bool {
+		keys = append(keys, leaf.Key())
+		return true
+	})
+
+	if am.cmp.Name() != comparator.Bytewise.Name() {
+		sortKeys(am.cmp, keys)
+	}
+	return keys
+}
+
+func leafToEntry(l *art.Leaf) *Entry {
+	if l == nil {
+		return nil
+	}
+	return &Entry{
+		key:       l.Key(),
+		value:     l.Value(),
+		tombstone: l.Tombstone(),
+		merge:     l.Merge(),
+		pointer:   l.Pointer(),
+		timestamp: l.Timestamp(),
+		expiry:    l.Expiry(),
+	}
+}
@@ -0,0 +1,108 @@
+package memtable
+
+import (
+	"NoSQLDB/lib/comparator"
+	"NoSQLDB/lib/skiplist"
+	"NoSQLDB/lib/utils"
+)
+
+// ArenaSkipListMemtable keeps its entries in an arena-backed skip list.
+// Flushing drops the memtable, which releases the whole arena at once.
+type ArenaSkipListMemtable struct {
+	rangeTombstones
+	data       *skiplist.ArenaSkipList
+	threshhold int
+	maxBytes   int
+}
+
+func NewArenaSkipListMemtable(threshold, maxLevel int) *ArenaSkipListMemtable {
+	return NewArenaSkipListMemtableWithComparator(threshold, maxLevel, comparator.Bytewise)
+}
+
+// NewArenaSkipListMemtableWithComparator creates an arena skip list memtable ordered by the comparator
+func NewArenaSkipListMemtableWithComparator(threshold, maxLevel int, cmp comparator.Comparator) *ArenaSkipListMemtable {
+	return &ArenaSkipListMemtable{
+		rangeTombstones: rangeTombstones{cmp: cmp},
+		data:            skiplist.NewArenaSkipListWithComparator(maxLevel, ARENA_SLAB_SIZE, cmp),
+		threshhold:      threshold,
+	}
+}
+
+func (am *ArenaSkipListMemtable) Put(key string, value []byte) error {
+	return am.PutEntry(NewEntry(key, value, false))
+}
+
+func (am *ArenaSkipListMemtable) PutEntry(entry *Entry) error {
+	return am.data.PutVersion(entry.key, entry.value, entry.tombstone, entry.merge, entry.pointer, entry.timestamp, entry.expiry)
+}
+
+func (am *ArenaSkipListMemtable) Get(key string) (*Entry, error) {
+	node, ok := am.data.Get(key)
+	if !ok {
+		return nil, nil
+	}
+	return arenaNodeToEntry(node), nil
+}
+
+func (am *ArenaSkipListMemtable) PutBytes(key, value []byte) error {
+	// the key is copied into the arena, so it doesn't have to outlive the call
+	return am.Put(utils.BytesToString(key), value)
+}
+
+func (am *ArenaSkipListMemtable) GetBytes(key []byte) (*Entry, error) {
+	return am.Get(utils.BytesToString(key))
+}
+
+func (am *ArenaSkipListMemtable) Delete(key string) error {
+	return am.PutEntry(NewEntry(key, nil, true))
+}
+
+func (am *ArenaSkipListMemtable) Size() int {
+	return am.data.Size()
+}
+
+// SizeBytes returns the part of the arena used by the memtable, overwritten values included
+func (am *ArenaSkipListMemtable) SizeBytes() int {
+	return am.data.SizeBytes()
+}
+
+func (am *ArenaSkipListMemtable) SetMaxBytes(maxBytes int) {
+	am.maxBytes = maxBytes
+}
+
+func (am *ArenaSkipListMemtable) IsFull() bool {
+	return am.Size() >= am.threshhold || (am.maxBytes > 0 && am.SizeBytes() >= am.maxBytes)
+}
+
+func (am *ArenaSkipListMemtable) SortKeys() []string {
+	nodes := am.data.Nodes()
+
+	keys := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		keys = append(keys, node.Key())
+	}
+
+	// the nodes are already ordered by the comparator of the skip list
+	return keys
+}
+
+func (am *ArenaSkipListMemtable) Iterator() Iterator {
+	it := am.data.Iterator()
+	return &cursorIterator{
+		next:  it.Next,
+		entry: func() *Entry { return arenaNodeToEntry(it.Node()) },
+	}
+}
+
+// arenaNodeToEntry returns an entry whose key and value point into the arena
+func arenaNodeToEntry(n skiplist.ArenaNode) *Entry {
+	return &Entry{
+		key:       n.Key(),
+		value:     n.Value(),
+		tombstone: n.Tombstone(),
+		merge:     n.Merge(),
+		pointer:   n.Pointer(),
+		timestamp: n.Timestamp(),
+		expiry:    n.Expiry(),
+	}
+}
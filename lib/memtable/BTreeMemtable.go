@@ -2,23 +2,41 @@ package memtable
 
 import (
 	"NoSQLDB/lib/btree"
-	"sort"
+	"NoSQLDB/lib/comparator"
+	"NoSQLDB/lib/utils"
 )
 
 type BTreeMemtable struct {
+	rangeTombstones
+	memoryUsage
 	data      *btree.BTree
 	threshold int
 }
 
 func NewBTreeMemtable(minDegree, threshold int) *BTreeMemtable {
+	return NewBTreeMemtableWithComparator(minDegree, threshold, comparator.Bytewise)
+}
+
+// NewBTreeMemtableWithComparator creates a BTree memtable ordered by the comparator
+func NewBTreeMemtableWithComparator(minDegree, threshold int, cmp comparator.Comparator) *BTreeMemtable {
 	return &BTreeMemtable{
-		data:      btree.NewBTree(minDegree),
-		threshold: threshold,
+		rangeTombstones: rangeTombstones{cmp: cmp},
+		data:            btree.NewBTreeWithComparator(minDegree, cmp),
+		threshold:       threshold,
 	}
 }
 
 func (btm *BTreeMemtable) Put(key string, value []byte) error {
-	btm.data.Put(key, value, false)
+	return btm.PutEntry(NewEntry(key, value, false))
+}
+
+func (btm *BTreeMemtable) PutEntry(entry *Entry) error {
+	if old, _ := btm.data.Get(entry.key, nil); old != nil {
+		btm.replace(toEntry(old), entry)
+	} else {
+		btm.replace(nil, entry)
+	}
+	btm.data.PutVersion(entry.key, entry.value, entry.tombstone, entry.merge, entry.pointer, entry.timestamp, entry.expiry)
 	return nil
 }
 
@@ -30,9 +48,16 @@ func (btm *BTreeMemtable) Get(key string) (*Entry, error) {
 	return nil, nil
 }
 
+func (btm *BTreeMemtable) PutBytes(key, value []byte) error {
+	return btm.Put(utils.BytesToString(key), value)
+}
+
+func (btm *BTreeMemtable) GetBytes(key []byte) (*Entry, error) {
+	return btm.Get(utils.BytesToString(key))
+}
+
 func (btm *BTreeMemtable) Delete(key string) error {
-	btm.data.Put(key, nil, true)
-	return nil
+	return btm.PutEntry(NewEntry(key, nil, true))
 }
 
 func (btm *BTreeMemtable) Size() int {
@@ -40,7 +65,15 @@ func (btm *BTreeMemtable) Size() int {
 }
 
 func (btm *BTreeMemtable) IsFull() bool {
-	return false
+	return btm.Size() >= btm.threshold || btm.bytesFull()
+}
+
+func (btm *BTreeMemtable) Iterator() Iterator {
+	it := btm.data.Iterator()
+	return &cursorIterator{
+		next:  it.Next,
+		entry: func() *Entry { return toEntry(it.Entry()) },
+	}
 }
 
 func toEntry(be *btree.Entry) *Entry {
@@ -48,29 +81,18 @@ func toEntry(be *btree.Entry) *Entry {
 		key:       be.Key(),
 		value:     be.Value(),
 		tombstone: be.Tombstone(),
+		merge:     be.Merge(),
+		pointer:   be.Pointer(),
+		timestamp: be.Timestamp(),
+		expiry:    be.Expiry(),
 	}
 }
 
 func (b *BTreeMemtable) SortKeys() []string {
-	var keys []string
-	b.collectKeysRecursive(b.data.Root(), &keys)
-	sort.Strings(keys)
+	keys := make([]string, 0, b.data.Size())
+	b.data.Ascend("", func(entry *btree.Entry) bool {
+		keys = append(keys, entry.Key())
+		return true
+	})
 	return keys
 }
-
-func (b *BTreeMemtable) collectKeysRecursive(node *btree.Node, keys *[]string) {
-	if node == nil {
-		return
-	}
-
-	for i := 0; i < len(node.Keys()); i++ {
-		*keys = append(*keys, node.Keys()[i])
-	}
-
-	// Recursively process child nodes
-	if !node.IsLeaf() {
-		for i := 0; i < len(node.Children()); i++ {
-			b.collectKeysRecursive(node.Children()[i], keys)
-		}
-	}
-}
@@ -0,0 +1,177 @@
+package memtable
+
+import (
+	"container/list"
+	"hash/fnv"
+	"strconv"
+	"sync"
+)
+
+const (
+	BLOCK_CACHE_SHARDS = 16
+	// approximates the memory a summary or index entry takes up besides its key
+	INDEX_ENTRY_OVERHEAD = 32
+)
+
+// blockKey identifies a block by the file it was read from, whose name holds the generation of the table, and its offset.
+// Generations are never reused, so a block of a compacted table is simply not read again until it is evicted.
+type blockKey struct {
+	file   string
+	offset int
+}
+
+// indexBlock is a decoded run of summary or index entries, their offsets point into the next file of a lookup
+type indexBlock struct {
+	keys    []string
+	offsets []int
+}
+
+// cachedBlock holds either an index block or the entries of a data block
+type cachedBlock struct {
+	key   blockKey
+	index *indexBlock
+	data  []*Entry
+	size  int
+}
+
+// BlockCache is a sharded LRU cache of decoded sstable blocks, its capacity is counted in bytes.
+// It is shared by the readers of all column families.
+type BlockCache struct {
+	shards []*blockShard
+}
+
+type blockShard struct {
+	mu        sync.Mutex
+	capacity  int
+	used      int
+	items     map[blockKey]*list.Element
+	lru       *list.List
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// BlockCacheStats counts the lookups and evictions of a block cache
+type BlockCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+func NewBlockCache(capacityBytes int) *BlockCache {
+	bc := &BlockCache{shards: make([]*blockShard, BLOCK_CACHE_SHARDS)}
+	for i := range bc.shards {
+		bc.shards[i] = &blockShard{
+			capacity: capacityBytes / BLOCK_CACHE_SHARDS,
+			items:    make(map[blockKey]*list.Element),
+			lru:      list.New(),
+		}
+	}
+	return bc
+}
+
+func (bc *BlockCache) shardOf(key blockKey) *blockShard {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(key.file))
+	hasher.Write([]byte(strconv.Itoa(key.offset)))
+	return bc.shards[hasher.Sum32()%uint32(len(bc.shards))]
+}
+
+func (bc *BlockCache) get(key blockKey) *cachedBlock {
+	s := bc.shardOf(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.items[key]
+	if !ok {
+		s.misses++
+		return nil
+	}
+	s.hits++
+	s.lru.MoveToFront(element)
+	return element.Value.(*cachedBlock)
+}
+
+// put caches the block, evicting the least recently used blocks of its shard until it fits.
+// Blocks larger than a shard are not cached.
+func (bc *BlockCache) put(block *cachedBlock) {
+	s := bc.shardOf(block.key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.items[block.key]; ok {
+		s.remove(element)
+	}
+	if block.size > s.capacity {
+		return
+	}
+
+	for s.used+block.size > s.capacity {
+		s.remove(s.lru.Back())
+		s.evictions++
+	}
+	s.items[block.key] = s.lru.PushFront(block)
+	s.used += block.size
+}
+
+func (s *blockShard) remove(element *list.Element) {
+	block := s.lru.Remove(element).(*cachedBlock)
+	delete(s.items, block.key)
+	s.used -= block.size
+}
+
+// getIndex returns the cached summary or index block of the file at the offset, nil if it is not cached
+func (bc *BlockCache) getIndex(file string, offset int) *indexBlock {
+	if block := bc.get(blockKey{file, offset}); block != nil {
+		return block.index
+	}
+	return nil
+}
+
+func (bc *BlockCache) putIndex(file string, offset int, index *indexBlock) {
+	size := 0
+	for _, key := range index.keys {
+		size += len(key) + INDEX_ENTRY_OVERHEAD
+	}
+	bc.put(&cachedBlock{key: blockKey{file, offset}, index: index, size: size})
+}
+
+// getData returns the entries of the cached data block of the file at the offset, nil if it is not cached
+func (bc *BlockCache) getData(file string, offset int) ([]*Entry, bool) {
+	if block := bc.get(blockKey{file, offset}); block != nil {
+		return block.data, true
+	}
+	return nil, false
+}
+
+func (bc *BlockCache) putData(file string, offset int, entries []*Entry) {
+	size := 0
+	for _, entry := range entries {
+		size += entry.sizeBytes()
+	}
+	bc.put(&cachedBlock{key: blockKey{file, offset}, data: entries, size: size})
+}
+
+// SizeBytes returns the memory taken up by the cached blocks
+func (bc *BlockCache) SizeBytes() int {
+	used := 0
+	for _, s := range bc.shards {
+		s.mu.Lock()
+		used += s.used
+		s.mu.Unlock()
+	}
+	return used
+}
+
+// Stats returns the hits, misses and evictions of all shards
+func (bc *BlockCache) Stats() BlockCacheStats {
+	var stats BlockCacheStats
+	for _, s := range bc.shards {
+		s.mu.Lock()
+		stats.Hits += s.hits
+		stats.Misses += s.misses
+		stats.Evictions += s.evictions
+		s.mu.Unlock()
+	}
+	return stats
+}
@@ -0,0 +1,101 @@
+package memtable
+
+import (
+	"NoSQLDB/lib/comparator"
+	"fmt"
+	"testing"
+)
+
+// writeVersionedTables flushes two tables with small strides, every key has three versions and the even keys are overwritten in the second table
+func writeVersionedTables(t *testing.T) string {
+	dir := t.TempDir() + "/"
+	policy := NewVersionPolicy(3, 0)
+	writer, err := NewSSWriter(dir, 2, 2, 100, 0.01, 0, policy, comparator.Bytewise)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for table := 0; table < 2; table++ {
+		vm := NewVersionedMemtable(NewMapMemtable(1000), policy)
+		for i := 0; i < 60; i++ {
+			if table == 1 && i%2 == 1 {
+				continue
+			}
+			for version := 0; version < 3; version++ {
+				value := fmt.Sprintf("t%d-v%d", table, version)
+				vm.PutEntry(NewEntryAt(fmt.Sprintf("key%03d", i), []byte(value), false, int64(table*10+version+1), 0))
+			}
+		}
+		if err := writer.Flush(vm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func checkReader(t *testing.T, reader *SSReader) {
+	for i := 0; i < 60; i++ {
+		key := fmt.Sprintf("key%03d", i)
+		table, versions := 0, 3
+		if i%2 == 0 {
+			table, versions = 1, 6
+		}
+
+		entry, err := reader.Get(key)
+		if err != nil || entry == nil {
+			t.Fatalf("Get(%s) = %v, %v", key, entry, err)
+		}
+		if want := fmt.Sprintf("t%d-v2", table); string(entry.Value()) != want {
+			t.Errorf("Get(%s) = %s; want %s", key, entry.Value(), want)
+		}
+
+		history, err := reader.History(key)
+		if err != nil || len(history) != versions {
+			t.Errorf("len(History(%s)) = %d, %v; want %d", key, len(history), err, versions)
+		}
+	}
+
+	for _, key := range []string{"", "a", "key", "key0005", "key060", "z"} {
+		if entry, err := reader.Get(key); err != nil || entry != nil {
+			t.Errorf("Get(%q) = %v, %v; want nil", key, entry, err)
+		}
+	}
+}
+
+func TestSSReaderBlockCache(t *testing.T) {
+	dir := writeVersionedTables(t)
+
+	reader, _ := NewSSReader(dir, comparator.Bytewise)
+	checkReader(t, reader)
+
+	blocks := NewBlockCache(1 << 20)
+	reader.SetBlockCache(blocks)
+	checkReader(t, reader)
+
+	misses := blocks.Stats().Misses
+	if misses == 0 || blocks.SizeBytes() == 0 {
+		t.Fatalf("no blocks were cached: %+v", blocks.Stats())
+	}
+
+	// every block was read before, so the tables are served from the cache
+	checkReader(t, reader)
+	if stats := blocks.Stats(); stats.Misses != misses || stats.Hits == 0 {
+		t.Errorf("Stats() = %+v; want %d misses", stats, misses)
+	}
+}
+
+func TestBlockCacheEvicts(t *testing.T) {
+	dir := writeVersionedTables(t)
+
+	blocks := NewBlockCache(BLOCK_CACHE_SHARDS * 512)
+	reader, _ := NewSSReader(dir, comparator.Bytewise)
+	reader.SetBlockCache(blocks)
+	checkReader(t, reader)
+
+	if blocks.Stats().Evictions == 0 {
+		t.Errorf("Stats() = %+v; want evictions", blocks.Stats())
+	}
+	if blocks.SizeBytes() > BLOCK_CACHE_SHARDS*512 {
+		t.Errorf("SizeBytes() = %d; want at most %d", blocks.SizeBytes(), BLOCK_CACHE_SHARDS*512)
+	}
+}
@@ -0,0 +1,123 @@
+package memtable
+
+import (
+	"NoSQLDB/lib/comparator"
+	"NoSQLDB/lib/skiplist"
+	"NoSQLDB/lib/utils"
+	"sync"
+	"sync/atomic"
+)
+
+// ConcurrentSkipListMemtable can be written and read by many goroutines at once.
+// Puts and gets go through the lock-free skip list, only range deletes take a lock.
+// The mempool writes it under its read lock, so reads of the mempool go on while entries are put.
+type ConcurrentSkipListMemtable struct {
+	data       *skiplist.ConcurrentSkipList
+	threshhold int
+	bytes      atomic.Int64
+	maxBytes   atomic.Int64
+
+	// the range tombstones are copied on write, so a returned slice is never modified
+	mu         sync.RWMutex
+	tombstones rangeTombstones
+}
+
+func NewConcurrentSkipListMemtable(threshold, maxLevel int) *ConcurrentSkipListMemtable {
+	return NewConcurrentSkipListMemtableWithComparator(threshold, maxLevel, comparator.Bytewise)
+}
+
+// NewConcurrentSkipListMemtableWithComparator creates a concurrent skip list memtable ordered by the comparator
+func NewConcurrentSkipListMemtableWithComparator(threshold, maxLevel int, cmp comparator.Comparator) *ConcurrentSkipListMemtable {
+	return &ConcurrentSkipListMemtable{
+		data:       skiplist.NewConcurrentSkipListWithComparator(maxLevel, cmp),
+		threshhold: threshold,
+		tombstones: rangeTombstones{cmp: cmp},
+	}
+}
+
+func (cm *ConcurrentSkipListMemtable) Put(key string, value []byte) error {
+	return cm.PutEntry(NewEntry(key, value, false))
+}
+
+func (cm *ConcurrentSkipListMemtable) PutEntry(entry *Entry) error {
+	old := cm.data.PutVersion(entry.key, entry.value, entry.tombstone, entry.merge, entry.pointer, entry.timestamp, entry.expiry)
+	delta := entry.sizeBytes()
+	if old != nil {
+		delta -= NodeToEntry(old).sizeBytes()
+	}
+	cm.bytes.Add(int64(delta))
+	return nil
+}
+
+func (cm *ConcurrentSkipListMemtable) Get(key string) (*Entry, error) {
+	node, _ := cm.data.Get(key)
+	return NodeToEntry(node), nil
+}
+
+func (cm *ConcurrentSkipListMemtable) PutBytes(key, value []byte) error {
+	return cm.Put(utils.BytesToString(key), value)
+}
+
+func (cm *ConcurrentSkipListMemtable) GetBytes(key []byte) (*Entry, error) {
+	return cm.Get(utils.BytesToString(key))
+}
+
+func (cm *ConcurrentSkipListMemtable) Delete(key string) error {
+	return cm.PutEntry(NewEntry(key, nil, true))
+}
+
+func (cm *ConcurrentSkipListMemtable) DeleteRange(rt *RangeTombstone) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	tombstones := rangeTombstones{
+		tombstones: append([]*RangeTombstone{}, cm.tombstones.tombstones...),
+		cmp:        cm.tombstones.cmp,
+	}
+	tombstones.DeleteRange(rt)
+	cm.tombstones = tombstones
+	return nil
+}
+
+func (cm *ConcurrentSkipListMemtable) RangeTombstones() []*RangeTombstone {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.tombstones.RangeTombstones()
+}
+
+func (cm *ConcurrentSkipListMemtable) Size() int {
+	return cm.data.Size()
+}
+
+func (cm *ConcurrentSkipListMemtable) SizeBytes() int {
+	return int(cm.bytes.Load())
+}
+
+func (cm *ConcurrentSkipListMemtable) SetMaxBytes(maxBytes int) {
+	cm.maxBytes.Store(int64(maxBytes))
+}
+
+func (cm *ConcurrentSkipListMemtable) IsFull() bool {
+	maxBytes := cm.maxBytes.Load()
+	return cm.Size() >= cm.threshhold || (maxBytes > 0 && cm.bytes.Load() >= maxBytes)
+}
+
+func (cm *ConcurrentSkipListMemtable) Iterator() Iterator {
+	it := cm.data.Iterator()
+	return &cursorIterator{
+		next:  it.Next,
+		entry: func() *Entry { return NodeToEntry(it.Node()) },
+	}
+}
+
+func (cm *ConcurrentSkipListMemtable) SortKeys() []string {
+	nodes := cm.data.Nodes()
+
+	keys := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		keys = append(keys, node.Key())
+	}
+
+	// the nodes are already ordered by the comparator of the skip list
+	return keys
+}
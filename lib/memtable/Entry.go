@@ -1,15 +1,29 @@
 package memtable
 
+import (
+	"NoSQLDB/lib/utils"
+	"time"
+)
+
 type Entry struct {
 	key       string
 	value     []byte
 	tombstone bool
+	merge     bool  // value holds merge operands which still have to be folded
+	pointer   bool  // value holds a pointer into the value log, see Resolve
+	timestamp int64 // unix time of the write in nanoseconds
+	expiry    int64 // unix time in nanoseconds after which the entry is absent, 0 if it never expires
 }
 
 func (e *Entry) Key() string {
 	return e.key
 }
 
+// KeyBytes returns the key without copying it, the returned slice must not be modified.
+func (e *Entry) KeyBytes() []byte {
+	return utils.StringToBytes(e.key)
+}
+
 func (e *Entry) Value() []byte {
 	return e.value
 }
@@ -18,6 +32,45 @@ func (e *Entry) Tombstone() bool {
 	return e.tombstone
 }
 
+// Merge reports whether the entry holds merge operands instead of a full value
+func (e *Entry) Merge() bool {
+	return e.merge
+}
+
+// Pointer reports whether the value was separated into the value log and the entry only holds a pointer to it
+func (e *Entry) Pointer() bool {
+	return e.pointer
+}
+
+func (e *Entry) Timestamp() int64 {
+	return e.timestamp
+}
+
+func (e *Entry) Expiry() int64 {
+	return e.expiry
+}
+
+// WrittenAt returns a copy of the entry with another write timestamp, used once the WAL has ordered the write
+func (e *Entry) WrittenAt(timestamp int64) *Entry {
+	entry := *e
+	entry.timestamp = timestamp
+	return &entry
+}
+
+// sizeBytes approximates the memory the entry takes up in a memtable
+func (e *Entry) sizeBytes() int {
+	return len(e.key) + len(e.value) + ENTRY_OVERHEAD
+}
+
+// ExpiredAt reports whether the entry has expired by the given unix time in nanoseconds.
+func (e *Entry) ExpiredAt(timestamp int64) bool {
+	return e.expiry != 0 && e.expiry <= timestamp
+}
+
+func (e *Entry) Expired() bool {
+	return e.ExpiredAt(time.Now().UnixNano())
+}
+
 // func (e *Entry) Serialize() []byte {
 // 	tombstone := make([]byte, TOMBSTONE_SIZE)
 
@@ -50,9 +103,29 @@ func (e *Entry) Tombstone() bool {
 //		}
 //	}
 func NewEntry(key string, value []byte, tombstone bool) *Entry {
+	return NewEntryAt(key, value, tombstone, time.Now().UnixNano(), 0)
+}
+
+// NewEntryFromBytes creates an entry whose key shares the memory of the byte slice instead of copying it.
+// The key must not be modified afterwards.
+func NewEntryFromBytes(key, value []byte, tombstone bool) *Entry {
+	return NewEntry(utils.BytesToString(key), value, tombstone)
+}
+
+// NewExpiringEntry creates an entry that is treated as absent after the given ttl.
+func NewExpiringEntry(key string, value []byte, ttl time.Duration) *Entry {
+	now := time.Now().UnixNano()
+	return NewEntryAt(key, value, false, now, now+int64(ttl))
+}
+
+// NewEntryAt creates an entry with an explicit write timestamp and expiry,
+// used when recovering entries from the WAL or reading them from sstables.
+func NewEntryAt(key string, value []byte, tombstone bool, timestamp, expiry int64) *Entry {
 	return &Entry{
 		key:       key,
 		value:     value,
 		tombstone: tombstone,
+		timestamp: timestamp,
+		expiry:    expiry,
 	}
 }
@@ -4,8 +4,32 @@ const (
 	KEY_SIZE_SIZE   = 4
 	VALUE_SIZE_SIZE = 4
 	TOMBSTONE_SIZE  = 1
+	TIMESTAMP_SIZE  = 8
+	EXPIRY_SIZE     = 8
 
-	USE_SKIP_LIST = "skip_list"
-	USE_BTREE     = "btree"
-	USE_MAP       = "map"
+	// kind of an sstable entry, stored in its tombstone byte
+	ENTRY_PUT       = 0
+	ENTRY_TOMBSTONE = 1
+	ENTRY_MERGE     = 2
+	ENTRY_POINTER   = 3
+
+	// approximate memory a memtable spends on an entry besides its key and value
+	ENTRY_OVERHEAD = 64
+
+	// file in the sstable directory holding the name of the comparator the tables are ordered by
+	COMPARATOR_FILE = "COMPARATOR"
+
+	// size of the slabs an arena skip list memtable allocates its nodes from
+	ARENA_SLAB_SIZE = 1 << 20
+
+	USE_SKIP_LIST            = "skip_list"
+	USE_ARENA_SKIP_LIST      = "arena_skip_list"
+	USE_CONCURRENT_SKIP_LIST = "concurrent_skip_list"
+	USE_BTREE                = "btree"
+	USE_MAP                  = "map"
+	USE_ART                  = "art"
+
+	// strategies picking the tables an automatic compaction merges
+	COMPACTION_FULL   = "full"   // every table
+	COMPACTION_TIERED = "tiered" // the newest tables of a similar size
 )
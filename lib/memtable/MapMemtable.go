@@ -1,28 +1,42 @@
 package memtable
 
 import (
+	"NoSQLDB/lib/comparator"
+	"NoSQLDB/lib/utils"
 	"errors"
-	"sort"
 )
 
 type MapMemtable struct {
+	rangeTombstones
+	memoryUsage
 	data       map[string]Entry
 	threshhold int
 }
 
 func NewMapMemtable(threshold int) *MapMemtable {
+	return NewMapMemtableWithComparator(threshold, comparator.Bytewise)
+}
+
+// NewMapMemtableWithComparator creates a map memtable whose keys are sorted with the comparator
+func NewMapMemtableWithComparator(threshold int, cmp comparator.Comparator) *MapMemtable {
 	return &MapMemtable{
-		data:       make(map[string]Entry),
-		threshhold: threshold,
+		rangeTombstones: rangeTombstones{cmp: cmp},
+		data:            make(map[string]Entry),
+		threshhold:      threshold,
 	}
 }
 
 func (m *MapMemtable) Put(key string, value []byte) error {
-	m.data[key] = Entry{
-		key:       key,
-		value:     value,
-		tombstone: false,
+	return m.PutEntry(NewEntry(key, value, false))
+}
+
+func (m *MapMemtable) PutEntry(entry *Entry) error {
+	if old, ok := m.data[entry.key]; ok {
+		m.replace(&old, entry)
+	} else {
+		m.replace(nil, entry)
 	}
+	m.data[entry.key] = *entry
 	return nil
 }
 
@@ -34,13 +48,16 @@ func (m *MapMemtable) Get(key string) (*Entry, error) {
 	return &value, nil
 }
 
+func (m *MapMemtable) PutBytes(key, value []byte) error {
+	return m.Put(utils.BytesToString(key), value)
+}
+
+func (m *MapMemtable) GetBytes(key []byte) (*Entry, error) {
+	return m.Get(utils.BytesToString(key))
+}
+
 func (m *MapMemtable) Delete(key string) error {
-	m.data[key] = Entry{
-		key:       key,
-		value:     nil,
-		tombstone: true,
-	}
-	return nil
+	return m.PutEntry(NewEntry(key, nil, true))
 }
 
 func (m *MapMemtable) Size() int {
@@ -48,7 +65,18 @@ func (m *MapMemtable) Size() int {
 }
 
 func (m *MapMemtable) IsFull() bool {
-	return m.Size() >= m.threshhold
+	return m.Size() >= m.threshhold || m.bytesFull()
+}
+
+// Iterator sorts the entries first, the map keeps no order
+func (m *MapMemtable) Iterator() Iterator {
+	keys := m.SortKeys()
+	entries := make([]*Entry, 0, len(keys))
+	for _, key := range keys {
+		entry := m.data[key]
+		entries = append(entries, &entry)
+	}
+	return newSliceIterator(entries)
 }
 
 // promeniti da vraca sortirane kljuceve pa onda preko Get() ih serijalizovati i zapisivati
@@ -57,6 +85,6 @@ func (memtable *MapMemtable) SortKeys() []string {
 	for key := range memtable.data {
 		keys = append(keys, key)
 	}
-	sort.Strings(keys)
+	sortKeys(memtable.cmp, keys)
 	return keys
 }
@@ -1,11 +1,16 @@
 package memtable
 
 import (
+	"NoSQLDB/lib/comparator"
+	"NoSQLDB/lib/utils"
 	"errors"
 	"fmt"
+	"sync"
 )
 
 type Mempool struct {
+	mu             sync.RWMutex // guards the tables and the active table, held alone while the tables rotate or flush
+	concurrent     bool         // the tables take concurrent writes, so they are written under the read lock
 	tableCount     int
 	tables         []Memtable
 	activeTableIdx int
@@ -13,69 +18,115 @@ type Mempool struct {
 	memtableType   string
 	minDegree      int
 	tableSize      int
+	tableSizeBytes int // 0 limits the tables only by their number of entries
 	maxLevel       int
+	versionPolicy  *VersionPolicy // nil when versioning is disabled
+	mergeOperator  MergeOperator
+	valueLog       ValueLog // resolves separated values merge operands are folded onto
+	cmp            comparator.Comparator
 }
 
 func NewMempool(
 	numTables, memtableSize, skipListMaxLevel, BTreeMinDegree int,
 	writer *SSWriter,
-	memtableType string) (*Mempool, error) {
-	memtables := make([]Memtable, numTables)
-	var err error
-	for i := 0; i < numTables; i++ {
-		switch memtableType {
-		case USE_BTREE:
-			memtables[i] = NewBTreeMemtable(BTreeMinDegree, memtableSize)
-		case USE_MAP:
-			memtables[i] = NewMapMemtable(memtableSize)
-		case USE_SKIP_LIST:
-			memtables[i] = NewSkipListMemtable(skipListMaxLevel, memtableSize)
-		default:
-			return nil, errors.New("invalid memtable type")
-		}
-	}
-
-	return &Mempool{
+	memtableType string,
+	versionPolicy *VersionPolicy,
+	cmp comparator.Comparator) (*Mempool, error) {
+	mp := &Mempool{
 		tableCount:     numTables,
-		tables:         memtables,
+		tables:         make([]Memtable, numTables),
 		activeTableIdx: 0,
 		writer:         writer,
 		memtableType:   memtableType,
 		minDegree:      BTreeMinDegree,
 		tableSize:      memtableSize,
 		maxLevel:       skipListMaxLevel,
-	}, err
+		versionPolicy:  versionPolicy,
+		cmp:            cmp,
+		// the versioned wrapper keeps the versions of a key outside of the table
+		concurrent: memtableType == USE_CONCURRENT_SKIP_LIST && versionPolicy == nil,
+	}
+
+	var err error
+	for i := 0; i < numTables; i++ {
+		mp.tables[i], err = mp.createEmptyMemtable()
+		if err != nil {
+			return nil, errors.New("invalid memtable type")
+		}
+	}
+
+	return mp, nil
 }
 
 func (mp *Mempool) createEmptyMemtable() (Memtable, error) {
+	var table Memtable
 	switch mp.memtableType {
 	case USE_BTREE:
-		return NewBTreeMemtable(mp.minDegree, mp.tableSize), nil
+		table = NewBTreeMemtableWithComparator(mp.minDegree, mp.tableSize, mp.cmp)
 	case USE_MAP:
-		return NewMapMemtable(mp.tableSize), nil
+		table = NewMapMemtableWithComparator(mp.tableSize, mp.cmp)
+	case USE_ART:
+		table = NewARTMemtableWithComparator(mp.tableSize, mp.cmp)
 	case USE_SKIP_LIST:
-		return NewSkipListMemtable(mp.maxLevel, mp.tableSize), nil
+		table = NewSkipListMemtableWithComparator(mp.tableSize, mp.maxLevel, mp.cmp)
+	case USE_ARENA_SKIP_LIST:
+		table = NewArenaSkipListMemtableWithComparator(mp.tableSize, mp.maxLevel, mp.cmp)
+	case USE_CONCURRENT_SKIP_LIST:
+		table = NewConcurrentSkipListMemtableWithComparator(mp.tableSize, mp.maxLevel, mp.cmp)
 	default:
 		return nil, fmt.Errorf("invalid memtable type")
 	}
 
+	if mp.versionPolicy != nil {
+		table = NewVersionedMemtable(table, mp.versionPolicy)
+	}
+	table.SetMaxBytes(mp.tableSizeBytes)
+	return table, nil
 }
 
 func (mp *Mempool) rotateForward() {
 	mp.activeTableIdx = (mp.activeTableIdx + 1) % mp.tableCount
 }
 
+// GetBytes looks up the key without copying it.
+func (mp *Mempool) GetBytes(key []byte) (*Entry, error) {
+	return mp.Get(utils.BytesToString(key))
+}
+
 func (mp *Mempool) Get(key string) (*Entry, error) {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
 	for i := 0; i < mp.tableCount; i++ {
 		tableIdx := (mp.activeTableIdx - i + mp.tableCount) % mp.tableCount
 		entry, err := mp.tables[tableIdx].Get(key)
-		if err == nil {
+		if err == nil && entry != nil {
 			return entry, nil
 		}
 	}
 	return nil, errors.New("entry not found")
 }
 
+// History returns all versions of the key held in memory, newest first.
+func (mp *Mempool) History(key string) []*Entry {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	var versions []*Entry
+	for i := 0; i < mp.tableCount; i++ {
+		tableIdx := (mp.activeTableIdx - i + mp.tableCount) % mp.tableCount
+		if vm, ok := mp.tables[tableIdx].(Versioned); ok {
+			versions = append(versions, vm.History(key)...)
+			continue
+		}
+		entry, err := mp.tables[tableIdx].Get(key)
+		if err == nil && entry != nil {
+			versions = append(versions, entry)
+		}
+	}
+	return versions
+}
+
 // func (mp *Mempool) Exists(key string) (bool, int) {
 // 	for i := 0; i < mp.tableCount; i++ {
 // 		tableIdx := (mp.activeTableIdx - i + mp.tableCount) % mp.tableCount // the addition makes sure we dont get negative numbers
@@ -110,52 +161,181 @@ func (mp *Mempool) shouldFlush() bool {
 	}
 */
 
+func (mp *Mempool) SetMergeOperator(op MergeOperator) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.mergeOperator = op
+}
+
+// SetTableSizeBytes limits the memory every table may use, in addition to its number of entries
+func (mp *Mempool) SetTableSizeBytes(maxBytes int) {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	mp.tableSizeBytes = maxBytes
+	for _, table := range mp.tables {
+		table.SetMaxBytes(maxBytes)
+	}
+}
+
+func (mp *Mempool) SetValueLog(vlog ValueLog) {
+	mp.valueLog = vlog
+}
+
+// mergeInto combines a merge entry with the version of the key already in the active table.
+// Operands are folded into a full value right away if one is there, otherwise they are accumulated.
+func (mp *Mempool) mergeInto(entry *Entry) (*Entry, error) {
+	existing, err := mp.tables[mp.activeTableIdx].Get(entry.Key())
+	if err != nil || existing == nil {
+		return entry, nil
+	}
+
+	if existing.Merge() || mp.mergeOperator == nil {
+		return combineMerges(existing, entry), nil
+	}
+
+	existing, err = existing.Resolve(mp.valueLog)
+	if err != nil {
+		return nil, err
+	}
+	return Fold(mp.mergeOperator, []*Entry{entry, existing})
+}
+
+// Put writes the entry to the active table.
+// Tables taking concurrent writes are written under the read lock, only rotating a full table takes the lock alone.
 func (mp *Mempool) Put(entry *Entry) error {
-	err := mp.tables[mp.activeTableIdx].Put(entry.Key(), entry.Value())
+	if mp.concurrent && !entry.Merge() {
+		mp.mu.RLock()
+		table := mp.tables[mp.activeTableIdx]
+		err := table.PutEntry(entry)
+		full := table.IsFull()
+		mp.mu.RUnlock()
+		if err != nil || !full {
+			return err
+		}
+
+		mp.mu.Lock()
+		defer mp.mu.Unlock()
+
+		// another writer filling the same table may have rotated it already
+		if mp.tables[mp.activeTableIdx] != table {
+			return nil
+		}
+		return mp.rotate()
+	}
+
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	if entry.Merge() {
+		var err error
+		entry, err = mp.mergeInto(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := mp.tables[mp.activeTableIdx].PutEntry(entry)
 
 	if err != nil {
 		return err
 	}
 
 	if mp.tables[mp.activeTableIdx].IsFull() {
-		mp.rotateForward()
+		return mp.rotate()
+	}
 
-		if mp.shouldFlush() {
-			err := mp.writer.Flush(mp.tables[mp.activeTableIdx])
-			if err != nil {
-				return nil
-			}
-			mp.tables[mp.activeTableIdx], err = mp.createEmptyMemtable()
-			if err != nil {
-				return err
-			}
+	return nil
+}
+
+// rotate moves on from the full active table, flushing the next one once every table is full
+func (mp *Mempool) rotate() error {
+	mp.rotateForward()
+
+	if mp.shouldFlush() {
+		err := mp.writer.Flush(mp.tables[mp.activeTableIdx])
+		if err != nil {
+			return err
+		}
+		mp.tables[mp.activeTableIdx], err = mp.createEmptyMemtable()
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
-// logical delete
-func (mp *Mempool) Delete(key string) error {
-	err := mp.Put(&Entry{key, nil, true})
-	if err != nil {
-		return err
+// FlushAll writes every table holding entries or range tombstones to an sstable, oldest first,
+// and replaces it with an empty one
+func (mp *Mempool) FlushAll() error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	// the tables after the active one were filled before it
+	for i := 1; i <= mp.tableCount; i++ {
+		tableIdx := (mp.activeTableIdx + i) % mp.tableCount
+		table := mp.tables[tableIdx]
+		if table.Size() == 0 && len(table.RangeTombstones()) == 0 {
+			continue
+		}
+
+		if err := mp.writer.Flush(table); err != nil {
+			return err
+		}
+		var err error
+		mp.tables[tableIdx], err = mp.createEmptyMemtable()
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	if mp.tables[mp.activeTableIdx].IsFull() {
-		mp.rotateForward()
+// Scan returns every version of the keys in [start, end) held in memory, newest table first.
+// Empty bounds leave the range open.
+func (mp *Mempool) Scan(start, end string) []*Entry {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
 
-		if mp.shouldFlush() {
-			err := mp.writer.Flush(mp.tables[mp.activeTableIdx])
-			if err != nil {
-				return nil
+	var entries []*Entry
+	for i := 0; i < mp.tableCount; i++ {
+		table := mp.tables[(mp.activeTableIdx-i+mp.tableCount)%mp.tableCount]
+		for it := table.Iterator(); it.Next(); {
+			position := rangePosition(mp.cmp, it.Entry().key, start, end)
+			if position > 0 {
+				break
 			}
-			mp.tables[mp.activeTableIdx], err = mp.createEmptyMemtable()
-			if err != nil {
-				return err
+			if position == 0 {
+				entries = append(entries, memtableVersions(table, it.Entry())...)
 			}
 		}
 	}
+	return entries
+}
 
-	return nil
+// DeleteRange stores a range tombstone in the active table
+func (mp *Mempool) DeleteRange(rt *RangeTombstone) error {
+	mp.mu.Lock()
+	defer mp.mu.Unlock()
+
+	return mp.tables[mp.activeTableIdx].DeleteRange(rt)
+}
+
+// RangeTombstones returns the range tombstones of all tables
+func (mp *Mempool) RangeTombstones() []*RangeTombstone {
+	mp.mu.RLock()
+	defer mp.mu.RUnlock()
+
+	var tombstones []*RangeTombstone
+	for _, table := range mp.tables {
+		tombstones = append(tombstones, table.RangeTombstones()...)
+	}
+	return tombstones
+}
+
+// logical delete, the tombstone rotates and flushes the tables like any other entry
+func (mp *Mempool) Delete(key string) error {
+	return mp.Put(NewEntry(key, nil, true))
 }
@@ -1,7 +1,11 @@
 package memtable
 
 import (
+	"NoSQLDB/lib/comparator"
 	"errors"
+	"fmt"
+	"sync"
+	"testing"
 )
 
 // Mock implementations for Memtable, Entry, etc. for testing
@@ -35,3 +39,59 @@ func (m *MockMemtable) Flush() error {
 }
 
 // Tests for Mempool
+
+// TestMempoolConcurrentPuts puts keys from many goroutines while others read them back from the mempool and the sstables,
+// the small tables rotate, flush and get compacted meanwhile, run it with -race
+func TestMempoolConcurrentPuts(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writer, err := NewSSWriter(dir, 2, 2, 100, 0.01, 2, nil, comparator.Bytewise)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mempool, err := NewMempool(2, 20, 8, 4, writer, USE_CONCURRENT_SKIP_LIST, nil, comparator.Bytewise)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, _ := NewSSReader(dir, comparator.Bytewise)
+	reader.SetTablesLock(writer.TablesLock())
+
+	get := func(key string) (*Entry, error) {
+		if entry, err := mempool.Get(key); err == nil {
+			return entry, nil
+		}
+		return reader.Get(key)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("key%d-%02d", g, i)
+				if err := mempool.Put(NewEntry(key, []byte(key), false)); err != nil {
+					t.Error(err)
+					return
+				}
+				// a key stays visible while its table is flushed and compacted
+				if entry, err := get(key); err != nil || entry == nil || string(entry.Value()) != key {
+					t.Errorf("get(%s) = %v, %v after the put", key, entry, err)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if err := mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	for g := 0; g < 8; g++ {
+		for i := 0; i < 50; i++ {
+			key := fmt.Sprintf("key%d-%02d", g, i)
+			if entry, err := reader.Get(key); err != nil || entry == nil || string(entry.Value()) != key {
+				t.Errorf("SSReader.Get(%s) = %v, %v after the flush", key, entry, err)
+			}
+		}
+	}
+}
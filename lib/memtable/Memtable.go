@@ -1,10 +1,116 @@
 package memtable
 
+import (
+	"NoSQLDB/lib/comparator"
+	"sort"
+)
+
 type Memtable interface {
 	Put(key string, value []byte) error
+	PutEntry(entry *Entry) error
+	// PutBytes and GetBytes use the key without copying it, a put key must not be modified afterwards
+	PutBytes(key, value []byte) error
 	Get(key string) (*Entry, error)
+	GetBytes(key []byte) (*Entry, error)
 	Delete(key string) error
+	DeleteRange(rt *RangeTombstone) error
+	RangeTombstones() []*RangeTombstone
 	Size() int
+	// SizeBytes returns the memory used by the keys and values of the memtable, including a fixed overhead per entry
+	SizeBytes() int
+	// SetMaxBytes makes the memtable full once it uses the given number of bytes, 0 leaves it unbounded in bytes
+	SetMaxBytes(maxBytes int)
 	IsFull() bool
 	SortKeys() []string
+	// Iterator walks the entries of the memtable ordered by key, tombstones included
+	Iterator() Iterator
+}
+
+// Iterator walks the entries of a memtable. It starts before the first entry.
+type Iterator interface {
+	// Next moves to the next entry, it returns false once there are none left
+	Next() bool
+	Entry() *Entry
+}
+
+// cursorIterator adapts the iterator of an ordered data structure to the entries of a memtable
+type cursorIterator struct {
+	next  func() bool
+	entry func() *Entry
+}
+
+func (it *cursorIterator) Next() bool {
+	return it.next()
+}
+
+func (it *cursorIterator) Entry() *Entry {
+	return it.entry()
+}
+
+// sliceIterator walks entries which were collected in order beforehand
+type sliceIterator struct {
+	entries []*Entry
+	index   int
+}
+
+func newSliceIterator(entries []*Entry) *sliceIterator {
+	return &sliceIterator{entries: entries, index: -1}
+}
+
+func (it *sliceIterator) Next() bool {
+	if it.index < len(it.entries) {
+		it.index++
+	}
+	return it.index < len(it.entries)
+}
+
+func (it *sliceIterator) Entry() *Entry {
+	return it.entries[it.index]
+}
+
+// memoryUsage accounts the bytes held by a memtable.
+// Memtables embed it to implement SizeBytes and SetMaxBytes.
+type memoryUsage struct {
+	bytes    int
+	maxBytes int // 0 leaves the memtable unbounded in bytes
+}
+
+func (mu *memoryUsage) SizeBytes() int {
+	return mu.bytes
+}
+
+func (mu *memoryUsage) SetMaxBytes(maxBytes int) {
+	mu.maxBytes = maxBytes
+}
+
+// replace accounts for the entry overwriting the old one, which is nil if the key was not present
+func (mu *memoryUsage) replace(old, entry *Entry) {
+	if old != nil {
+		mu.bytes -= old.sizeBytes()
+	}
+	mu.bytes += entry.sizeBytes()
+}
+
+// bytesFull reports whether the memtable reached its size in bytes
+func (mu *memoryUsage) bytesFull() bool {
+	return mu.maxBytes > 0 && mu.bytes >= mu.maxBytes
+}
+
+// sortKeys orders the keys with the comparator
+func sortKeys(cmp comparator.Comparator, keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		return cmp.Compare(keys[i], keys[j]) < 0
+	})
+}
+
+// rangePosition reports whether the key lies before (-1), within (0) or after (1) the range [start, end).
+// Empty bounds leave the range open.
+func rangePosition(cmp comparator.Comparator, key, start, end string) int {
+	if start != "" && cmp.Compare(key, start) < 0 {
+		return -1
+	}
+	if end != "" && cmp.Compare(key, end) >= 0 {
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,174 @@
+package memtable
+
+import (
+	"NoSQLDB/lib/comparator"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMemtableSortKeysWithComparator(t *testing.T) {
+	tables := []Memtable{
+		NewMapMemtableWithComparator(10, comparator.Reverse),
+		NewSkipListMemtableWithComparator(10, 8, comparator.Reverse),
+		NewArenaSkipListMemtableWithComparator(10, 8, comparator.Reverse),
+		NewConcurrentSkipListMemtableWithComparator(10, 8, comparator.Reverse),
+		NewARTMemtableWithComparator(10, comparator.Reverse),
+		NewBTreeMemtableWithComparator(2, 10, comparator.Reverse),
+	}
+
+	for _, table := range tables {
+		for _, key := range []string{"b", "d", "a", "e", "c"} {
+			table.Put(key, []byte(key))
+		}
+
+		keys := table.SortKeys()
+		if strings.Join(keys, "") != "edcba" {
+			t.Errorf("SortKeys() of %T = %v; want [e d c b a]", table, keys)
+		}
+		if entry, _ := table.Get("d"); entry == nil || string(entry.Value()) != "d" {
+			t.Errorf("Get(d) of %T = %v; want d", table, entry)
+		}
+	}
+}
+
+func TestMemtableBinaryKeys(t *testing.T) {
+	tables := []Memtable{
+		NewMapMemtable(10),
+		NewSkipListMemtable(10, 8),
+		NewArenaSkipListMemtable(10, 8),
+		NewConcurrentSkipListMemtable(10, 8),
+		NewARTMemtable(10),
+		NewBTreeMemtable(2, 10),
+	}
+
+	keys := [][]byte{{0x00, 0xff}, {0x00}, {0xff, 0x00, 0x01}, {0x7f}}
+	for _, table := range tables {
+		for i, key := range keys {
+			if err := table.PutBytes(key, []byte{byte(i)}); err != nil {
+				t.Fatalf("PutBytes(%x) of %T: %v", key, table, err)
+			}
+		}
+
+		for i, key := range keys {
+			entry, _ := table.GetBytes(append([]byte{}, key...))
+			if entry == nil || !bytes.Equal(entry.Value(), []byte{byte(i)}) || !bytes.Equal(entry.KeyBytes(), key) {
+				t.Errorf("GetBytes(%x) of %T = %v; want value %d", key, table, entry, i)
+			}
+		}
+
+		sorted := table.SortKeys()
+		want := []string{"\x00", "\x00\xff", "\x7f", "\xff\x00\x01"}
+		if strings.Join(sorted, ",") != strings.Join(want, ",") {
+			t.Errorf("SortKeys() of %T = %q; want %q", table, sorted, want)
+		}
+	}
+}
+
+func TestMemtableSizeBytes(t *testing.T) {
+	tables := []Memtable{
+		NewMapMemtable(100),
+		NewSkipListMemtable(100, 8),
+		NewConcurrentSkipListMemtable(100, 8),
+		NewARTMemtable(100),
+		NewBTreeMemtable(2, 100),
+	}
+
+	for _, table := range tables {
+		table.SetMaxBytes(1000)
+
+		table.Put("a", make([]byte, 300))
+		table.Put("a", make([]byte, 300))
+		if got, want := table.SizeBytes(), 1+300+ENTRY_OVERHEAD; got != want {
+			t.Errorf("SizeBytes() of %T after overwriting = %d; want %d", table, got, want)
+		}
+		if table.IsFull() {
+			t.Errorf("IsFull() of %T = true; want false below the limit", table)
+		}
+
+		table.Put("b", make([]byte, 600))
+		if !table.IsFull() {
+			t.Errorf("IsFull() of %T = false; want true at %d bytes", table, table.SizeBytes())
+		}
+	}
+
+	// overwritten versions kept in the history count as well
+	versioned := NewVersionedMemtable(NewMapMemtable(100), NewVersionPolicy(2, 0))
+	versioned.SetMaxBytes(1000)
+	versioned.Put("a", make([]byte, 300))
+	versioned.Put("a", make([]byte, 300))
+	if got, want := versioned.SizeBytes(), 2*(1+300+ENTRY_OVERHEAD); got != want {
+		t.Errorf("SizeBytes() of VersionedMemtable = %d; want %d", got, want)
+	}
+	versioned.Put("a", make([]byte, 300))
+	if got, want := versioned.SizeBytes(), 2*(1+300+ENTRY_OVERHEAD); got != want {
+		t.Errorf("SizeBytes() of VersionedMemtable beyond the retained versions = %d; want %d", got, want)
+	}
+}
+
+func TestBTreeMemtableIsFull(t *testing.T) {
+	table := NewBTreeMemtable(2, 3)
+	for _, key := range []string{"a", "b", "c"} {
+		if table.IsFull() {
+			t.Errorf("IsFull() = true before putting %s; want false", key)
+		}
+		table.Put(key, []byte(key))
+	}
+	if !table.IsFull() {
+		t.Errorf("IsFull() = false with %d entries; want true", table.Size())
+	}
+}
+
+func TestARTMemtableKeysWithPrefix(t *testing.T) {
+	table := NewARTMemtable(100)
+	for _, key := range []string{"user/2/name", "user/1/name", "user/10/name", "order/1", "user/1/email"} {
+		table.Put(key, []byte(key))
+	}
+	table.Delete("user/3/name")
+
+	keys := table.KeysWithPrefix("user/1")
+	want := []string{"user/1/email", "user/1/name", "user/10/name"}
+	if strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Errorf("KeysWithPrefix(user/1) = %v; want %v", keys, want)
+	}
+
+	if entry, _ := table.Get("user/3/name"); entry == nil || !entry.Tombstone() {
+		t.Errorf("Get(user/3/name) = %v; want a tombstone", entry)
+	}
+}
+
+func TestMemtableIterator(t *testing.T) {
+	tables := []Memtable{
+		NewMapMemtable(100),
+		NewSkipListMemtable(100, 8),
+		NewArenaSkipListMemtable(100, 8),
+		NewConcurrentSkipListMemtable(100, 8),
+		NewBTreeMemtable(2, 100),
+		NewARTMemtable(100),
+		NewVersionedMemtable(NewSkipListMemtable(100, 8), NewVersionPolicy(2, 0)),
+	}
+
+	for _, table := range tables {
+		for _, i := range []int{5, 3, 9, 1, 7, 0, 8, 2, 6, 4} {
+			table.Put(fmt.Sprintf("key%d", i), []byte{byte(i)})
+		}
+		// deleting a key the memtable never held still stores a tombstone
+		table.Delete("key3")
+		table.Delete("key99")
+
+		var keys []string
+		for it := table.Iterator(); it.Next(); {
+			entry := it.Entry()
+			keys = append(keys, entry.Key())
+			if tombstone := entry.Key() == "key3" || entry.Key() == "key99"; entry.Tombstone() != tombstone {
+				t.Errorf("Iterator() of %T at %s: tombstone %v; want %v", table, entry.Key(), entry.Tombstone(), tombstone)
+			}
+		}
+
+		want := "key0 key1 key2 key3 key4 key5 key6 key7 key8 key9 key99"
+		if strings.Join(keys, " ") != want {
+			t.Errorf("Iterator() of %T visited %v; want %s", table, keys, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package memtable
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// MergeOperator combines merge operands with the existing value of a key.
+type MergeOperator interface {
+	// Merge applies the operands, oldest first, to the existing value,
+	// which is nil if the key is absent, and returns the new value.
+	Merge(key string, existing []byte, operands [][]byte) ([]byte, error)
+}
+
+var ErrNoMergeOperator = errors.New("no merge operator registered")
+var ErrUnresolvedValue = errors.New("value has to be resolved from the value log before folding")
+var ErrCorruptOperands = errors.New("merge operands are corrupt")
+
+// NewMergeEntry creates an entry holding a single merge operand.
+func NewMergeEntry(key string, operand []byte) *Entry {
+	return NewMergeEntryAt(key, operand, time.Now().UnixNano())
+}
+
+// NewMergeEntryAt creates a merge entry with an explicit write timestamp.
+func NewMergeEntryAt(key string, operand []byte, timestamp int64) *Entry {
+	return &Entry{
+		key:       key,
+		value:     appendOperand(nil, operand),
+		merge:     true,
+		timestamp: timestamp,
+	}
+}
+
+// appendOperand appends a length prefixed operand to the encoded operand list.
+func appendOperand(operands, operand []byte) []byte {
+	operandLen := make([]byte, VALUE_SIZE_SIZE)
+	binary.BigEndian.PutUint32(operandLen, uint32(len(operand)))
+	operands = append(operands, operandLen...)
+	return append(operands, operand...)
+}
+
+// Operands decodes the merge operands of the entry, oldest first.
+// It returns ErrCorruptOperands if an operand runs past the end of the value.
+func (e *Entry) Operands() ([][]byte, error) {
+	var operands [][]byte
+	data := e.value
+	for len(data) > 0 {
+		if len(data) < VALUE_SIZE_SIZE {
+			return nil, ErrCorruptOperands
+		}
+		operandLen := int(binary.BigEndian.Uint32(data[:VALUE_SIZE_SIZE]))
+		data = data[VALUE_SIZE_SIZE:]
+		if operandLen > len(data) {
+			return nil, ErrCorruptOperands
+		}
+		operands = append(operands, data[:operandLen])
+		data = data[operandLen:]
+	}
+	return operands, nil
+}
+
+// combineMerges appends the operands of the newer merge entry to the older one.
+func combineMerges(older, newer *Entry) *Entry {
+	combined := *newer
+	combined.value = append(append([]byte{}, older.value...), newer.value...)
+	return &combined
+}
+
+// Fold resolves the newest of the versions, which are sorted from newest to oldest.
+// Leading merge entries are applied to the first full version below them.
+// It returns nil if there are no versions.
+func Fold(op MergeOperator, versions []*Entry) (*Entry, error) {
+	if len(versions) == 0 {
+		return nil, nil
+	}
+	if !versions[0].merge {
+		return versions[0], nil
+	}
+
+	if op == nil {
+		return nil, ErrNoMergeOperator
+	}
+
+	var operands [][]byte
+	var base *Entry
+	i := 0
+	for ; i < len(versions) && versions[i].merge; i++ {
+		entryOperands, err := versions[i].Operands()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(entryOperands, operands...)
+	}
+	if i < len(versions) {
+		base = versions[i]
+	}
+
+	var existing []byte
+	var expiry int64
+	if base != nil && !base.tombstone && !base.Expired() {
+		if base.pointer {
+			return nil, ErrUnresolvedValue
+		}
+		existing = base.value
+		expiry = base.expiry
+	}
+
+	value, err := op.Merge(versions[0].key, existing, operands)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewEntryAt(versions[0].key, value, false, versions[0].timestamp, expiry), nil
+}
+
+func hasMerge(versions []*Entry) bool {
+	for _, version := range versions {
+		if version.merge {
+			return true
+		}
+	}
+	return false
+}
+
+// foldChain folds every merge entry in the versions, sorted from newest to oldest, in place.
+func foldChain(op MergeOperator, versions []*Entry) error {
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !versions[i].merge {
+			continue
+		}
+		folded, err := Fold(op, versions[i:])
+		if err != nil {
+			return err
+		}
+		versions[i] = folded
+	}
+	return nil
+}
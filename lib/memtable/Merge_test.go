@@ -0,0 +1,166 @@
+package memtable
+
+import (
+	"NoSQLDB/lib/comparator"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// concatOperator appends the operands to the existing value
+type concatOperator struct{}
+
+func (concatOperator) Merge(key string, existing []byte, operands [][]byte) ([]byte, error) {
+	return append(append([]byte{}, existing...), bytes.Join(operands, nil)...), nil
+}
+
+func TestFold(t *testing.T) {
+	versions := []*Entry{
+		NewMergeEntryAt("key", []byte("c"), 4),
+		combineMerges(NewMergeEntryAt("key", []byte("a"), 2), NewMergeEntryAt("key", []byte("b"), 3)),
+		NewEntryAt("key", []byte("base-"), false, 1, 0),
+	}
+
+	entry, err := Fold(concatOperator{}, versions)
+	if err != nil {
+		t.Fatalf("Fold() = %v; want nil", err)
+	}
+	if string(entry.Value()) != "base-abc" || entry.Merge() {
+		t.Errorf("Fold() = %s (merge %v); want base-abc (merge false)", entry.Value(), entry.Merge())
+	}
+	if entry.Timestamp() != 4 {
+		t.Errorf("Fold().Timestamp() = %d; want 4", entry.Timestamp())
+	}
+}
+
+func TestFoldOnTombstone(t *testing.T) {
+	versions := []*Entry{
+		NewMergeEntryAt("key", []byte("x"), 2),
+		NewEntryAt("key", nil, true, 1, 0),
+	}
+
+	entry, err := Fold(concatOperator{}, versions)
+	if err != nil {
+		t.Fatalf("Fold() = %v; want nil", err)
+	}
+	if string(entry.Value()) != "x" {
+		t.Errorf("Fold() = %s; want x", entry.Value())
+	}
+}
+
+func TestFoldWithoutOperator(t *testing.T) {
+	_, err := Fold(nil, []*Entry{NewMergeEntry("key", []byte("x"))})
+	if err != ErrNoMergeOperator {
+		t.Errorf("Fold() = %v; want %v", err, ErrNoMergeOperator)
+	}
+}
+
+func TestOperandsCorrupt(t *testing.T) {
+	entry := NewMergeEntryAt("key", []byte("operand"), 1)
+	if operands, err := entry.Operands(); err != nil || len(operands) != 1 || string(operands[0]) != "operand" {
+		t.Fatalf("Operands() = %q, %v; want [operand]", operands, err)
+	}
+
+	for _, length := range []int{VALUE_SIZE_SIZE - 1, VALUE_SIZE_SIZE + 3} {
+		corrupt := NewEntryAt("key", entry.value[:length], false, 1, 0)
+		corrupt.merge = true
+		if _, err := corrupt.Operands(); err != ErrCorruptOperands {
+			t.Errorf("Operands() of %d bytes = %v; want %v", length, err, ErrCorruptOperands)
+		}
+		if _, err := Fold(concatOperator{}, []*Entry{corrupt}); err != ErrCorruptOperands {
+			t.Errorf("Fold() of %d bytes = %v; want %v", length, err, ErrCorruptOperands)
+		}
+	}
+}
+
+var errBrokenOperator = errors.New("broken operator")
+
+// brokenOperator fails every merge
+type brokenOperator struct{}
+
+func (brokenOperator) Merge(key string, existing []byte, operands [][]byte) ([]byte, error) {
+	return nil, errBrokenOperator
+}
+
+func TestFoldingErrorsAreReturned(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writer, err := NewSSWriter(dir, 2, 2, 100, 0.01, 0, nil, comparator.Bytewise)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, _ := NewSSReader(dir, comparator.Bytewise)
+
+	table := NewMapMemtable(10)
+	table.PutEntry(NewEntryAt("key", []byte("base"), false, 1, 0))
+	if err := writer.Flush(table); err != nil {
+		t.Fatal(err)
+	}
+
+	writer.SetMergeOperator(brokenOperator{})
+	table = NewMapMemtable(10)
+	table.PutEntry(NewMergeEntryAt("key", []byte("operand"), 2))
+	if err := writer.Flush(table); !errors.Is(err, errBrokenOperator) {
+		t.Fatalf("Flush() = %v; want %v", err, errBrokenOperator)
+	}
+	// the incomplete table was removed
+	if history, err := reader.History("key"); err != nil || len(history) != 1 {
+		t.Errorf("len(History(key)) = %d, %v after the failed flush; want 1", len(history), err)
+	}
+
+	writer.SetMergeOperator(nil)
+	if err := writer.Flush(table); err != nil {
+		t.Fatal(err)
+	}
+	writer.SetMergeOperator(brokenOperator{})
+	if err := writer.Compact(); !errors.Is(err, errBrokenOperator) {
+		t.Fatalf("Compact() = %v; want %v", err, errBrokenOperator)
+	}
+	if history, err := reader.History("key"); err != nil || len(history) != 2 {
+		t.Errorf("len(History(key)) = %d, %v after the failed compaction; want 2", len(history), err)
+	}
+}
+
+func TestFlushFoldsOntoStoredBase(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writer, err := NewSSWriter(dir, 2, 2, 100, 0.01, 0, nil, comparator.Bytewise)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.SetMergeOperator(concatOperator{})
+	reader, _ := NewSSReader(dir, comparator.Bytewise)
+
+	for i, entry := range []*Entry{NewEntryAt("key", []byte("base-"), false, 1, 0), NewMergeEntryAt("key", []byte("a"), 2)} {
+		table := NewMapMemtable(10)
+		table.PutEntry(entry)
+		if err := writer.Flush(table); err != nil {
+			t.Fatalf("Flush(%d) = %v", i, err)
+		}
+	}
+
+	entry, err := reader.Get("key")
+	if err != nil || entry.Merge() || string(entry.Value()) != "base-a" {
+		t.Errorf("Get(key) = %v, %v; want the folded value base-a", entry, err)
+	}
+}
+
+// TestPartialCompactionKeepsOperands compacts operands whose base may be in a table left out of the compaction
+func TestPartialCompactionKeepsOperands(t *testing.T) {
+	writer, err := NewSSWriter(t.TempDir()+"/", 2, 2, 100, 0.01, 0, nil, comparator.Bytewise)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.SetMergeOperator(concatOperator{})
+	operands := func() []*Entry {
+		return []*Entry{NewMergeEntryAt("key", []byte("b"), 3), NewMergeEntryAt("key", []byte("a"), 2)}
+	}
+
+	versions, err := writer.compactVersions(operands(), false)
+	if err != nil || len(versions) != 2 || !versions[0].Merge() || !versions[1].Merge() {
+		t.Errorf("compactVersions() of a partial compaction = %v, %v; want both operands", versions, err)
+	}
+
+	versions, err = writer.compactVersions(operands(), true)
+	if err != nil || len(versions) != 1 || versions[0].Merge() || string(versions[0].Value()) != "ab" {
+		t.Errorf("compactVersions() of a full compaction = %v, %v; want the operands folded into ab", versions, err)
+	}
+}
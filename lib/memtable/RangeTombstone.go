@@ -0,0 +1,93 @@
+package memtable
+
+import (
+	"NoSQLDB/lib/comparator"
+	"sort"
+	"time"
+)
+
+// RangeTombstone deletes every key in [start, end) written before it.
+type RangeTombstone struct {
+	start     string
+	end       string
+	timestamp int64
+}
+
+func NewRangeTombstone(start, end string) *RangeTombstone {
+	return NewRangeTombstoneAt(start, end, time.Now().UnixNano())
+}
+
+func NewRangeTombstoneAt(start, end string, timestamp int64) *RangeTombstone {
+	return &RangeTombstone{
+		start:     start,
+		end:       end,
+		timestamp: timestamp,
+	}
+}
+
+func (rt *RangeTombstone) Start() string {
+	return rt.start
+}
+
+func (rt *RangeTombstone) End() string {
+	return rt.end
+}
+
+func (rt *RangeTombstone) Timestamp() int64 {
+	return rt.timestamp
+}
+
+// Contains reports whether the key lies in the range, ordered by the comparator.
+func (rt *RangeTombstone) Contains(cmp comparator.Comparator, key string) bool {
+	return cmp.Compare(rt.start, key) <= 0 && cmp.Compare(key, rt.end) < 0
+}
+
+// Covers reports whether the entry was deleted by the range tombstone.
+func (rt *RangeTombstone) Covers(cmp comparator.Comparator, entry *Entry) bool {
+	return rt.Contains(cmp, entry.key) && entry.timestamp < rt.timestamp
+}
+
+// IsCovered reports whether any of the range tombstones deleted the entry.
+func IsCovered(cmp comparator.Comparator, tombstones []*RangeTombstone, entry *Entry) bool {
+	for _, rt := range tombstones {
+		if rt.Covers(cmp, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// DropCovered returns the versions which were not deleted by any of the range tombstones.
+func DropCovered(cmp comparator.Comparator, tombstones []*RangeTombstone, versions []*Entry) []*Entry {
+	if len(tombstones) == 0 {
+		return versions
+	}
+	live := make([]*Entry, 0, len(versions))
+	for _, version := range versions {
+		if !IsCovered(cmp, tombstones, version) {
+			live = append(live, version)
+		}
+	}
+	return live
+}
+
+// rangeTombstones keeps the range tombstones of a memtable ordered by their start key.
+// Memtables embed it to implement DeleteRange and RangeTombstones.
+type rangeTombstones struct {
+	tombstones []*RangeTombstone
+	cmp        comparator.Comparator
+}
+
+func (rts *rangeTombstones) DeleteRange(rt *RangeTombstone) error {
+	i := sort.Search(len(rts.tombstones), func(i int) bool {
+		return rts.cmp.Compare(rts.tombstones[i].start, rt.start) > 0
+	})
+	rts.tombstones = append(rts.tombstones, nil)
+	copy(rts.tombstones[i+1:], rts.tombstones[i:])
+	rts.tombstones[i] = rt
+	return nil
+}
+
+func (rts *rangeTombstones) RangeTombstones() []*RangeTombstone {
+	return rts.tombstones
+}
@@ -0,0 +1,45 @@
+package memtable
+
+import (
+	"NoSQLDB/lib/comparator"
+	"testing"
+)
+
+func TestRangeTombstoneCovers(t *testing.T) {
+	rt := NewRangeTombstoneAt("b", "d", 10)
+
+	tests := []struct {
+		entry *Entry
+		want  bool
+	}{
+		{NewEntryAt("a", nil, false, 5, 0), false},
+		{NewEntryAt("b", nil, false, 5, 0), true},
+		{NewEntryAt("c", nil, false, 5, 0), true},
+		{NewEntryAt("c", nil, false, 15, 0), false},
+		{NewEntryAt("d", nil, false, 5, 0), false},
+	}
+
+	for _, test := range tests {
+		if got := rt.Covers(comparator.Bytewise, test.entry); got != test.want {
+			t.Errorf("Covers(%s@%d) = %v; want %v", test.entry.Key(), test.entry.Timestamp(), got, test.want)
+		}
+	}
+}
+
+func TestMemtableDeleteRange(t *testing.T) {
+	tables := []Memtable{
+		NewMapMemtable(10),
+		NewSkipListMemtable(10, 8),
+		NewBTreeMemtable(2, 10),
+	}
+
+	for _, table := range tables {
+		table.DeleteRange(NewRangeTombstoneAt("m", "p", 2))
+		table.DeleteRange(NewRangeTombstoneAt("a", "c", 1))
+
+		tombstones := table.RangeTombstones()
+		if len(tombstones) != 2 || tombstones[0].Start() != "a" || tombstones[1].Start() != "m" {
+			t.Errorf("RangeTombstones() of %T is not ordered by start key", table)
+		}
+	}
+}
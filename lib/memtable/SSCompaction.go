@@ -0,0 +1,260 @@
+package memtable
+
+import (
+	"fmt"
+	"os"
+)
+
+// CompactionHook derives entries from the live entries of a compaction, which are ordered by key.
+// The derived entries are written to the compacted table, replacing any versions of their keys.
+type CompactionHook func(live []*Entry) []*Entry
+
+func (wr *SSWriter) AddCompactionHook(hook CompactionHook) {
+	wr.compactionHooks = append(wr.compactionHooks, hook)
+}
+
+// SetCompactionStrategy sets the strategy which picks the tables an automatic compaction merges,
+// COMPACTION_FULL or COMPACTION_TIERED. Compact always merges every table.
+func (wr *SSWriter) SetCompactionStrategy(strategy string) {
+	wr.compactionStrategy = strategy
+}
+
+// compactIfNeeded compacts the tables once there are at least 'compactionThreshold' of them.
+func (wr *SSWriter) compactIfNeeded() error {
+	if wr.compactionThreshold <= 0 {
+		return nil
+	}
+
+	reader := &SSReader{dirPath: wr.outputDir, cmp: wr.cmp}
+	numberGroups, err := reader.groupFilesByNumber()
+	if err != nil {
+		return err
+	}
+
+	if len(numberGroups) < wr.compactionThreshold {
+		return nil
+	}
+
+	if wr.compactionStrategy == COMPACTION_TIERED {
+		run, err := tieredRun(numberGroups)
+		if err != nil {
+			return err
+		}
+		return wr.compactTables(run, len(run) == len(numberGroups))
+	}
+	return wr.compactTables(numberGroups, true)
+}
+
+// tieredRun picks the tables a tiered compaction merges. Starting from the newest table,
+// an older table joins the run as long as its data is no larger than the data of the tables which joined before it.
+// At least the two newest tables are merged, so every compaction lowers the number of tables.
+// The run always holds the newest tables, so the merged table, which gets the next generation, is still newer than the rest.
+func tieredRun(numberGroups map[int][]string) (map[int][]string, error) {
+	run := make(map[int][]string)
+	var runSize int64
+	for _, number := range sortedNumbers(numberGroups) {
+		info, err := os.Stat(findFileName(numberGroups[number], "Data"))
+		if err != nil {
+			return nil, err
+		}
+		if len(run) >= 2 && info.Size() > runSize {
+			break
+		}
+		run[number] = numberGroups[number]
+		runSize += info.Size()
+	}
+	return run, nil
+}
+
+// Compact merges all tables into a single new table.
+// Merge operands are folded into full values, only the versions allowed by the version policy are kept,
+// expired entries are dropped, and so are deleted keys and keys covered by range tombstones
+// when versioning is disabled.
+// The merged tables are removed once the new table is written.
+func (wr *SSWriter) Compact() error {
+	wr.tables.Lock()
+	defer wr.tables.Unlock()
+
+	reader := &SSReader{dirPath: wr.outputDir, cmp: wr.cmp}
+	numberGroups, err := reader.groupFilesByNumber()
+	if err != nil {
+		return err
+	}
+
+	if len(numberGroups) == 0 {
+		return nil
+	}
+
+	return wr.compactTables(numberGroups, true)
+}
+
+// compactTables merges the given tables, which are the newest ones, into a single new table.
+// Only if they include the oldest table (bottom) nothing older can show through,
+// otherwise deletions, expired entries, range tombstones and merge operands without a base are kept,
+// and compaction hooks, which need every live entry, are not run.
+func (wr *SSWriter) compactTables(numberGroups map[int][]string, bottom bool) error {
+	var tombstones []*RangeTombstone
+	for _, files := range numberGroups {
+		rangeDelFileName := findFileName(files, "RangeDel")
+		if rangeDelFileName == "" {
+			continue
+		}
+		tableTombstones, err := readRangeTombstones(rangeDelFileName)
+		if err != nil {
+			return err
+		}
+		tombstones = append(tombstones, tableTombstones...)
+	}
+
+	// tables are read from the newest one, so versions of a key end up newest first
+	versions := make(map[string][]*Entry)
+	for _, number := range sortedNumbers(numberGroups) {
+		entries, err := readTable(findFileName(numberGroups[number], "Data"))
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			versions[entry.key] = append(versions[entry.key], entry)
+		}
+	}
+
+	// every older version is part of the compaction, so the covered keys can be dropped together with the range tombstones.
+	// With versioning the range tombstones are kept, so the covered versions stay in the history.
+	if wr.versionPolicy == nil && bottom {
+		for key := range versions {
+			versions[key] = DropCovered(wr.cmp, tombstones, versions[key])
+		}
+		tombstones = nil
+	}
+
+	keys := make([]string, 0, len(versions))
+	for key := range versions {
+		if len(versions[key]) == 0 {
+			continue
+		}
+		compacted, err := wr.compactVersions(versions[key], bottom)
+		if err != nil {
+			return err
+		}
+		versions[key] = compacted
+		if len(compacted) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	sortKeys(wr.cmp, keys)
+
+	if len(wr.compactionHooks) > 0 && bottom {
+		keys = wr.runCompactionHooks(keys, versions)
+	}
+
+	fileNames := wr.generateFilenames()
+	err := wr.generateFiles(fileNames)
+	if err != nil {
+		return err
+	}
+
+	next := func() ([]*Entry, bool, error) {
+		if len(keys) == 0 {
+			return nil, false, nil
+		}
+		key := keys[0]
+		keys = keys[1:]
+		return versions[key], true, nil
+	}
+	err = wr.writeToFiles(next, tombstones, fileNames)
+	if err != nil {
+		return err
+	}
+
+	for number, files := range numberGroups {
+		for _, file := range files {
+			if err := os.Remove(file); err != nil {
+				return err
+			}
+		}
+		wr.tombstones.remove(number)
+	}
+
+	return nil
+}
+
+// runCompactionHooks adds the entries derived by the hooks to the versions and returns the new ordered keys.
+func (wr *SSWriter) runCompactionHooks(keys []string, versions map[string][]*Entry) []string {
+	live := make([]*Entry, 0, len(keys))
+	for _, key := range keys {
+		latest := versions[key][0]
+		if latest.tombstone || latest.merge || latest.Expired() {
+			continue
+		}
+		// hooks read the values, entries which can't be resolved are left out
+		if resolved, err := latest.Resolve(wr.valueLog); err == nil {
+			live = append(live, resolved)
+		}
+	}
+
+	for _, hook := range wr.compactionHooks {
+		for _, derived := range hook(live) {
+			if len(versions[derived.key]) == 0 {
+				keys = append(keys, derived.key)
+			}
+			versions[derived.key] = []*Entry{derived}
+		}
+	}
+
+	sortKeys(wr.cmp, keys)
+	return keys
+}
+
+// compactVersions returns the versions of a key that survive compaction, newest first.
+// Without a merge operator, operands are kept together with their base.
+// bottom tells whether the oldest table takes part in the compaction.
+func (wr *SSWriter) compactVersions(versions []*Entry, bottom bool) ([]*Entry, error) {
+	SortVersions(versions)
+	versions = DedupVersions(versions)
+
+	// if every table takes part in the compaction, operands without a base are folded onto an absent key,
+	// otherwise their base may be in an older table
+	if wr.mergeOperator != nil && hasMerge(versions) && (bottom || !versions[len(versions)-1].merge) {
+		folded := append([]*Entry{}, versions...)
+		if err := ResolveMergeBases(wr.valueLog, folded); err != nil {
+			return nil, err
+		}
+		if err := foldChain(wr.mergeOperator, folded); err != nil {
+			return nil, fmt.Errorf("folding merge operands of key %q: %w", folded[0].key, err)
+		}
+		versions = folded
+	}
+
+	latest := versions[0]
+	if wr.versionPolicy == nil {
+		if bottom && (latest.tombstone || latest.Expired()) {
+			return nil, nil
+		}
+		// the deletion still hides the versions in older tables
+		if latest.tombstone {
+			return versions[:1], nil
+		}
+		if latest.Expired() {
+			return []*Entry{NewEntryAt(latest.key, nil, true, latest.expiry, 0)}, nil
+		}
+		i := 0
+		for i < len(versions)-1 && versions[i].merge {
+			i++
+		}
+		return versions[:i+1], nil
+	}
+
+	// an expired latest version is kept as a tombstone, so older versions don't come back
+	retained := []*Entry{latest}
+	if latest.Expired() {
+		retained[0] = NewEntryAt(latest.key, nil, true, latest.expiry, 0)
+	}
+
+	for _, version := range wr.versionPolicy.Retain(versions)[1:] {
+		if !version.Expired() {
+			retained = append(retained, version)
+		}
+	}
+
+	return retained, nil
+}
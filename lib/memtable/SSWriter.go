@@ -1,43 +1,92 @@
 package memtable
 
 import (
+	"NoSQLDB/lib/comparator"
 	"NoSQLDB/lib/pds"
+	"NoSQLDB/lib/utils"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type SSWriter struct {
-	outputDir     string
-	tableGen      int
-	filter        *pds.BloomFilter
-	indexStride   int
-	summaryStride int
+	outputDir           string
+	tableGen            int
+	filter              *pds.BloomFilter
+	indexStride         int
+	summaryStride       int
+	compactionThreshold int            // number of tables which triggers a compaction, 0 disables it
+	compactionStrategy  string         // picks the tables an automatic compaction merges, full by default
+	versionPolicy       *VersionPolicy // versions kept by compaction, nil keeps only the latest
+	mergeOperator       MergeOperator  // folds merge operands during flush and compaction
+	valueLog            ValueLog       // resolves separated values merge operands are folded onto
+	compactionHooks     []CompactionHook
+	tombstones          *TableTombstones      // range tombstones of the written tables, shared with the readers
+	tables              *sync.RWMutex         // held while tables are written and removed, shared with the readers
+	cmp                 comparator.Comparator // order of the keys within the tables
 }
 
+var ErrComparatorMismatch = errors.New("sstables were written with a different comparator")
+
 func NewSSWriter(outputDir string,
 	indexStride, summaryStride, expectedElements int,
-	falsePositiveRate float64) (*SSWriter, error) {
+	falsePositiveRate float64,
+	compactionThreshold int,
+	versionPolicy *VersionPolicy,
+	cmp comparator.Comparator) (*SSWriter, error) {
 	tableGen, err := generateTableGen(outputDir)
 	if err != nil {
 		return nil, err
 	}
 
+	err = checkComparator(outputDir, cmp, tableGen > 0)
+	if err != nil {
+		return nil, err
+	}
+
 	filter := pds.NewBloomFilter(expectedElements, falsePositiveRate)
 
 	return &SSWriter{
-		outputDir:     outputDir,
-		tableGen:      tableGen,
-		filter:        filter,
-		indexStride:   indexStride,
-		summaryStride: summaryStride,
+		outputDir:           outputDir,
+		tableGen:            tableGen,
+		filter:              filter,
+		indexStride:         indexStride,
+		summaryStride:       summaryStride,
+		compactionThreshold: compactionThreshold,
+		versionPolicy:       versionPolicy,
+		tombstones:          NewTableTombstones(),
+		tables:              &sync.RWMutex{},
+		cmp:                 cmp,
 	}, nil
 }
 
+// checkComparator records the comparator in the sstable directory on first use,
+// afterwards the directory can only be opened with the same comparator.
+// Tables written before comparators were recorded are ordered bytewise.
+func checkComparator(dirPath string, cmp comparator.Comparator, hasTables bool) error {
+	path := filepath.Join(dirPath, COMPARATOR_FILE)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		if hasTables && cmp.Name() != comparator.Bytewise.Name() {
+			return fmt.Errorf("%w: tables in %s are ordered by %s", ErrComparatorMismatch, dirPath, comparator.Bytewise.Name())
+		}
+		return os.WriteFile(path, []byte(cmp.Name()), 0644)
+	} else if err != nil {
+		return err
+	}
+
+	if string(data) != cmp.Name() {
+		return fmt.Errorf("%w: tables in %s are ordered by %s", ErrComparatorMismatch, dirPath, data)
+	}
+	return nil
+}
+
 func generateTableGen(dirPath string) (int, error) {
 	if _, err := os.Stat(dirPath); os.IsNotExist(err) {
 		err := os.Mkdir(dirPath, 0755)
@@ -46,7 +95,7 @@ func generateTableGen(dirPath string) (int, error) {
 		}
 		return 0, nil
 	}
-	maxNumber := 0
+	maxNumber := -1
 
 	files, err := os.ReadDir(dirPath)
 	if err != nil {
@@ -71,7 +120,8 @@ func generateTableGen(dirPath string) (int, error) {
 		}
 	}
 
-	return maxNumber, nil
+	// the next table gets the generation after the newest existing one
+	return maxNumber + 1, nil
 }
 
 // Flush writes data from the Memtable to SSTable (data, index, summary, filter, and metadata).
@@ -83,7 +133,11 @@ func generateTableGen(dirPath string) (int, error) {
 // 5. Records segment offsets in a separate file.
 // 6. Closes all files.
 // 7. Optionally deletes the intermediate files (if 'isSingleFile' is true).
+// 8. Compacts the tables if there are at least 'compactionThreshold' of them.
 func (wr *SSWriter) Flush(mt Memtable) error {
+	wr.tables.Lock()
+	defer wr.tables.Unlock()
+
 	// Generate filenames for data, index, summary, filter, and metadata files
 	fileNames := wr.generateFilenames()
 
@@ -93,18 +147,124 @@ func (wr *SSWriter) Flush(mt Memtable) error {
 		return err
 	}
 
-	// Write data, index entries, summary data, filter data, and metadata to the files
-	err = wr.writeToFiles(mt, fileNames)
+	// Write data, index entries, summary data, filter data, and metadata to the files in a single pass over the memtable
+	it := mt.Iterator()
+	next := func() ([]*Entry, bool, error) {
+		if !it.Next() {
+			return nil, false, nil
+		}
+		versions, err := wr.foldVersions(memtableVersions(mt, it.Entry()))
+		return versions, err == nil, err
+	}
+	err = wr.writeToFiles(next, mt.RangeTombstones(), fileNames)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return wr.compactIfNeeded()
+}
+
+func (wr *SSWriter) SetMergeOperator(op MergeOperator) {
+	wr.mergeOperator = op
+}
+
+func (wr *SSWriter) SetValueLog(vlog ValueLog) {
+	wr.valueLog = vlog
+}
+
+// TableTombstones returns the range tombstones of the tables the writer keeps up to date
+func (wr *SSWriter) TableTombstones() *TableTombstones {
+	return wr.tombstones
+}
+
+// Sync flushes the tables and the directory holding them to the disk.
+// The parent directory is synced as well, the directory of a column family is created in it.
+func (wr *SSWriter) Sync() error {
+	wr.tables.RLock()
+	defer wr.tables.RUnlock()
+
+	files, err := os.ReadDir(wr.outputDir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		if err := syncPath(filepath.Join(wr.outputDir, file.Name())); err != nil {
+			return err
+		}
+	}
+
+	if err := syncPath(wr.outputDir); err != nil {
+		return err
+	}
+	return syncPath(filepath.Dir(filepath.Clean(wr.outputDir)))
+}
+
+// syncPath flushes the file or directory to the disk
+func syncPath(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// TablesLock returns the lock the writer holds while it writes and removes tables
+func (wr *SSWriter) TablesLock() *sync.RWMutex {
+	return wr.tables
+}
+
+// foldVersions folds merge operands of the flushed versions of a key into full values,
+// using the versions already stored in sstables as their base.
+// Without a merge operator the operands are written as they are and get folded on read or during compaction.
+func (wr *SSWriter) foldVersions(versions []*Entry) ([]*Entry, error) {
+	if wr.mergeOperator == nil || !hasMerge(versions) {
+		return versions, nil
+	}
+
+	chain := append([]*Entry{}, versions...)
+	// the table being written is left out, there is no older one if it is the first
+	if chain[len(chain)-1].merge && wr.tableGen > 0 {
+		reader := &SSReader{dirPath: wr.outputDir, cmp: wr.cmp, below: wr.tableGen}
+		stored, err := reader.History(chain[0].key)
+		if err != nil {
+			return nil, err
+		}
+		// versions replayed from the WAL may already be stored, only the older ones are bases of the flushed operands
+		oldest := chain[len(chain)-1].timestamp
+		for _, version := range stored {
+			if version.timestamp < oldest {
+				chain = append(chain, version)
+			}
+		}
+	}
+
+	if err := ResolveMergeBases(wr.valueLog, chain); err != nil {
+		return nil, err
+	}
+	if err := foldChain(wr.mergeOperator, chain); err != nil {
+		return nil, fmt.Errorf("folding merge operands of key %q: %w", chain[0].key, err)
+	}
+	return chain[:len(versions)], nil
+}
+
+// memtableVersions returns the versions of the entry's key held by the memtable, newest first.
+func memtableVersions(mt Memtable, entry *Entry) []*Entry {
+	if vm, ok := mt.(Versioned); ok {
+		return DedupVersions(vm.History(entry.key))
+	}
+	return []*Entry{entry}
 }
 
 // generateFilenames creates a set of filenames for different components of a sstable.
 // It constructs filenames based on the sstable generation number (wr.tableGen) and the output directory.
-// The generated filenames include Data, Index, Summary, Filter, and Metadata files.
+// The generated filenames include Data, Index, Summary, Filter, and RangeDel files.
 func (wr *SSWriter) generateFilenames() []string {
 	fileNames := make([]string, 0)
 
@@ -121,8 +281,11 @@ func (wr *SSWriter) generateFilenames() []string {
 	fileNameFilter := fmt.Sprintf("usertable-%02d-Filter.txt", wr.tableGen)
 	fileNameFilter = filepath.Join(wr.outputDir, fileNameFilter)
 
+	fileNameRangeDel := fmt.Sprintf("usertable-%02d-RangeDel.txt", wr.tableGen)
+	fileNameRangeDel = filepath.Join(wr.outputDir, fileNameRangeDel)
+
 	// Add the generated filenames to the slice
-	fileNames = append(fileNames, fileNameData, fileNameIndex, fileNameSummary, fileNameFilter)
+	fileNames = append(fileNames, fileNameData, fileNameIndex, fileNameSummary, fileNameFilter, fileNameRangeDel)
 
 	return fileNames
 }
@@ -143,20 +306,34 @@ func (wr *SSWriter) generateFiles(fileNames []string) error {
 }
 
 // serializeEntry serializes an Entry (key-value pair) into a byte slice.
-// It constructs a binary representation that includes tombstone information,
-// key length, key data, value length, and value data.
+// It constructs a binary representation that includes tombstone information (the entry kind),
+// timestamp, expiry, key length, key data, value length, and value data.
 func (wr *SSWriter) serializeEntry(e Entry) []byte {
 	var data []byte
 	// Create a tombstone slice (initially all zeros)
 	tombstone := make([]byte, TOMBSTONE_SIZE)
 	if e.tombstone {
-		tombstone[0] = 1
+		tombstone[0] = ENTRY_TOMBSTONE
+	} else if e.merge {
+		tombstone[0] = ENTRY_MERGE
+	} else if e.pointer {
+		tombstone[0] = ENTRY_POINTER
 	} else {
-		tombstone[0] = 0
+		tombstone[0] = ENTRY_PUT
 	}
 
 	data = tombstone
 
+	timestampBytes := make([]byte, TIMESTAMP_SIZE)
+	binary.BigEndian.PutUint64(timestampBytes, uint64(e.timestamp))
+
+	data = append(data, timestampBytes...)
+
+	expiryBytes := make([]byte, EXPIRY_SIZE)
+	binary.BigEndian.PutUint64(expiryBytes, uint64(e.expiry))
+
+	data = append(data, expiryBytes...)
+
 	// Determine the length of the key
 	keyLen := uint32(len(e.key))
 	keyLenBytes := make([]byte, KEY_SIZE_SIZE)
@@ -183,6 +360,22 @@ func (wr *SSWriter) serializeEntry(e Entry) []byte {
 	return data
 }
 
+// serializeRangeTombstone serializes a range tombstone into timestamp,
+// start key length, start key, end key length and end key.
+func serializeRangeTombstone(rt *RangeTombstone) []byte {
+	data := make([]byte, TIMESTAMP_SIZE)
+	binary.BigEndian.PutUint64(data, uint64(rt.timestamp))
+
+	for _, key := range []string{rt.start, rt.end} {
+		keyLenBytes := make([]byte, KEY_SIZE_SIZE)
+		binary.BigEndian.PutUint32(keyLenBytes, uint32(len(key)))
+		data = append(data, keyLenBytes...)
+		data = append(data, utils.StringToBytes(key)...)
+	}
+
+	return data
+}
+
 // openFiles opens or creates a set of files with the specified names for writing.
 // It takes a slice of filenames as input and returns a slice of file pointers.
 // If any error occurs during file opening, it closes any previously opened files
@@ -207,58 +400,70 @@ func openFiles(fileNames []string) ([]*os.File, error) {
 }
 
 // writeToFiles orchestrates the process of writing data, index, summary, filter, and metadata files.
-// It takes a Memtable (presumably containing key-value pairs), a slice of file names, and performs the following steps:
+// It takes a function returning the versions of the next key (newest first) in order, until it reports there are none left,
+// the range tombstones and a slice of file names, and performs the following steps:
 // 1. Opens the necessary files (data, index, summary, filter, and metadata).
-// 2. Sorts the keys from the Memtable.
-// 3. Optionally compresses keys if 'isCompressed' is true.
-// 4. Serializes and writes each entry to the data file.
+// 2. Optionally compresses keys if 'isCompressed' is true.
+// 3. Serializes and writes all versions of each key to the data file.
 // 5. Writes index entries and summary data at specific intervals.
 // 6. Maintains data and index offsets.
-// 7. Writes filter data to the filter file.
+// 7. Writes filter data to the filter file and range tombstones to the range deletion file.
 // 8. Constructs and writes the serialized Merkle tree (metadata) to the metadata file.
 // 9. Closes all files when done.
-func (wr *SSWriter) writeToFiles(mt Memtable, fileNames []string) error {
+func (wr *SSWriter) writeToFiles(next func() ([]*Entry, bool, error), tombstones []*RangeTombstone, fileNames []string) error {
 	// Open necessary files (data, index, summary, filter, metadata)
 	files, err := openFiles(fileNames)
 	if err != nil {
 		return err
 	}
 
-	// Sort keys from the Memtable
-	sortedKeys := mt.SortKeys()
-
 	dataFile := files[0]
 	indexFile := files[1]
 	summaryFile := files[2]
 	filterFile := files[3]
+	rangeDelFile := files[4]
 
-	for i, key := range sortedKeys {
-		// Add key to the filter
-		wr.filter.Add(key)
-
-		// Get entry from the Memtable
-		entry, err := mt.Get(key)
+	for i := 0; ; i++ {
+		versions, ok, err := next()
 		if err != nil {
+			// an incomplete table is not kept, the next table is written with the same generation
+			closeFiles(files)
+			for _, name := range fileNames {
+				os.Remove(name)
+			}
 			return err
 		}
+		if !ok {
+			break
+		}
+		if len(versions) == 0 {
+			continue
+		}
+		key := versions[0].key
 
-		// Serialize the entry and write to the data file
-		serializedEntry := wr.serializeEntry(*entry)
-		entryLen, err := dataFile.Write(serializedEntry)
-		if err != nil {
-			return err
+		// Add key to the filter
+		wr.filter.Add(key)
+
+		// Serialize the versions and write them to the data file, newest first
+		entryLen := 0
+		for _, version := range versions {
+			versionLen, err := dataFile.Write(wr.serializeEntry(*version))
+			if err != nil {
+				return err
+			}
+			entryLen += versionLen
 		}
 
 		if (i+1)%wr.indexStride == 0 {
 			keyLenBuf := make([]byte, KEY_SIZE_SIZE)
 			binary.BigEndian.PutUint32(keyLenBuf, uint32(len(key)))
-			serializedKey := []byte(key)
+			serializedKey := utils.StringToBytes(key)
 
-			dataFile.Seek(int64(entryLen)*-1, io.SeekCurrent)
 			position, err := Tell(dataFile)
 			if err != nil {
 				return err
 			}
+			position -= entryLen
 			positionBuf := make([]byte, 4) // size of an int
 			binary.BigEndian.PutUint32(positionBuf, uint32(position))
 
@@ -297,12 +502,20 @@ func (wr *SSWriter) writeToFiles(mt Memtable, fileNames []string) error {
 
 	wr.filter.Clear()
 
+	for _, rt := range tombstones {
+		_, err := rangeDelFile.Write(serializeRangeTombstone(rt))
+		if err != nil {
+			return err
+		}
+	}
+
 	// Close all files
 	err = closeFiles(files)
 	if err != nil {
 		panic(err)
 	}
 
+	wr.tombstones.add(wr.tableGen, tombstones)
 	wr.tableGen++
 	return nil
 }
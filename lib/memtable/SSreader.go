@@ -1,7 +1,9 @@
 package memtable
 
 import (
+	"NoSQLDB/lib/comparator"
 	"NoSQLDB/lib/pds"
+	"NoSQLDB/lib/utils"
 	"encoding/binary"
 	"io"
 	"io/fs"
@@ -11,24 +13,78 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type SSReader struct {
-	dirPath string
+	dirPath    string
+	cmp        comparator.Comparator // order of the keys within the tables
+	blocks     *BlockCache           // nil reads every block from disk
+	below      int                   // only tables of older generations are read, 0 reads every table
+	tombstones *TableTombstones      // range tombstones of the tables, nil reads them from disk on every call
+	tables     *sync.RWMutex         // held while reading the tables, nil if no writer shares the directory
 }
 
-func NewSSReader(dirPath string) (*SSReader, error) {
+func NewSSReader(dirPath string, cmp comparator.Comparator) (*SSReader, error) {
 	return &SSReader{
 		dirPath: dirPath,
+		cmp:     cmp,
 	}, nil
 }
 
+// SetBlockCache sets the cache decoded summary, index and data blocks are kept in
+func (re *SSReader) SetBlockCache(blocks *BlockCache) {
+	re.blocks = blocks
+}
+
+// SetTableTombstones sets the range tombstones of the tables, kept up to date by the writer of the directory
+func (re *SSReader) SetTableTombstones(tombstones *TableTombstones) {
+	re.tombstones = tombstones
+}
+
+// SetTablesLock sets the lock the writer of the directory holds while it writes and removes tables
+func (re *SSReader) SetTablesLock(tables *sync.RWMutex) {
+	re.tables = tables
+}
+
+// readTables holds the tables lock for reading, if there is one, until the returned function is called
+func (re *SSReader) readTables() func() {
+	if re.tables == nil {
+		return func() {}
+	}
+	re.tables.RLock()
+	return re.tables.RUnlock
+}
+
+// GetBytes looks up the key without copying it.
+func (re *SSReader) GetBytes(key []byte) (*Entry, error) {
+	return re.Get(utils.BytesToString(key))
+}
+
 func (re *SSReader) Get(key string) (*Entry, error) {
+	versions, err := re.find(key, true)
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return versions[0], nil
+}
+
+// History returns every version of the key stored in sstables, newest first.
+func (re *SSReader) History(key string) ([]*Entry, error) {
+	return re.find(key, false)
+}
+
+// find walks the tables from the newest one and collects the versions of the key.
+// If latestOnly is set it stops at the first table containing the key.
+func (re *SSReader) find(key string, latestOnly bool) ([]*Entry, error) {
+	defer re.readTables()()
+
 	numberGroups, err := re.groupFilesByNumber()
 	if err != nil {
 		return nil, err
 	}
 
+	var versions []*Entry
 	sortedNumbers := sortedNumbers(numberGroups)
 	for _, number := range sortedNumbers {
 		fileNames := make([]string, 0)
@@ -43,72 +99,203 @@ func (re *SSReader) Get(key string) (*Entry, error) {
 			continue
 		} else {
 			summaryFileName := findFileName(fileNames, "Summary")
-			startOffsetIndex, err := CheckSummaryIndex(summaryFileName, key, 0)
+			startOffsetIndex, endOffsetIndex, err := re.CheckSummaryIndex(summaryFileName, key, 0, -1)
 			if err != nil {
 				return nil, err
 			}
 
 			indexFileName := findFileName(fileNames, "Index")
-			startOffsetData, err := CheckSummaryIndex(indexFileName, key, startOffsetIndex)
+			startOffsetData, endOffsetData, err := re.CheckSummaryIndex(indexFileName, key, startOffsetIndex, endOffsetIndex)
 			if err != nil {
 				return nil, err
 			}
 
 			dataFileName := findFileName(fileNames, "Data")
-			value, err := CheckData(dataFileName, key, startOffsetData)
+			tableVersions, err := re.CheckData(dataFileName, key, startOffsetData, endOffsetData)
 			if err != nil {
 				return nil, err
 			}
 
-			if value == nil {
+			if len(tableVersions) == 0 {
 				continue
 			}
 
-			entry := NewEntry(key, value, false)
-			return entry, nil
+			versions = append(versions, tableVersions...)
+			if latestOnly {
+				break
+			}
 		}
 	}
 
-	return nil, nil
+	return versions, nil
 }
 
-func CheckSummaryIndex(fileName, keyToFind string, startOffset int) (int, error) {
+// CheckSummaryIndex looks up keyToFind in the summary or index entries in [startOffset, endOffset), -1 ends at the end of the file.
+// It returns the range of the next file holding the key: the offset stored with the last key not ordered after keyToFind
+// and the offset stored with the entry after it, -1 if the range runs to the end of the file.
+func (re *SSReader) CheckSummaryIndex(fileName, keyToFind string, startOffset, endOffset int) (int, int, error) {
+	var block *indexBlock
+	if re.blocks != nil {
+		block = re.blocks.getIndex(fileName, startOffset)
+	}
+	if block == nil {
+		var err error
+		block, err = readIndexBlock(fileName, startOffset, endOffset)
+		if err != nil {
+			return 0, 0, err
+		}
+		if re.blocks != nil {
+			re.blocks.putIndex(fileName, startOffset, block)
+		}
+	}
+
+	i := sort.Search(len(block.keys), func(i int) bool {
+		return re.cmp.Compare(block.keys[i], keyToFind) > 0
+	})
+	start, end := 0, -1
+	if i > 0 {
+		start = block.offsets[i-1]
+	}
+	if i < len(block.keys) {
+		end = block.offsets[i]
+	}
+	return start, end, nil
+}
+
+// readIndexBlock reads the summary or index entries in [startOffset, endOffset), -1 reads to the end of the file.
+// The entry at endOffset is read as well, its offset ends the range of the entry before it.
+func readIndexBlock(fileName string, startOffset, endOffset int) (*indexBlock, error) {
 	file, err := os.Open(fileName)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer file.Close()
 
-	var lowerKeyBuf []byte
-	var lowerOffsetBuf int
-
 	_, err = file.Seek(int64(startOffset), 0)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	for {
-		lowerKey, lowerOffset, err := readSummaryIndexEntry(file)
+	block := &indexBlock{}
+	for position := startOffset; ; {
+		key, offset, err := readSummaryIndexEntry(file)
 		if err == io.EOF {
 			break
 		} else if err != nil {
+			return nil, err
+		}
 
-			return 0, err
+		block.keys = append(block.keys, utils.BytesToString(key))
+		block.offsets = append(block.offsets, offset)
+		if endOffset >= 0 && position >= endOffset {
+			break
 		}
+		position += KEY_SIZE_SIZE + len(key) + 4
+	}
+	return block, nil
+}
 
-		lowerKeyBuf = lowerKey
+// CheckData returns all versions of keyToFind in the data entries in [startOffset, endOffset), newest first.
+// An endOffset of -1 reads to the end of the file.
+func (re *SSReader) CheckData(fileName, keyToFind string, startOffset, endOffset int) ([]*Entry, error) {
+	var entries []*Entry
+	cached := false
+	if re.blocks != nil {
+		entries, cached = re.blocks.getData(fileName, startOffset)
+	}
+	if !cached {
+		var err error
+		entries, err = readDataBlock(fileName, startOffset, endOffset)
+		if err != nil {
+			return nil, err
+		}
+		if re.blocks != nil {
+			re.blocks.putData(fileName, startOffset, entries)
+		}
+	}
+
+	i := sort.Search(len(entries), func(i int) bool {
+		return re.cmp.Compare(entries[i].key, keyToFind) >= 0
+	})
+	var versions []*Entry
+	for ; i < len(entries) && entries[i].key == keyToFind; i++ {
+		versions = append(versions, entries[i])
+	}
+	return versions, nil
+}
+
+// readDataBlock reads the data entries in [startOffset, endOffset), -1 reads to the end of the file.
+func readDataBlock(fileName string, startOffset, endOffset int) ([]*Entry, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	_, err = file.Seek(int64(startOffset), 0)
+	if err != nil {
+		return nil, err
+	}
 
-		if string(lowerKeyBuf) >= keyToFind {
+	var entries []*Entry
+	for position := startOffset; endOffset < 0 || position < endOffset; {
+		entry, err := readDataEntry(file)
+		if err == io.EOF {
 			break
+		} else if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+
+		position, err = Tell(file)
+		if err != nil {
+			return nil, err
 		}
+	}
+	return entries, nil
+}
 
-		lowerOffsetBuf = lowerOffset
+// Scan returns every version of the keys in [start, end) stored in sstables, newest table first.
+// Empty bounds leave the range open.
+func (re *SSReader) Scan(start, end string) ([]*Entry, error) {
+	defer re.readTables()()
+
+	numberGroups, err := re.groupFilesByNumber()
+	if err != nil {
+		return nil, err
 	}
 
-	return lowerOffsetBuf, nil
+	var entries []*Entry
+	for _, number := range sortedNumbers(numberGroups) {
+		fileNames := numberGroups[number]
+
+		startOffsetData := 0
+		if start != "" {
+			summaryFileName := findFileName(fileNames, "Summary")
+			startOffsetIndex, endOffsetIndex, err := re.CheckSummaryIndex(summaryFileName, start, 0, -1)
+			if err != nil {
+				return nil, err
+			}
+
+			indexFileName := findFileName(fileNames, "Index")
+			startOffsetData, _, err = re.CheckSummaryIndex(indexFileName, start, startOffsetIndex, endOffsetIndex)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		tableEntries, err := scanData(findFileName(fileNames, "Data"), start, end, startOffsetData, re.cmp)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, tableEntries...)
+	}
+
+	return entries, nil
 }
 
-func CheckData(fileName, keyToFind string, startOffset int) ([]byte, error) {
+// scanData reads the entries with keys in [start, end) from the data file, beginning at startOffset.
+func scanData(fileName, start, end string, startOffset int, cmp comparator.Comparator) ([]*Entry, error) {
 	file, err := os.Open(fileName)
 	if err != nil {
 		return nil, err
@@ -120,16 +307,110 @@ func CheckData(fileName, keyToFind string, startOffset int) ([]byte, error) {
 		return nil, err
 	}
 
+	var entries []*Entry
+	for {
+		entry, err := readDataEntry(file)
+		if err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch rangePosition(cmp, entry.key, start, end) {
+		case 0:
+			entries = append(entries, entry)
+		case 1:
+			return entries, nil
+		}
+	}
+}
+
+// readTable reads every entry of a data file in the order they were written.
+func readTable(fileName string) ([]*Entry, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []*Entry
 	for {
-		key, value, err := readDataEntry(file)
+		entry, err := readDataEntry(file)
 		if err == io.EOF {
-			return nil, nil
+			return entries, nil
 		} else if err != nil {
 			return nil, err
-		} else if string(key) == keyToFind {
-			return value, nil
 		}
+		entries = append(entries, entry)
+	}
+}
+
+// RangeTombstones returns the range tombstones stored in all tables.
+func (re *SSReader) RangeTombstones() ([]*RangeTombstone, error) {
+	defer re.readTables()()
+
+	if re.tombstones != nil {
+		return re.tombstones.all(re.loadRangeTombstones)
+	}
+
+	tables, err := re.loadRangeTombstones()
+	if err != nil {
+		return nil, err
+	}
+
+	var tombstones []*RangeTombstone
+	for _, tableTombstones := range tables {
+		tombstones = append(tombstones, tableTombstones...)
+	}
+	return tombstones, nil
+}
+
+// loadRangeTombstones reads the range tombstones of every table, by generation.
+func (re *SSReader) loadRangeTombstones() (map[int][]*RangeTombstone, error) {
+	numberGroups, err := re.groupFilesByNumber()
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make(map[int][]*RangeTombstone)
+	for number, fileNames := range numberGroups {
+		rangeDelFileName := findFileName(fileNames, "RangeDel")
+		if rangeDelFileName == "" {
+			continue
+		}
+		tableTombstones, err := readRangeTombstones(rangeDelFileName)
+		if err != nil {
+			return nil, err
+		}
+		tables[number] = tableTombstones
+	}
+
+	return tables, nil
+}
+
+func readRangeTombstones(fileName string) ([]*RangeTombstone, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	var tombstones []*RangeTombstone
+	for len(data) > 0 {
+		timestamp := int64(binary.BigEndian.Uint64(data[:TIMESTAMP_SIZE]))
+		data = data[TIMESTAMP_SIZE:]
+
+		keys := make([]string, 2)
+		for i := range keys {
+			keyLen := int(binary.BigEndian.Uint32(data[:KEY_SIZE_SIZE]))
+			data = data[KEY_SIZE_SIZE:]
+			keys[i] = string(data[:keyLen])
+			data = data[keyLen:]
+		}
+
+		tombstones = append(tombstones, NewRangeTombstoneAt(keys[0], keys[1], timestamp))
 	}
+
+	return tombstones, nil
 }
 
 func dirExists(path string) bool {
@@ -152,6 +433,11 @@ func (re *SSReader) groupFilesByNumber() (map[int][]string, error) {
 			return err
 		}
 
+		// column families keep their sstables in subdirectories
+		if d.IsDir() && path != re.dirPath {
+			return fs.SkipDir
+		}
+
 		if !d.IsDir() {
 			if matched, _ := regexp.MatchString(`usertable-\d+-[^.]+\.txt`, d.Name()); matched {
 				parts := strings.Split(d.Name(), "-")
@@ -172,6 +458,13 @@ func (re *SSReader) groupFilesByNumber() (map[int][]string, error) {
 		return nil, err
 	}
 
+	if re.below > 0 {
+		for number := range groups {
+			if number >= re.below {
+				delete(groups, number)
+			}
+		}
+	}
 	return groups, nil
 }
 
@@ -230,36 +523,64 @@ func readSummaryIndexEntry(file *os.File) ([]byte, int, error) {
 	return serializedKeyBuf, offset, nil
 }
 
-func readDataEntry(file *os.File) ([]byte, []byte, error) {
+func readDataEntry(file *os.File) (*Entry, error) {
 	tombstoneBuf := make([]byte, TOMBSTONE_SIZE)
 	_, err := file.Read(tombstoneBuf)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	tombstone := tombstoneBuf[0] == ENTRY_TOMBSTONE
+	merge := tombstoneBuf[0] == ENTRY_MERGE
+	pointer := tombstoneBuf[0] == ENTRY_POINTER
+
+	timestampBuf := make([]byte, TIMESTAMP_SIZE)
+	_, err = file.Read(timestampBuf)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := int64(binary.BigEndian.Uint64(timestampBuf))
+
+	expiryBuf := make([]byte, EXPIRY_SIZE)
+	_, err = file.Read(expiryBuf)
+	if err != nil {
+		return nil, err
 	}
+	expiry := int64(binary.BigEndian.Uint64(expiryBuf))
 
 	keyLenBuf := make([]byte, KEY_SIZE_SIZE)
 	_, err = file.Read(keyLenBuf)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	serializedKeyBuf := make([]byte, int32(binary.BigEndian.Uint32(keyLenBuf)))
 	_, err = file.Read(serializedKeyBuf)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+
+	// the key buffer is not reused, so the entry shares it instead of copying it
+	key := utils.BytesToString(serializedKeyBuf)
+
+	// tombstones are written without a value
+	if tombstone {
+		return NewEntryAt(key, nil, true, timestamp, expiry), nil
 	}
 
 	valueLenBuf := make([]byte, VALUE_SIZE_SIZE)
 	_, err = file.Read(valueLenBuf)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	serializedValueBuf := make([]byte, int32(binary.BigEndian.Uint32(valueLenBuf)))
 	_, err = file.Read(serializedValueBuf)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	return serializedKeyBuf, serializedValueBuf, nil
+	entry := NewEntryAt(key, serializedValueBuf, false, timestamp, expiry)
+	entry.merge = merge
+	entry.pointer = pointer
+	return entry, nil
 }
@@ -1,38 +1,62 @@
 package memtable
 
 import (
+	"NoSQLDB/lib/comparator"
 	"NoSQLDB/lib/skiplist"
-	"sort"
+	"NoSQLDB/lib/utils"
 )
 
 type SkipListMemtable struct {
+	rangeTombstones
+	memoryUsage
 	data       *skiplist.SkipList
 	threshhold int
 }
 
 func NewSkipListMemtable(threshold, maxLevel int) *SkipListMemtable {
+	return NewSkipListMemtableWithComparator(threshold, maxLevel, comparator.Bytewise)
+}
+
+// NewSkipListMemtableWithComparator creates a skip list memtable ordered by the comparator
+func NewSkipListMemtableWithComparator(threshold, maxLevel int, cmp comparator.Comparator) *SkipListMemtable {
 	return &SkipListMemtable{
-		data:       skiplist.NewSkipList(maxLevel),
-		threshhold: threshold,
+		rangeTombstones: rangeTombstones{cmp: cmp},
+		data:            skiplist.NewSkipListWithComparator(maxLevel, cmp),
+		threshhold:      threshold,
 	}
 }
 
 func (slm *SkipListMemtable) Put(key string, value []byte) error {
-	slm.data.Put(key, value)
+	return slm.PutEntry(NewEntry(key, value, false))
+}
+
+func (slm *SkipListMemtable) PutEntry(entry *Entry) error {
+	old, _ := slm.data.Find(entry.key)
+	slm.replace(NodeToEntry(old), entry)
+	slm.data.PutVersion(entry.key, entry.value, entry.tombstone, entry.merge, entry.pointer, entry.timestamp, entry.expiry)
 	return nil
 }
 
+// Get returns the entry of the key, a tombstone if it was deleted
 func (slm *SkipListMemtable) Get(key string) (*Entry, error) {
-	node, _ := slm.data.Get(key)
+	node, _ := slm.data.Find(key)
 	if node == nil {
 		return nil, nil
 	}
 	return NodeToEntry(node), nil
 }
 
+func (slm *SkipListMemtable) PutBytes(key, value []byte) error {
+	return slm.Put(utils.BytesToString(key), value)
+}
+
+func (slm *SkipListMemtable) GetBytes(key []byte) (*Entry, error) {
+	return slm.Get(utils.BytesToString(key))
+}
+
+// Delete stores a tombstone, even if the key is not in the memtable, so it hides the key in the sstables
 func (slm *SkipListMemtable) Delete(key string) error {
-	slm.data.LogicallyDelete(key)
-	return nil
+	return slm.PutEntry(NewEntry(key, nil, true))
 }
 
 func (slm *SkipListMemtable) Size() int {
@@ -40,7 +64,15 @@ func (slm *SkipListMemtable) Size() int {
 }
 
 func (slm *SkipListMemtable) IsFull() bool {
-	return slm.Size() >= slm.threshhold
+	return slm.Size() >= slm.threshhold || slm.bytesFull()
+}
+
+func (slm *SkipListMemtable) Iterator() Iterator {
+	it := slm.data.Iterator()
+	return &cursorIterator{
+		next:  it.Next,
+		entry: func() *Entry { return NodeToEntry(it.Node()) },
+	}
 }
 
 func NodeToEntry(n *skiplist.Node) *Entry {
@@ -51,6 +83,10 @@ func NodeToEntry(n *skiplist.Node) *Entry {
 		key:       n.Key(),
 		value:     n.Value(),
 		tombstone: n.Tombstone(),
+		merge:     n.Merge(),
+		pointer:   n.Pointer(),
+		timestamp: n.Timestamp(),
+		expiry:    n.Expiry(),
 	}
 }
 
@@ -62,7 +98,6 @@ func (sm *SkipListMemtable) SortKeys() []string {
 		keys = append(keys, node.Key())
 	}
 
-	sort.Strings(keys)
-
+	// the nodes are already ordered by the comparator of the skip list
 	return keys
 }
@@ -41,8 +41,8 @@ func TestSkipListMemtable(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Get() after Delete() = %v; want nil", err)
 	}
-	if entry != nil {
-		t.Errorf("Get('key1') after Delete() = %v; want nil", entry)
+	if entry == nil || !entry.Tombstone() {
+		t.Errorf("Get('key1') after Delete() = %v; want a tombstone", entry)
 	}
 
 	// Test Size after deletion
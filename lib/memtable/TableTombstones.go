@@ -0,0 +1,56 @@
+package memtable
+
+import "sync"
+
+// TableTombstones keeps the range tombstones of the tables in a directory by their generation.
+// The writer of the directory records the tables it writes and removes, so a reader sharing it
+// loads the range tombstones of every table once instead of on every lookup.
+// Generations are never reused, so a recorded table never changes.
+type TableTombstones struct {
+	mu     sync.Mutex
+	loaded bool // tables are only recorded once they were loaded from disk
+	tables map[int][]*RangeTombstone
+}
+
+func NewTableTombstones() *TableTombstones {
+	return &TableTombstones{tables: make(map[int][]*RangeTombstone)}
+}
+
+// add records the range tombstones of a newly written table
+func (tt *TableTombstones) add(gen int, tombstones []*RangeTombstone) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	if tt.loaded {
+		tt.tables[gen] = tombstones
+	}
+}
+
+// remove forgets a removed table
+func (tt *TableTombstones) remove(gen int) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	delete(tt.tables, gen)
+}
+
+// all returns the range tombstones of every table, the first call loads them with load
+func (tt *TableTombstones) all(load func() (map[int][]*RangeTombstone, error)) ([]*RangeTombstone, error) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+
+	if !tt.loaded {
+		tables, err := load()
+		if err != nil {
+			return nil, err
+		}
+		tt.tables = tables
+		tt.loaded = true
+	}
+
+	var tombstones []*RangeTombstone
+	for _, tableTombstones := range tt.tables {
+		tombstones = append(tombstones, tableTombstones...)
+	}
+	return tombstones, nil
+}
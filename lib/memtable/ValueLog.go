@@ -0,0 +1,62 @@
+package memtable
+
+import (
+	"errors"
+)
+
+// ValueLog holds the values which were separated from their entries.
+// A separated entry keeps a pointer to its value, which is resolved only when the value is read.
+type ValueLog interface {
+	Read(pointer []byte) ([]byte, error)
+}
+
+var ErrNoValueLog = errors.New("entry points into a value log, but none is set")
+
+// NewPointerEntryAt creates an entry whose value was written to the value log
+func NewPointerEntryAt(key string, pointer []byte, timestamp, expiry int64) *Entry {
+	entry := NewEntryAt(key, pointer, false, timestamp, expiry)
+	entry.pointer = true
+	return entry
+}
+
+// Resolve returns the entry with its value loaded from the value log.
+// Entries which hold their value are returned as they are.
+func (e *Entry) Resolve(vlog ValueLog) (*Entry, error) {
+	if !e.pointer {
+		return e, nil
+	}
+	if vlog == nil {
+		return nil, ErrNoValueLog
+	}
+
+	value, err := vlog.Read(e.value)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := *e
+	resolved.value = value
+	resolved.pointer = false
+	return &resolved, nil
+}
+
+// ResolveMergeBases loads the values merge operands are folded onto, in place.
+// The versions are sorted from newest to oldest. Other values are left in the value log,
+// older ones might have been collected already.
+func ResolveMergeBases(vlog ValueLog, versions []*Entry) error {
+	for i, version := range versions {
+		if i == 0 || !versions[i-1].merge {
+			continue
+		}
+		if !version.pointer || version.tombstone || version.Expired() {
+			continue
+		}
+
+		resolved, err := version.Resolve(vlog)
+		if err != nil {
+			return err
+		}
+		versions[i] = resolved
+	}
+	return nil
+}
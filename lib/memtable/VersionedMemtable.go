@@ -0,0 +1,145 @@
+package memtable
+
+import (
+	"sort"
+	"time"
+)
+
+// VersionPolicy decides which older versions of a key are retained.
+// A version is kept if it is one of the newest MaxVersions versions
+// or if it was written less than MaxAge ago. The latest version is always kept.
+type VersionPolicy struct {
+	MaxVersions int
+	MaxAge      time.Duration
+}
+
+func NewVersionPolicy(maxVersions int, maxAge time.Duration) *VersionPolicy {
+	if maxVersions <= 0 && maxAge <= 0 {
+		return nil
+	}
+	return &VersionPolicy{
+		MaxVersions: maxVersions,
+		MaxAge:      maxAge,
+	}
+}
+
+// Retain filters versions sorted from newest to oldest according to the policy.
+func (vp *VersionPolicy) Retain(versions []*Entry) []*Entry {
+	if vp == nil || len(versions) == 0 {
+		return versions
+	}
+
+	now := time.Now().UnixNano()
+	retained := versions[:1]
+	for i := 1; i < len(versions); i++ {
+		inCount := vp.MaxVersions > 0 && i < vp.MaxVersions
+		inAge := vp.MaxAge > 0 && now-versions[i].timestamp < int64(vp.MaxAge)
+		if inCount || inAge {
+			retained = append(retained, versions[i])
+		}
+	}
+	return retained
+}
+
+// SortVersions orders versions from newest to oldest.
+func SortVersions(versions []*Entry) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].timestamp > versions[j].timestamp
+	})
+}
+
+// DedupVersions drops the copies of versions sorted from newest to oldest.
+// Entries are logged with their timestamps, so an entry replayed from the WAL over an sstable which already holds it
+// is a copy with the same key and timestamp.
+func DedupVersions(versions []*Entry) []*Entry {
+	deduped := make([]*Entry, 0, len(versions))
+	for i, version := range versions {
+		if i > 0 && version.timestamp == versions[i-1].timestamp && version.key == versions[i-1].key {
+			continue
+		}
+		deduped = append(deduped, version)
+	}
+	return deduped
+}
+
+// Versioned is implemented by memtables that keep older versions of their keys.
+type Versioned interface {
+	// History returns every retained version of the key, newest first.
+	History(key string) []*Entry
+}
+
+// VersionedMemtable wraps a memtable and keeps the versions
+// that the wrapped memtable would otherwise overwrite in place.
+type VersionedMemtable struct {
+	Memtable
+	history      map[string][]*Entry
+	historyBytes int // memory used by the versions in the history
+	maxBytes     int
+	policy       *VersionPolicy
+}
+
+func NewVersionedMemtable(mt Memtable, policy *VersionPolicy) *VersionedMemtable {
+	return &VersionedMemtable{
+		Memtable: mt,
+		history:  make(map[string][]*Entry),
+		policy:   policy,
+	}
+}
+
+// keep moves the current version of the key into the history before it gets overwritten by entry
+func (vm *VersionedMemtable) keep(entry *Entry) {
+	current, err := vm.Memtable.Get(entry.key)
+	if err != nil || current == nil {
+		return
+	}
+	for _, version := range vm.history[entry.key] {
+		vm.historyBytes -= version.sizeBytes()
+	}
+
+	versions := append([]*Entry{entry, current}, vm.history[entry.key]...)
+	vm.history[entry.key] = vm.policy.Retain(versions)[1:]
+
+	for _, version := range vm.history[entry.key] {
+		vm.historyBytes += version.sizeBytes()
+	}
+}
+
+// SizeBytes includes the versions kept in the history
+func (vm *VersionedMemtable) SizeBytes() int {
+	return vm.Memtable.SizeBytes() + vm.historyBytes
+}
+
+func (vm *VersionedMemtable) SetMaxBytes(maxBytes int) {
+	vm.maxBytes = maxBytes
+	vm.Memtable.SetMaxBytes(maxBytes)
+}
+
+func (vm *VersionedMemtable) IsFull() bool {
+	return vm.Memtable.IsFull() || (vm.maxBytes > 0 && vm.SizeBytes() >= vm.maxBytes)
+}
+
+func (vm *VersionedMemtable) Put(key string, value []byte) error {
+	return vm.PutEntry(NewEntry(key, value, false))
+}
+
+func (vm *VersionedMemtable) PutEntry(entry *Entry) error {
+	vm.keep(entry)
+	return vm.Memtable.PutEntry(entry)
+}
+
+func (vm *VersionedMemtable) PutBytes(key, value []byte) error {
+	return vm.PutEntry(NewEntryFromBytes(key, value, false))
+}
+
+func (vm *VersionedMemtable) Delete(key string) error {
+	return vm.PutEntry(NewEntry(key, nil, true))
+}
+
+func (vm *VersionedMemtable) History(key string) []*Entry {
+	var versions []*Entry
+	current, err := vm.Memtable.Get(key)
+	if err == nil && current != nil {
+		versions = append(versions, current)
+	}
+	return append(versions, vm.history[key]...)
+}
@@ -0,0 +1,84 @@
+package memtable
+
+import (
+	"NoSQLDB/lib/comparator"
+	"testing"
+	"time"
+)
+
+func TestVersionedMemtableKeepsVersions(t *testing.T) {
+	vm := NewVersionedMemtable(NewMapMemtable(10), NewVersionPolicy(2, 0))
+
+	for i, value := range []string{"v1", "v2", "v3", "v4"} {
+		vm.PutEntry(NewEntryAt("key", []byte(value), false, int64(i+1), 0))
+	}
+
+	entry, err := vm.Get("key")
+	if err != nil || string(entry.Value()) != "v4" {
+		t.Fatalf("Get('key') = %v, %v; want v4", entry, err)
+	}
+
+	history := vm.History("key")
+	if len(history) != 2 {
+		t.Fatalf("len(History('key')) = %d; want 2", len(history))
+	}
+	if string(history[0].Value()) != "v4" || string(history[1].Value()) != "v3" {
+		t.Errorf("History('key') = [%s %s]; want [v4 v3]", history[0].Value(), history[1].Value())
+	}
+}
+
+func TestVersionPolicyMaxAge(t *testing.T) {
+	policy := NewVersionPolicy(0, time.Hour)
+	now := time.Now()
+
+	versions := []*Entry{
+		NewEntryAt("key", []byte("new"), false, now.UnixNano(), 0),
+		NewEntryAt("key", []byte("recent"), false, now.Add(-time.Minute).UnixNano(), 0),
+		NewEntryAt("key", []byte("old"), false, now.Add(-2*time.Hour).UnixNano(), 0),
+	}
+
+	retained := policy.Retain(versions)
+	if len(retained) != 2 {
+		t.Fatalf("len(Retain()) = %d; want 2", len(retained))
+	}
+	if string(retained[1].Value()) != "recent" {
+		t.Errorf("Retain()[1] = %s; want recent", retained[1].Value())
+	}
+}
+
+func TestNewVersionPolicyDisabled(t *testing.T) {
+	if policy := NewVersionPolicy(0, 0); policy != nil {
+		t.Errorf("NewVersionPolicy(0, 0) = %v; want nil", policy)
+	}
+}
+
+// TestCompactionDropsReplayedVersions flushes the same versions twice, as a WAL replayed over a flushed table does
+func TestCompactionDropsReplayedVersions(t *testing.T) {
+	policy := NewVersionPolicy(10, 0)
+	writer, err := NewSSWriter(t.TempDir()+"/", 2, 2, 100, 0.01, 0, policy, comparator.Bytewise)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := &SSReader{dirPath: writer.outputDir, cmp: comparator.Bytewise}
+
+	for table := 0; table < 2; table++ {
+		vm := NewVersionedMemtable(NewMapMemtable(10), policy)
+		for i, value := range []string{"v1", "v2"} {
+			vm.PutEntry(NewEntryAt("key", []byte(value), false, int64(i+1), 0))
+		}
+		if err := writer.Flush(vm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Compact(); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := reader.History("key")
+	if err != nil || len(history) != 2 {
+		t.Fatalf("len(History('key')) = %d, %v; want 2", len(history), err)
+	}
+	if string(history[0].Value()) != "v2" || string(history[1].Value()) != "v1" {
+		t.Errorf("History('key') = [%s %s]; want [v2 v1]", history[0].Value(), history[1].Value())
+	}
+}
@@ -0,0 +1,91 @@
+package mergeoperator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+)
+
+// CounterAdd treats values and operands as decimal integers and adds the operands to the value.
+type CounterAdd struct{}
+
+func (CounterAdd) Merge(key string, existing []byte, operands [][]byte) ([]byte, error) {
+	var sum int64
+	if existing != nil {
+		value, err := strconv.ParseInt(string(existing), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		sum = value
+	}
+
+	for _, operand := range operands {
+		delta, err := strconv.ParseInt(string(operand), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		sum += delta
+	}
+
+	return []byte(strconv.FormatInt(sum, 10)), nil
+}
+
+// ListAppend appends the operands to the value, separating the elements with Separator.
+type ListAppend struct {
+	Separator []byte
+}
+
+func (la ListAppend) Merge(key string, existing []byte, operands [][]byte) ([]byte, error) {
+	elements := make([][]byte, 0, len(operands)+1)
+	if len(existing) > 0 {
+		elements = append(elements, existing)
+	}
+	elements = append(elements, operands...)
+
+	return bytes.Join(elements, la.Separator), nil
+}
+
+// JSONMergePatch applies the operands as JSON merge patches (RFC 7396) to a JSON value.
+type JSONMergePatch struct{}
+
+func (JSONMergePatch) Merge(key string, existing []byte, operands [][]byte) ([]byte, error) {
+	var document interface{}
+	if existing != nil {
+		if err := json.Unmarshal(existing, &document); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, operand := range operands {
+		var patch interface{}
+		if err := json.Unmarshal(operand, &patch); err != nil {
+			return nil, err
+		}
+		document = mergePatch(document, patch)
+	}
+
+	return json.Marshal(document)
+}
+
+// mergePatch applies a single merge patch to the target document
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+
+	return targetObject
+}
@@ -0,0 +1,52 @@
+package mergeoperator
+
+import (
+	"testing"
+)
+
+func TestCounterAdd(t *testing.T) {
+	value, err := CounterAdd{}.Merge("hits", []byte("10"), [][]byte{[]byte("5"), []byte("-3")})
+	if err != nil {
+		t.Fatalf("Merge() = %v; want nil", err)
+	}
+	if string(value) != "12" {
+		t.Errorf("Merge() = %s; want 12", value)
+	}
+
+	value, err = CounterAdd{}.Merge("hits", nil, [][]byte{[]byte("1")})
+	if err != nil || string(value) != "1" {
+		t.Errorf("Merge() on absent key = %s, %v; want 1, nil", value, err)
+	}
+}
+
+func TestListAppend(t *testing.T) {
+	op := ListAppend{Separator: []byte(",")}
+
+	value, _ := op.Merge("list", nil, [][]byte{[]byte("a"), []byte("b")})
+	if string(value) != "a,b" {
+		t.Errorf("Merge() on absent key = %s; want a,b", value)
+	}
+
+	value, _ = op.Merge("list", value, [][]byte{[]byte("c")})
+	if string(value) != "a,b,c" {
+		t.Errorf("Merge() = %s; want a,b,c", value)
+	}
+}
+
+func TestJSONMergePatch(t *testing.T) {
+	existing := []byte(`{"name":"ana","address":{"city":"Novi Sad","zip":"21000"},"tmp":1}`)
+	operands := [][]byte{
+		[]byte(`{"address":{"city":"Beograd"},"tmp":null}`),
+		[]byte(`{"status":"open"}`),
+	}
+
+	value, err := JSONMergePatch{}.Merge("doc", existing, operands)
+	if err != nil {
+		t.Fatalf("Merge() = %v; want nil", err)
+	}
+
+	want := `{"address":{"city":"Beograd","zip":"21000"},"name":"ana","status":"open"}`
+	if string(value) != want {
+		t.Errorf("Merge() = %s; want %s", value, want)
+	}
+}
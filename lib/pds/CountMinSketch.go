@@ -83,3 +83,12 @@ func (cms *CountMinSketch) Delete() {
 	cms.Width = 0
 	cms.Depth = 0
 }
+
+// Halve divides every count by two, so older insertions weigh less than recent ones
+func (cms *CountMinSketch) Halve() {
+	for i := range cms.Table {
+		for j := range cms.Table[i] {
+			cms.Table[i][j] /= 2
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package skiplist
+
+import (
+	"errors"
+	"math"
+)
+
+var ErrArenaFull = errors.New("arena is out of addressable space")
+
+// Arena hands out memory from large preallocated slabs, addressed by uint32 offsets.
+// Nothing is freed individually, the whole arena is released once it is no longer referenced.
+type Arena struct {
+	slabs    [][]byte
+	slabSize int
+	current  int // slab being filled
+	used     int // bytes used in the current slab
+}
+
+func NewArena(slabSize int) *Arena {
+	return &Arena{
+		slabs:    [][]byte{make([]byte, slabSize)},
+		slabSize: slabSize,
+	}
+}
+
+// Allocate reserves n bytes and returns their offset.
+// An allocation larger than a slab gets a slab of its own, which covers as many offsets as it needs.
+// Empty allocations, like the values of tombstones, all get offset 0, which is always in range.
+func (a *Arena) Allocate(n int) (uint32, error) {
+	if n == 0 {
+		return 0, nil
+	}
+
+	if a.used+n <= len(a.slabs[a.current]) {
+		offset := a.current*a.slabSize + a.used
+		a.used += n
+		return uint32(offset), nil
+	}
+
+	count := (n + a.slabSize - 1) / a.slabSize
+	if count == 0 {
+		count = 1
+	}
+	if (len(a.slabs)+count)*a.slabSize > math.MaxUint32 {
+		return 0, ErrArenaFull
+	}
+
+	a.current = len(a.slabs)
+	a.slabs = append(a.slabs, make([]byte, count*a.slabSize))
+	// the slab takes up the offsets of the following ones, which are never handed out
+	for i := 1; i < count; i++ {
+		a.slabs = append(a.slabs, nil)
+	}
+	a.used = n
+	if count > 1 {
+		a.used = len(a.slabs[a.current])
+	}
+
+	return uint32(a.current * a.slabSize), nil
+}
+
+// Bytes returns the n bytes at the offset, capped so appending to them doesn't overwrite the arena
+func (a *Arena) Bytes(offset uint32, n int) []byte {
+	slab := a.slabs[int(offset)/a.slabSize]
+	start := int(offset) % a.slabSize
+	return slab[start : start+n : start+n]
+}
+
+// Size returns the number of bytes reserved by the arena
+func (a *Arena) Size() int {
+	size := 0
+	for _, slab := range a.slabs {
+		size += len(slab)
+	}
+	return size
+}
+
+// Used returns the number of bytes handed out, including the unused ends of filled slabs
+func (a *Arena) Used() int {
+	return a.current*a.slabSize + a.used
+}
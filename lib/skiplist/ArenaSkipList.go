@@ -0,0 +1,266 @@
+package skiplist
+
+import (
+	"NoSQLDB/lib/comparator"
+	"NoSQLDB/lib/utils"
+	"encoding/binary"
+	"math/rand"
+)
+
+/*
+   Every node is stored in the arena as
+
+   +---------------+---------------+-----------------+-----------------+----------------+-------------+-----------+------------+-----------------+
+   | Key Off. (4B) | Key Size (4B) | Value Off. (4B) | Value Size (4B) | Timestamp (8B) | Expiry (8B) | Flags(1B) | Height(1B) | Tower (Height*4B) |
+   +---------------+---------------+-----------------+-----------------+----------------+-------------+-----------+------------+-----------------+
+
+   Keys and values are allocated separately, an overwritten value stays in the arena until it is released.
+   The tower holds the offsets of the next node on every level, 0 marks the end since the head is stored at 0.
+*/
+
+const (
+	NODE_KEY_OFFSET   = 0
+	NODE_KEY_SIZE     = NODE_KEY_OFFSET + 4
+	NODE_VALUE_OFFSET = NODE_KEY_SIZE + 4
+	NODE_VALUE_SIZE   = NODE_VALUE_OFFSET + 4
+	NODE_TIMESTAMP    = NODE_VALUE_SIZE + 4
+	NODE_EXPIRY       = NODE_TIMESTAMP + 8
+	NODE_FLAGS        = NODE_EXPIRY + 8
+	NODE_HEIGHT       = NODE_FLAGS + 1
+	NODE_TOWER        = NODE_HEIGHT + 1
+
+	FLAG_TOMBSTONE = 1 << 0
+	FLAG_MERGE     = 1 << 1
+	FLAG_POINTER   = 1 << 2
+)
+
+// ArenaSkipList is a skip list whose nodes, keys and values live in an arena,
+// so inserts don't allocate anything the garbage collector has to track.
+type ArenaSkipList struct {
+	arena    *Arena
+	maxLevel int
+	level    int
+	size     int
+	head     uint32
+	cmp      comparator.Comparator
+}
+
+// ArenaNode is a view of a node stored in the arena
+type ArenaNode struct {
+	list   *ArenaSkipList
+	offset uint32
+}
+
+func NewArenaSkipList(maxLevel, slabSize int) *ArenaSkipList {
+	return NewArenaSkipListWithComparator(maxLevel, slabSize, comparator.Bytewise)
+}
+
+// NewArenaSkipListWithComparator creates an arena skip list which orders its keys with the comparator
+func NewArenaSkipListWithComparator(maxLevel, slabSize int, cmp comparator.Comparator) *ArenaSkipList {
+	sl := &ArenaSkipList{
+		arena:    NewArena(slabSize),
+		maxLevel: maxLevel,
+		cmp:      cmp,
+	}
+	// the first allocation of an empty arena always fits and lands at offset 0
+	sl.head, _ = sl.newNode(maxLevel + 1)
+	return sl
+}
+
+func (sl *ArenaSkipList) newNode(height int) (uint32, error) {
+	return sl.arena.Allocate(NODE_TOWER + 4*height)
+}
+
+func (sl *ArenaSkipList) uint32At(offset uint32) uint32 {
+	return binary.BigEndian.Uint32(sl.arena.Bytes(offset, 4))
+}
+
+func (sl *ArenaSkipList) putUint32At(offset, value uint32) {
+	binary.BigEndian.PutUint32(sl.arena.Bytes(offset, 4), value)
+}
+
+func (sl *ArenaSkipList) next(node uint32, level int) uint32 {
+	return sl.uint32At(node + NODE_TOWER + 4*uint32(level))
+}
+
+func (sl *ArenaSkipList) setNext(node uint32, level int, next uint32) {
+	sl.putUint32At(node+NODE_TOWER+4*uint32(level), next)
+}
+
+func (sl *ArenaSkipList) key(node uint32) string {
+	return utils.BytesToString(sl.arena.Bytes(sl.uint32At(node+NODE_KEY_OFFSET), int(sl.uint32At(node+NODE_KEY_SIZE))))
+}
+
+func (sl *ArenaSkipList) roll() int {
+	level := 0
+	for rand.Int31n(2) == 1 && level < sl.maxLevel {
+		level++
+	}
+	return level
+}
+
+// find returns the first node with a key not ordered before the key, 0 if there is none.
+// If update is given, it is filled with the last node before the key on every level.
+func (sl *ArenaSkipList) find(key string, update []uint32) uint32 {
+	current := sl.head
+	for i := sl.level; i >= 0; i-- {
+		for next := sl.next(current, i); next != 0 && sl.cmp.Compare(sl.key(next), key) < 0; next = sl.next(current, i) {
+			current = next
+		}
+		if update != nil {
+			update[i] = current
+		}
+	}
+	return sl.next(current, 0)
+}
+
+// PutVersion inserts or overwrites a key with the given value, tombstone, merge and pointer flags, timestamp and expiry.
+// The key and value are copied into the arena.
+func (sl *ArenaSkipList) PutVersion(key string, value []byte, tombstone, merge, pointer bool, timestamp, expiry int64) error {
+	update := make([]uint32, sl.maxLevel+1)
+	node := sl.find(key, update)
+
+	if node == 0 || sl.key(node) != key {
+		level := sl.roll()
+
+		var err error
+		node, err = sl.newNode(level + 1)
+		if err != nil {
+			return err
+		}
+		keyOffset, err := sl.arena.Allocate(len(key))
+		if err != nil {
+			return err
+		}
+		copy(sl.arena.Bytes(keyOffset, len(key)), key)
+		sl.putUint32At(node+NODE_KEY_OFFSET, keyOffset)
+		sl.putUint32At(node+NODE_KEY_SIZE, uint32(len(key)))
+		sl.arena.Bytes(node+NODE_HEIGHT, 1)[0] = byte(level + 1)
+
+		if level > sl.level {
+			for i := sl.level + 1; i <= level; i++ {
+				update[i] = sl.head
+			}
+			sl.level = level
+		}
+		for i := 0; i <= level; i++ {
+			sl.setNext(node, i, sl.next(update[i], i))
+			sl.setNext(update[i], i, node)
+		}
+		sl.size++
+	}
+
+	valueOffset, err := sl.arena.Allocate(len(value))
+	if err != nil {
+		return err
+	}
+	copy(sl.arena.Bytes(valueOffset, len(value)), value)
+	sl.putUint32At(node+NODE_VALUE_OFFSET, valueOffset)
+	sl.putUint32At(node+NODE_VALUE_SIZE, uint32(len(value)))
+
+	binary.BigEndian.PutUint64(sl.arena.Bytes(node+NODE_TIMESTAMP, 8), uint64(timestamp))
+	binary.BigEndian.PutUint64(sl.arena.Bytes(node+NODE_EXPIRY, 8), uint64(expiry))
+
+	var flags byte
+	if tombstone {
+		flags |= FLAG_TOMBSTONE
+	}
+	if merge {
+		flags |= FLAG_MERGE
+	}
+	if pointer {
+		flags |= FLAG_POINTER
+	}
+	sl.arena.Bytes(node+NODE_FLAGS, 1)[0] = flags
+
+	return nil
+}
+
+// Get returns the node of the key, including deleted ones.
+func (sl *ArenaSkipList) Get(key string) (ArenaNode, bool) {
+	node := sl.find(key, nil)
+	if node == 0 || sl.key(node) != key {
+		return ArenaNode{}, false
+	}
+	return ArenaNode{sl, node}, true
+}
+
+// Nodes returns all nodes ordered by key
+func (sl *ArenaSkipList) Nodes() []ArenaNode {
+	nodes := make([]ArenaNode, 0, sl.size)
+	for node := sl.next(sl.head, 0); node != 0; node = sl.next(node, 0) {
+		nodes = append(nodes, ArenaNode{sl, node})
+	}
+	return nodes
+}
+
+// ArenaIterator walks the nodes of an arena skip list ordered by key
+type ArenaIterator struct {
+	list    *ArenaSkipList
+	current uint32
+	done    bool
+}
+
+// Iterator returns an iterator positioned before the first node
+func (sl *ArenaSkipList) Iterator() *ArenaIterator {
+	return &ArenaIterator{list: sl, current: sl.head}
+}
+
+// Next moves to the next node, it returns false once there are none left
+func (it *ArenaIterator) Next() bool {
+	if it.done {
+		return false
+	}
+	it.current = it.list.next(it.current, 0)
+	// the head is at 0, so a next offset of 0 is the end
+	it.done = it.current == 0
+	return !it.done
+}
+
+func (it *ArenaIterator) Node() ArenaNode {
+	return ArenaNode{it.list, it.current}
+}
+
+func (sl *ArenaSkipList) Size() int {
+	return sl.size
+}
+
+// SizeBytes returns the memory of the arena taken up by the nodes, keys and values
+func (sl *ArenaSkipList) SizeBytes() int {
+	return sl.arena.Used()
+}
+
+// Key returns the key without copying it out of the arena
+func (n ArenaNode) Key() string {
+	return n.list.key(n.offset)
+}
+
+// Value returns the value without copying it out of the arena, it must not be modified
+func (n ArenaNode) Value() []byte {
+	sl := n.list
+	return sl.arena.Bytes(sl.uint32At(n.offset+NODE_VALUE_OFFSET), int(sl.uint32At(n.offset+NODE_VALUE_SIZE)))
+}
+
+func (n ArenaNode) flags() byte {
+	return n.list.arena.Bytes(n.offset+NODE_FLAGS, 1)[0]
+}
+
+func (n ArenaNode) Tombstone() bool {
+	return n.flags()&FLAG_TOMBSTONE != 0
+}
+
+func (n ArenaNode) Merge() bool {
+	return n.flags()&FLAG_MERGE != 0
+}
+
+func (n ArenaNode) Pointer() bool {
+	return n.flags()&FLAG_POINTER != 0
+}
+
+func (n ArenaNode) Timestamp() int64 {
+	return int64(binary.BigEndian.Uint64(n.list.arena.Bytes(n.offset+NODE_TIMESTAMP, 8)))
+}
+
+func (n ArenaNode) Expiry() int64 {
+	return int64(binary.BigEndian.Uint64(n.list.arena.Bytes(n.offset+NODE_EXPIRY, 8)))
+}
@@ -0,0 +1,105 @@
+package skiplist
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestArenaSkipList(t *testing.T) {
+	// small slabs, so the nodes are spread over many of them
+	sl := NewArenaSkipList(4, 256)
+
+	for i := 99; i >= 0; i-- {
+		key := fmt.Sprintf("key%03d", i)
+		if err := sl.PutVersion(key, []byte(key), false, false, false, int64(i), 0); err != nil {
+			t.Fatalf("PutVersion(%s): %v", key, err)
+		}
+	}
+
+	if sl.Size() != 100 {
+		t.Errorf("Size() = %d; want 100", sl.Size())
+	}
+
+	nodes := sl.Nodes()
+	for i, node := range nodes {
+		key := fmt.Sprintf("key%03d", i)
+		if node.Key() != key || string(node.Value()) != key || node.Timestamp() != int64(i) {
+			t.Fatalf("Nodes()[%d] = %s: %s @ %d; want %s", i, node.Key(), node.Value(), node.Timestamp(), key)
+		}
+	}
+
+	// overwriting keeps the key in place
+	sl.PutVersion("key050", nil, true, false, false, 1000, 0)
+	node, ok := sl.Get("key050")
+	if !ok || !node.Tombstone() || len(node.Value()) != 0 || node.Timestamp() != 1000 {
+		t.Errorf("Get(key050) = %v, %v; want a tombstone at 1000", node, ok)
+	}
+	if sl.Size() != 100 {
+		t.Errorf("Size() after overwriting = %d; want 100", sl.Size())
+	}
+
+	if _, ok := sl.Get("key100"); ok {
+		t.Errorf("Get(key100) found a missing key")
+	}
+}
+
+func TestArenaSkipListLargeValue(t *testing.T) {
+	sl := NewArenaSkipList(4, 64)
+
+	value := bytes.Repeat([]byte{7}, 200)
+	sl.PutVersion("a", []byte("small"), false, false, false, 1, 0)
+	sl.PutVersion("b", value, false, true, true, 2, 3)
+	sl.PutVersion("c", []byte("after"), false, false, false, 4, 0)
+
+	node, ok := sl.Get("b")
+	if !ok || !bytes.Equal(node.Value(), value) || !node.Merge() || !node.Pointer() || node.Expiry() != 3 {
+		t.Errorf("Get(b) = %v, %v; want the large merge pointer value", node, ok)
+	}
+	for _, key := range []string{"a", "c"} {
+		if node, ok := sl.Get(key); !ok || node.Key() != key {
+			t.Errorf("Get(%s) = %v, %v", key, node, ok)
+		}
+	}
+}
+
+func TestArenaEmptyAllocation(t *testing.T) {
+	arena := NewArena(64)
+	if _, err := arena.Allocate(64); err != nil {
+		t.Fatal(err)
+	}
+	// the slab is exactly full, an empty allocation must still point into the arena
+	offset, err := arena.Allocate(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := arena.Bytes(offset, 0); len(b) != 0 {
+		t.Errorf("Bytes(%d, 0) = %v; want empty", offset, b)
+	}
+}
+
+func TestArenaSkipListTombstones(t *testing.T) {
+	// nodes of this size fill the slabs exactly, so tombstones and empty values land at the end of a full slab
+	sl := NewArenaSkipList(0, NODE_TOWER+4+6)
+
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key%03d", i)
+		if err := sl.PutVersion(key, nil, i%2 == 0, false, false, int64(i), 0); err != nil {
+			t.Fatalf("PutVersion(%s): %v", key, err)
+		}
+	}
+	if err := sl.PutVersion("", []byte{}, false, false, false, 0, 0); err != nil {
+		t.Fatalf("PutVersion(''): %v", err)
+	}
+
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("key%03d", i)
+		node, ok := sl.Get(key)
+		if !ok || node.Tombstone() != (i%2 == 0) || len(node.Value()) != 0 {
+			t.Errorf("Get(%s) = %v, %v", key, node, ok)
+		}
+	}
+	if node, ok := sl.Get(""); !ok || node.Key() != "" || len(node.Value()) != 0 {
+		t.Errorf("Get('') = %v, %v", node, ok)
+	}
+}
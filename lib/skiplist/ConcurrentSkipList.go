@@ -0,0 +1,164 @@
+package skiplist
+
+import (
+	"NoSQLDB/lib/comparator"
+	"math/rand"
+	"sync/atomic"
+)
+
+// concurrentNode links a key into the concurrent skip list.
+// The version of the key is an immutable Node, an overwrite swaps it as a whole.
+type concurrentNode struct {
+	key     string
+	version atomic.Pointer[Node]
+	next    []atomic.Pointer[concurrentNode]
+}
+
+// ConcurrentSkipList is a skip list which can be written and read by many goroutines at once.
+// New nodes are linked in with compare-and-swap on the forward pointers, from the bottom level up,
+// so a node is visible to readers as soon as it is linked on the bottom level.
+// Nodes are never unlinked, deletes are stored as tombstones.
+type ConcurrentSkipList struct {
+	maxLevel int
+	head     *concurrentNode
+	size     atomic.Int64
+	cmp      comparator.Comparator
+}
+
+func NewConcurrentSkipList(maxLevel int) *ConcurrentSkipList {
+	return NewConcurrentSkipListWithComparator(maxLevel, comparator.Bytewise)
+}
+
+// NewConcurrentSkipListWithComparator creates a concurrent skip list which orders its keys with the comparator
+func NewConcurrentSkipListWithComparator(maxLevel int, cmp comparator.Comparator) *ConcurrentSkipList {
+	return &ConcurrentSkipList{
+		maxLevel: maxLevel,
+		head:     &concurrentNode{next: make([]atomic.Pointer[concurrentNode], maxLevel+1)},
+		cmp:      cmp,
+	}
+}
+
+func (sl *ConcurrentSkipList) roll() int {
+	level := 0
+	for rand.Int31n(2) == 1 && level < sl.maxLevel {
+		level++
+	}
+	return level
+}
+
+// find fills preds and succs with the last node before the key and the node after it on every level.
+// It returns the node of the key, nil if the key is not linked in yet.
+func (sl *ConcurrentSkipList) find(key string, preds, succs []*concurrentNode) *concurrentNode {
+	current := sl.head
+	for i := sl.maxLevel; i >= 0; i-- {
+		next := current.next[i].Load()
+		for next != nil && sl.cmp.Compare(next.key, key) < 0 {
+			current = next
+			next = current.next[i].Load()
+		}
+		if preds != nil {
+			preds[i], succs[i] = current, next
+		}
+	}
+
+	next := current.next[0].Load()
+	if next != nil && next.key == key {
+		return next
+	}
+	return nil
+}
+
+// PutVersion inserts or overwrites a key with the given value, tombstone, merge and pointer flags, timestamp and expiry.
+// It returns the version it replaced, nil if the key was not present.
+func (sl *ConcurrentSkipList) PutVersion(key string, value []byte, tombstone, merge, pointer bool, timestamp, expiry int64) *Node {
+	version := &Node{
+		key:       key,
+		value:     value,
+		tombstone: tombstone,
+		merge:     merge,
+		pointer:   pointer,
+		timestamp: timestamp,
+		expiry:    expiry,
+	}
+
+	preds := make([]*concurrentNode, sl.maxLevel+1)
+	succs := make([]*concurrentNode, sl.maxLevel+1)
+
+	var node *concurrentNode
+	for {
+		if existing := sl.find(key, preds, succs); existing != nil {
+			return existing.version.Swap(version)
+		}
+
+		if node == nil {
+			node = &concurrentNode{key: key, next: make([]atomic.Pointer[concurrentNode], sl.roll()+1)}
+			node.version.Store(version)
+		}
+		node.next[0].Store(succs[0])
+
+		// another writer linked a node in between, look again
+		if preds[0].next[0].CompareAndSwap(succs[0], node) {
+			break
+		}
+	}
+	sl.size.Add(1)
+
+	for i := 1; i < len(node.next); i++ {
+		for {
+			node.next[i].Store(succs[i])
+			if preds[i].next[i].CompareAndSwap(succs[i], node) {
+				break
+			}
+			sl.find(key, preds, succs)
+		}
+	}
+
+	return nil
+}
+
+// Get returns the current version of the key, including deleted ones.
+func (sl *ConcurrentSkipList) Get(key string) (*Node, bool) {
+	node := sl.find(key, nil, nil)
+	if node == nil {
+		return nil, false
+	}
+	return node.version.Load(), true
+}
+
+// Nodes returns the current version of every key, ordered by key
+func (sl *ConcurrentSkipList) Nodes() []*Node {
+	nodes := make([]*Node, 0, sl.Size())
+	for node := sl.head.next[0].Load(); node != nil; node = node.next[0].Load() {
+		nodes = append(nodes, node.version.Load())
+	}
+	return nodes
+}
+
+// ConcurrentIterator walks the nodes of a concurrent skip list ordered by key.
+// Nodes linked in while iterating are seen if they come after the current one.
+type ConcurrentIterator struct {
+	current *concurrentNode
+}
+
+// Iterator returns an iterator positioned before the first node
+func (sl *ConcurrentSkipList) Iterator() *ConcurrentIterator {
+	return &ConcurrentIterator{current: sl.head}
+}
+
+// Next moves to the next node, it returns false once there are none left
+func (it *ConcurrentIterator) Next() bool {
+	if it.current == nil {
+		return false
+	}
+	it.current = it.current.next[0].Load()
+	return it.current != nil
+}
+
+// Node returns the current version of the key the iterator is at
+func (it *ConcurrentIterator) Node() *Node {
+	return it.current.version.Load()
+}
+
+func (sl *ConcurrentSkipList) Size() int {
+	return int(sl.size.Load())
+}
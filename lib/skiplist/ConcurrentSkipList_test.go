@@ -0,0 +1,50 @@
+package skiplist
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSkipList(t *testing.T) {
+	sl := NewConcurrentSkipList(8)
+
+	const writers, keys = 8, 500
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// every writer puts all keys, so they race on inserting and overwriting the same nodes
+			for i := 0; i < keys; i++ {
+				key := fmt.Sprintf("key%04d", (i*7+w*31)%keys)
+				sl.PutVersion(key, []byte(fmt.Sprint(w)), false, false, false, int64(i), 0)
+				if _, ok := sl.Get(key); !ok {
+					t.Errorf("Get(%s) missed a key written before", key)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if sl.Size() != keys {
+		t.Errorf("Size() = %d; want %d", sl.Size(), keys)
+	}
+
+	nodes := sl.Nodes()
+	if len(nodes) != keys {
+		t.Fatalf("len(Nodes()) = %d; want %d", len(nodes), keys)
+	}
+	for i, node := range nodes {
+		if want := fmt.Sprintf("key%04d", i); node.Key() != want {
+			t.Fatalf("Nodes()[%d] = %s; want %s", i, node.Key(), want)
+		}
+	}
+
+	if old := sl.PutVersion("key0001", nil, true, false, false, 1, 0); old == nil {
+		t.Errorf("PutVersion() over an existing key returned no old version")
+	}
+	if node, ok := sl.Get("key0001"); !ok || !node.Tombstone() {
+		t.Errorf("Get(key0001) = %v, %v; want a tombstone", node, ok)
+	}
+}
@@ -1,6 +1,7 @@
 package skiplist
 
 import (
+	"NoSQLDB/lib/comparator"
 	"fmt"
 	"math/rand"
 )
@@ -10,6 +11,10 @@ type Node struct {
 	key       string
 	value     []byte
 	tombstone bool
+	merge     bool // value holds merge operands instead of a full value
+	pointer   bool // value holds a pointer into the value log instead of the value
+	timestamp int64
+	expiry    int64
 	forward   []*Node
 }
 
@@ -25,14 +30,36 @@ func (n *Node) Tombstone() bool {
 	return n.tombstone
 }
 
+func (n *Node) Merge() bool {
+	return n.merge
+}
+
+func (n *Node) Pointer() bool {
+	return n.pointer
+}
+
+func (n *Node) Timestamp() int64 {
+	return n.timestamp
+}
+
+func (n *Node) Expiry() int64 {
+	return n.expiry
+}
+
 type SkipList struct {
 	maxLevel int
 	head     *Node
 	level    int
+	cmp      comparator.Comparator
 }
 
 // NewSkipList creates a new SkipList with the specified maximum level
 func NewSkipList(maxLevel int) *SkipList {
+	return NewSkipListWithComparator(maxLevel, comparator.Bytewise)
+}
+
+// NewSkipListWithComparator creates a new SkipList which orders its keys with the comparator
+func NewSkipListWithComparator(maxLevel int, cmp comparator.Comparator) *SkipList {
 	head := &Node{
 		forward: make([]*Node, maxLevel+1),
 	}
@@ -40,6 +67,7 @@ func NewSkipList(maxLevel int) *SkipList {
 		maxLevel: maxLevel,
 		head:     head,
 		level:    0,
+		cmp:      cmp,
 	}
 }
 
@@ -55,11 +83,17 @@ func (s *SkipList) roll() int {
 
 // Put inserts a key-value pair into the skip list.
 func (sl *SkipList) Put(key string, value []byte) {
+	sl.PutVersion(key, value, false, false, false, 0, 0)
+}
+
+// PutVersion inserts or overwrites a key with the given value, tombstone, merge and pointer flags, timestamp and expiry.
+// Unlike LogicallyDelete, a tombstone is stored even if the key was not present.
+func (sl *SkipList) PutVersion(key string, value []byte, tombstone, merge, pointer bool, timestamp, expiry int64) {
 	update := make([]*Node, sl.maxLevel+1)
 	current := sl.head
 
 	for i := sl.level; i >= 0; i-- {
-		for current.forward[i] != nil && current.forward[i].key < key {
+		for current.forward[i] != nil && sl.cmp.Compare(current.forward[i].key, key) < 0 {
 			current = current.forward[i]
 		}
 		update[i] = current
@@ -67,12 +101,12 @@ func (sl *SkipList) Put(key string, value []byte) {
 
 	current = current.forward[0]
 	if current != nil && current.key == key {
-		if !current.tombstone {
-			current.value = value
-		} else {
-			current.value = value
-			current.tombstone = false
-		}
+		current.value = value
+		current.tombstone = tombstone
+		current.merge = merge
+		current.pointer = pointer
+		current.timestamp = timestamp
+		current.expiry = expiry
 		return
 	}
 
@@ -87,7 +121,11 @@ func (sl *SkipList) Put(key string, value []byte) {
 	newNode := &Node{
 		key:       key,
 		value:     value,
-		tombstone: false,
+		tombstone: tombstone,
+		merge:     merge,
+		pointer:   pointer,
+		timestamp: timestamp,
+		expiry:    expiry,
 		forward:   make([]*Node, newLevel+1),
 	}
 
@@ -101,7 +139,7 @@ func (sl *SkipList) Put(key string, value []byte) {
 func (sl *SkipList) Get(key string) (*Node, bool) {
 	current := sl.head
 	for i := sl.level; i >= 0; i-- {
-		for current.forward[i] != nil && current.forward[i].key < key {
+		for current.forward[i] != nil && sl.cmp.Compare(current.forward[i].key, key) < 0 {
 			current = current.forward[i]
 		}
 	}
@@ -114,13 +152,30 @@ func (sl *SkipList) Get(key string) (*Node, bool) {
 	return nil, false
 }
 
+// Find returns the node of the key, including a logically deleted one.
+func (sl *SkipList) Find(key string) (*Node, bool) {
+	current := sl.head
+	for i := sl.level; i >= 0; i-- {
+		for current.forward[i] != nil && sl.cmp.Compare(current.forward[i].key, key) < 0 {
+			current = current.forward[i]
+		}
+	}
+
+	current = current.forward[0]
+	if current != nil && current.key == key {
+		return current, true
+	}
+
+	return nil, false
+}
+
 // LogicallyDelete marks the node with the given key as logically deleted.
 func (sl *SkipList) LogicallyDelete(key string) bool {
 	update := make([]*Node, sl.maxLevel+1)
 	current := sl.head
 
 	for i := sl.level; i >= 0; i-- {
-		for current.forward[i] != nil && current.forward[i].key < key {
+		for current.forward[i] != nil && sl.cmp.Compare(current.forward[i].key, key) < 0 {
 			current = current.forward[i]
 		}
 		update[i] = current
@@ -175,3 +230,26 @@ func (sl *SkipList) GetAllNodes() []*Node {
 
 	return allNodes
 }
+
+// Iterator walks the nodes of a skip list ordered by key, including logically deleted ones
+type Iterator struct {
+	current *Node
+}
+
+// Iterator returns an iterator positioned before the first node
+func (sl *SkipList) Iterator() *Iterator {
+	return &Iterator{current: sl.head}
+}
+
+// Next moves to the next node, it returns false once there are none left
+func (it *Iterator) Next() bool {
+	if it.current == nil {
+		return false
+	}
+	it.current = it.current.forward[0]
+	return it.current != nil
+}
+
+func (it *Iterator) Node() *Node {
+	return it.current
+}
@@ -0,0 +1,254 @@
+package timeseries
+
+import (
+	"NoSQLDB/lib/comparator"
+	"NoSQLDB/lib/engine"
+	mt "NoSQLDB/lib/memtable"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// POINT_SEPARATOR separates the series name from the timestamp of a point
+	POINT_SEPARATOR = "\x00"
+	// ROLLUP_SEPARATOR separates the series name from the resolution of a rollup
+	ROLLUP_SEPARATOR = "\x01"
+
+	TIMESTAMP_SIZE = 8
+	VALUE_SIZE     = 8
+	ROLLUP_SIZE    = 4 * 8 // min, max, sum, count
+
+	// name of the compaction hook computing the rollups
+	ROLLUP_HOOK = "timeseries-rollup"
+)
+
+var ErrInvalidSeries = errors.New("series name can not contain \\x00 or \\x01")
+
+// TS stores the points of time series under keys ending in a big endian timestamp,
+// so sstable order equals time order within a series.
+type TS struct {
+	engine      *engine.Engine
+	resolutions []time.Duration
+}
+
+type Point struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// Rollup aggregates the points of a series within one bucket of a resolution.
+type Rollup struct {
+	Start time.Time
+	Min   float64
+	Max   float64
+	Sum   float64
+	Count uint64
+}
+
+func (r *Rollup) Avg() float64 {
+	return r.Sum / float64(r.Count)
+}
+
+// NewTS creates a time series facade over the engine.
+// For every resolution the points are rolled up into buckets whenever the sstables are compacted.
+// A bucket is recomputed while any of its points is stored, so rollups outlive expired or deleted points.
+// Only one facade with resolutions can be created per engine, another one fails with engine.ErrCompactionHookExists.
+func NewTS(e *engine.Engine, resolutions ...time.Duration) (*TS, error) {
+	ts := &TS{
+		engine:      e,
+		resolutions: resolutions,
+	}
+	if len(resolutions) > 0 {
+		if err := e.RegisterCompactionHook(ROLLUP_HOOK, ts.rollup); err != nil {
+			return nil, err
+		}
+	}
+	return ts, nil
+}
+
+func (ts *TS) Append(series string, timestamp time.Time, value float64) error {
+	if !isSeriesValid(series) {
+		return ErrInvalidSeries
+	}
+
+	data := make([]byte, VALUE_SIZE)
+	binary.BigEndian.PutUint64(data, math.Float64bits(value))
+
+	return ts.engine.Put(pointKey(series, timestamp), data)
+}
+
+// Range returns the points of the series in [from, to), ordered by time.
+func (ts *TS) Range(series string, from, to time.Time) ([]Point, error) {
+	if !isSeriesValid(series) {
+		return nil, ErrInvalidSeries
+	}
+
+	entries, err := ts.scan(series+POINT_SEPARATOR, pointKey(series, from), pointKey(series, to))
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]Point, 0, len(entries))
+	for _, entry := range entries {
+		_, timestamp, ok := parsePointKey(entry.Key())
+		if !ok || len(entry.Value()) != VALUE_SIZE {
+			continue
+		}
+		points = append(points, Point{timestamp, math.Float64frombits(binary.BigEndian.Uint64(entry.Value()))})
+	}
+
+	return points, nil
+}
+
+// Rollups returns the buckets of the resolution overlapping [from, to), ordered by time.
+// Points written since the last compaction are not rolled up yet.
+func (ts *TS) Rollups(series string, resolution time.Duration, from, to time.Time) ([]Rollup, error) {
+	if !isSeriesValid(series) {
+		return nil, ErrInvalidSeries
+	}
+
+	prefix := fmt.Sprintf("%s%s%s%s", series, ROLLUP_SEPARATOR, resolution, POINT_SEPARATOR)
+	start := rollupKey(series, resolution, from.Truncate(resolution))
+	entries, err := ts.scan(prefix, start, rollupKey(series, resolution, to))
+	if err != nil {
+		return nil, err
+	}
+
+	rollups := make([]Rollup, 0, len(entries))
+	for _, entry := range entries {
+		if rollup, ok := decodeRollup(entry.Key(), entry.Value()); ok {
+			rollups = append(rollups, *rollup)
+		}
+	}
+
+	return rollups, nil
+}
+
+// scan returns the entries with keys in the bytewise range [start, end), which all share the prefix.
+// The keys are laid out for bytewise order, so with other comparators the prefix is scanned and sorted.
+func (ts *TS) scan(prefix, start, end string) ([]*mt.Entry, error) {
+	if ts.engine.Comparator.Name() == comparator.Bytewise.Name() {
+		return ts.engine.Scan(start, end)
+	}
+
+	entries, err := ts.engine.ScanPrefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var inRange []*mt.Entry
+	for _, entry := range entries {
+		if start <= entry.Key() && entry.Key() < end {
+			inRange = append(inRange, entry)
+		}
+	}
+	sort.Slice(inRange, func(i, j int) bool {
+		return inRange[i].Key() < inRange[j].Key()
+	})
+	return inRange, nil
+}
+
+// rollup is the compaction hook which aggregates the stored points into buckets
+func (ts *TS) rollup(live []*mt.Entry) []*mt.Entry {
+	buckets := make(map[string]*Rollup)
+	for _, entry := range live {
+		series, timestamp, ok := parsePointKey(entry.Key())
+		if !ok || len(entry.Value()) != VALUE_SIZE {
+			continue
+		}
+		value := math.Float64frombits(binary.BigEndian.Uint64(entry.Value()))
+
+		for _, resolution := range ts.resolutions {
+			start := timestamp.Truncate(resolution)
+			key := rollupKey(series, resolution, start)
+
+			bucket, ok := buckets[key]
+			if !ok {
+				buckets[key] = &Rollup{start, value, value, value, 1}
+				continue
+			}
+			bucket.Min = math.Min(bucket.Min, value)
+			bucket.Max = math.Max(bucket.Max, value)
+			bucket.Sum += value
+			bucket.Count++
+		}
+	}
+
+	keys := make([]string, 0, len(buckets))
+	for key := range buckets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	now := time.Now().UnixNano()
+	derived := make([]*mt.Entry, 0, len(keys))
+	for _, key := range keys {
+		derived = append(derived, mt.NewEntryAt(key, encodeRollup(buckets[key]), false, now, 0))
+	}
+	return derived
+}
+
+func isSeriesValid(series string) bool {
+	return !strings.ContainsAny(series, POINT_SEPARATOR+ROLLUP_SEPARATOR)
+}
+
+// encodeTimestamp flips the sign bit, so negative timestamps sort before positive ones
+func encodeTimestamp(timestamp time.Time) string {
+	data := make([]byte, TIMESTAMP_SIZE)
+	binary.BigEndian.PutUint64(data, uint64(timestamp.UnixNano())^(1<<63))
+	return string(data)
+}
+
+func decodeTimestamp(data string) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64([]byte(data))^(1<<63)))
+}
+
+func pointKey(series string, timestamp time.Time) string {
+	return series + POINT_SEPARATOR + encodeTimestamp(timestamp)
+}
+
+// parsePointKey splits a point key into its series and timestamp
+func parsePointKey(key string) (string, time.Time, bool) {
+	split := len(key) - TIMESTAMP_SIZE - len(POINT_SEPARATOR)
+	if split < 0 || key[split:split+len(POINT_SEPARATOR)] != POINT_SEPARATOR {
+		return "", time.Time{}, false
+	}
+
+	series := key[:split]
+	if !isSeriesValid(series) {
+		return "", time.Time{}, false
+	}
+	return series, decodeTimestamp(key[split+len(POINT_SEPARATOR):]), true
+}
+
+func rollupKey(series string, resolution time.Duration, start time.Time) string {
+	return fmt.Sprintf("%s%s%s%s%s", series, ROLLUP_SEPARATOR, resolution, POINT_SEPARATOR, encodeTimestamp(start))
+}
+
+func encodeRollup(rollup *Rollup) []byte {
+	data := make([]byte, ROLLUP_SIZE)
+	binary.BigEndian.PutUint64(data[0:8], math.Float64bits(rollup.Min))
+	binary.BigEndian.PutUint64(data[8:16], math.Float64bits(rollup.Max))
+	binary.BigEndian.PutUint64(data[16:24], math.Float64bits(rollup.Sum))
+	binary.BigEndian.PutUint64(data[24:32], rollup.Count)
+	return data
+}
+
+func decodeRollup(key string, data []byte) (*Rollup, bool) {
+	if len(data) != ROLLUP_SIZE || len(key) < TIMESTAMP_SIZE {
+		return nil, false
+	}
+
+	return &Rollup{
+		Start: decodeTimestamp(key[len(key)-TIMESTAMP_SIZE:]),
+		Min:   math.Float64frombits(binary.BigEndian.Uint64(data[0:8])),
+		Max:   math.Float64frombits(binary.BigEndian.Uint64(data[8:16])),
+		Sum:   math.Float64frombits(binary.BigEndian.Uint64(data[16:24])),
+		Count: binary.BigEndian.Uint64(data[24:32]),
+	}, true
+}
@@ -0,0 +1,144 @@
+package timeseries
+
+import (
+	cfg "NoSQLDB/lib/config"
+	"NoSQLDB/lib/engine"
+	mt "NoSQLDB/lib/memtable"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPointKeyOrder(t *testing.T) {
+	times := []time.Time{time.Unix(-10, 0), time.Unix(0, 0), time.Unix(5, 0), time.Unix(1<<32, 0)}
+
+	for i := 1; i < len(times); i++ {
+		if pointKey("s", times[i-1]) >= pointKey("s", times[i]) {
+			t.Errorf("pointKey(%v) >= pointKey(%v)", times[i-1], times[i])
+		}
+	}
+
+	series, timestamp, ok := parsePointKey(pointKey("sensor-1", times[0]))
+	if !ok || series != "sensor-1" || !timestamp.Equal(times[0]) {
+		t.Errorf("parsePointKey() = %s, %v, %v; want sensor-1, %v, true", series, timestamp, ok, times[0])
+	}
+}
+
+func TestRollup(t *testing.T) {
+	ts := &TS{resolutions: []time.Duration{time.Minute}}
+
+	var live []*mt.Entry
+	for i, value := range []float64{4, 2, 6, 10} {
+		data := make([]byte, VALUE_SIZE)
+		binary.BigEndian.PutUint64(data, math.Float64bits(value))
+		live = append(live, mt.NewEntry(pointKey("s", time.Unix(int64(i*20), 0)), data, false))
+	}
+
+	derived := ts.rollup(live)
+	if len(derived) != 2 {
+		t.Fatalf("rollup() returned %d buckets; want 2", len(derived))
+	}
+
+	first, _ := decodeRollup(derived[0].Key(), derived[0].Value())
+	if first.Min != 2 || first.Max != 6 || first.Avg() != 4 || first.Count != 3 {
+		t.Errorf("first bucket = %+v; want min 2, max 6, avg 4, count 3", first)
+	}
+	if !first.Start.Equal(time.Unix(0, 0)) {
+		t.Errorf("first bucket starts at %v; want %v", first.Start, time.Unix(0, 0))
+	}
+}
+
+func openEngine(t *testing.T) *engine.Engine {
+	dir := t.TempDir()
+	config := cfg.GetDefaultConfig()
+	config.WALDir = dir + "/wal/"
+	config.OutputDir = dir + "/sstable/"
+	config.TokenBucketSize = 1 << 30
+	// the test decides when the tables are compacted
+	config.CompactionThreshold = 0
+
+	e, err := engine.NewEngine(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Restore(*config); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func flushAndCompact(t *testing.T, e *engine.Engine) {
+	t.Helper()
+	if err := e.Mempool.FlushAll(); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Compact(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkRollups(t *testing.T, ts *TS, from time.Time, want ...Rollup) {
+	t.Helper()
+	rollups, err := ts.Rollups("s", time.Minute, from, from.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rollups) != len(want) {
+		t.Fatalf("Rollups() = %+v; want %+v", rollups, want)
+	}
+	for i := range want {
+		if !rollups[i].Start.Equal(want[i].Start) || rollups[i].Min != want[i].Min || rollups[i].Max != want[i].Max ||
+			rollups[i].Sum != want[i].Sum || rollups[i].Count != want[i].Count {
+			t.Errorf("Rollups()[%d] = %+v; want %+v", i, rollups[i], want[i])
+		}
+	}
+}
+
+func TestTimeSeriesOnEngine(t *testing.T) {
+	e := openEngine(t)
+	ts, err := NewTS(e, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTS(e, time.Hour); !errors.Is(err, engine.ErrCompactionHookExists) {
+		t.Errorf("NewTS() of a second rollup facade = %v; want %v", err, engine.ErrCompactionHookExists)
+	}
+	if _, err := NewTS(e); err != nil {
+		t.Errorf("NewTS() without resolutions = %v; want nil", err)
+	}
+
+	start := time.Unix(60*28_333_333, 0)
+	for i, value := range []float64{4, 2, 6, 10} {
+		if err := ts.Append("s", start.Add(time.Duration(i*20)*time.Second), value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ts.Append("other", start, 100)
+
+	points, err := ts.Range("s", start, start.Add(time.Minute))
+	if err != nil || len(points) != 3 || points[0].Value != 4 || points[2].Value != 6 || !points[1].Timestamp.Equal(start.Add(20*time.Second)) {
+		t.Errorf("Range() = %+v, %v; want the 3 points of the first minute", points, err)
+	}
+
+	// points are rolled up by compactions only
+	checkRollups(t, ts, start)
+
+	flushAndCompact(t, e)
+	checkRollups(t, ts, start,
+		Rollup{Start: start, Min: 2, Max: 6, Sum: 12, Count: 3},
+		Rollup{Start: start.Add(time.Minute), Min: 10, Max: 10, Sum: 10, Count: 1})
+
+	// a late point recomputes its bucket from every stored point on the next compaction
+	ts.Append("s", start.Add(50*time.Second), 20)
+	flushAndCompact(t, e)
+	checkRollups(t, ts, start,
+		Rollup{Start: start, Min: 2, Max: 20, Sum: 32, Count: 4},
+		Rollup{Start: start.Add(time.Minute), Min: 10, Max: 10, Sum: 10, Count: 1})
+
+	points, err = ts.Range("s", start, start.Add(time.Hour))
+	if err != nil || len(points) != 5 {
+		t.Errorf("Range() after the compactions = %+v, %v; want 5 points", points, err)
+	}
+}
@@ -0,0 +1,15 @@
+package utils
+
+import "unsafe"
+
+// BytesToString returns a string sharing the memory of the byte slice, without copying it.
+// The bytes must not be modified afterwards, since strings are assumed to be immutable.
+func BytesToString(b []byte) string {
+	return unsafe.String(unsafe.SliceData(b), len(b))
+}
+
+// StringToBytes returns a byte slice sharing the memory of the string, without copying it.
+// The returned slice must not be modified.
+func StringToBytes(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
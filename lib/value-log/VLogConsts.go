@@ -0,0 +1,21 @@
+package valuelog
+
+const (
+	CRC_SIZE         = 4
+	FAMILY_SIZE_SIZE = 8
+	KEY_SIZE_SIZE    = 8
+	VALUE_SIZE_SIZE  = 8
+	HEADER_SIZE      = CRC_SIZE + FAMILY_SIZE_SIZE + KEY_SIZE_SIZE + VALUE_SIZE_SIZE
+
+	CRC_START         = 0
+	FAMILY_SIZE_START = CRC_START + CRC_SIZE
+	KEY_SIZE_START    = FAMILY_SIZE_START + FAMILY_SIZE_SIZE
+	VALUE_SIZE_START  = KEY_SIZE_START + KEY_SIZE_SIZE
+	FAMILY_START      = VALUE_SIZE_START + VALUE_SIZE_SIZE
+
+	FILE_SIZE   = 4
+	OFFSET_SIZE = 8
+	LENGTH_SIZE = 4
+
+	POINTER_SIZE = FILE_SIZE + OFFSET_SIZE + LENGTH_SIZE
+)
@@ -0,0 +1,322 @@
+package valuelog
+
+import (
+	hash "NoSQLDB/lib/utils"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+/*
+   +---------------+------------------+---------------+-----------------+---...--+-...-+--...--+
+   |    CRC (4B)   | Family Size (8B) | Key Size (8B) | Value Size (8B) | Family | Key | Value |
+   +---------------+------------------+---------------+-----------------+---...--+-...-+--...--+
+   CRC = 32bit hash computed over the rest of the record
+   Family = Column family of the key, empty for the default keyspace
+   Key = Key the value was written under, used to check whether the value is still live
+   Value = Value data
+
+   Entries keep a pointer to the record instead of the value:
+
+   +-----------+-------------+-------------+
+   | File (4B) | Offset (8B) | Length (4B) |
+   +-----------+-------------+-------------+
+*/
+
+var ErrInvalidPointer = errors.New("invalid value log pointer")
+var ErrCorruptedRecord = errors.New("value log record is corrupted")
+
+// ValueLog is an append-only log of values which are too large to be copied by every flush and compaction.
+// Values are appended to the current file until it reaches the file size, then a new file is started.
+type ValueLog struct {
+	mu          sync.Mutex // guards the current file
+	path        string
+	fileSize    int
+	index       int // current file
+	currentFile *os.File
+	offset      int64 // size of the current file
+}
+
+// Pointer locates a record of the value log
+type Pointer struct {
+	File   int
+	Offset int64
+	Length int
+}
+
+func (p Pointer) Serialize() []byte {
+	data := make([]byte, POINTER_SIZE)
+	binary.BigEndian.PutUint32(data[:FILE_SIZE], uint32(p.File))
+	binary.BigEndian.PutUint64(data[FILE_SIZE:FILE_SIZE+OFFSET_SIZE], uint64(p.Offset))
+	binary.BigEndian.PutUint32(data[FILE_SIZE+OFFSET_SIZE:], uint32(p.Length))
+	return data
+}
+
+func DeserializePointer(data []byte) (Pointer, error) {
+	if len(data) != POINTER_SIZE {
+		return Pointer{}, ErrInvalidPointer
+	}
+	return Pointer{
+		File:   int(binary.BigEndian.Uint32(data[:FILE_SIZE])),
+		Offset: int64(binary.BigEndian.Uint64(data[FILE_SIZE : FILE_SIZE+OFFSET_SIZE])),
+		Length: int(binary.BigEndian.Uint32(data[FILE_SIZE+OFFSET_SIZE:])),
+	}, nil
+}
+
+// Record is a value read back from a value log file together with its key and pointer
+type Record struct {
+	Family  []byte
+	Key     []byte
+	Value   []byte
+	Pointer []byte
+	Size    int // size of the whole record in bytes
+}
+
+func NewValueLog(path string, fileSize int) (*ValueLog, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+
+	files, err := scanFolder(path)
+	if err != nil {
+		return nil, err
+	}
+
+	index := 1
+	if len(files) > 0 {
+		index = files[len(files)-1]
+	}
+
+	file, err := os.OpenFile(fileName(path, index), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ValueLog{
+		path:        path,
+		fileSize:    fileSize,
+		index:       index,
+		currentFile: file,
+		offset:      stat.Size(),
+	}, nil
+}
+
+// Append writes the value of the key in the column family to the log and returns the serialized pointer to it.
+// The family is empty for the default keyspace.
+func (vl *ValueLog) Append(family, key, value []byte) ([]byte, error) {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+
+	if vl.offset > 0 && vl.offset >= int64(vl.fileSize) {
+		if err := vl.createNewFile(); err != nil {
+			return nil, err
+		}
+	}
+
+	record := serializeRecord(family, key, value)
+	if _, err := vl.currentFile.Write(record); err != nil {
+		return nil, err
+	}
+
+	pointer := Pointer{File: vl.index, Offset: vl.offset, Length: len(record)}
+	vl.offset += int64(len(record))
+	return pointer.Serialize(), nil
+}
+
+// Read returns the value the pointer refers to
+func (vl *ValueLog) Read(pointer []byte) ([]byte, error) {
+	p, err := DeserializePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(fileName(vl.path, p.File))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	record := make([]byte, p.Length)
+	if _, err := file.ReadAt(record, p.Offset); err != nil {
+		return nil, err
+	}
+
+	_, _, value, err := deserializeRecord(record)
+	return value, err
+}
+
+// Sync flushes the current file to the disk
+func (vl *ValueLog) Sync() error {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+
+	return vl.currentFile.Sync()
+}
+
+func (vl *ValueLog) Close() error {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+
+	return vl.currentFile.Close()
+}
+
+// SealedFiles returns the files which are no longer appended to, oldest first
+func (vl *ValueLog) SealedFiles() ([]int, error) {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+
+	files, err := scanFolder(vl.path)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make([]int, 0, len(files))
+	for _, file := range files {
+		if file != vl.index {
+			sealed = append(sealed, file)
+		}
+	}
+	return sealed, nil
+}
+
+// Records reads every record of the file in the order they were written.
+// A record cut short by a crash ends the file.
+func (vl *ValueLog) Records(file int) ([]Record, error) {
+	data, err := os.ReadFile(fileName(vl.path, file))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	offset := 0
+	for len(data)-offset >= HEADER_SIZE {
+		familySize := int(binary.BigEndian.Uint64(data[offset+FAMILY_SIZE_START : offset+KEY_SIZE_START]))
+		keySize := int(binary.BigEndian.Uint64(data[offset+KEY_SIZE_START : offset+VALUE_SIZE_START]))
+		valueSize := int(binary.BigEndian.Uint64(data[offset+VALUE_SIZE_START : offset+FAMILY_START]))
+		length := HEADER_SIZE + familySize + keySize + valueSize
+		if familySize < 0 || keySize < 0 || valueSize < 0 || length > len(data)-offset {
+			break
+		}
+
+		family, key, value, err := deserializeRecord(data[offset : offset+length])
+		if err != nil {
+			return nil, err
+		}
+
+		pointer := Pointer{File: file, Offset: int64(offset), Length: length}
+		records = append(records, Record{Family: family, Key: key, Value: value, Pointer: pointer.Serialize(), Size: length})
+		offset += length
+	}
+
+	return records, nil
+}
+
+// Size returns the size of the file in bytes
+func (vl *ValueLog) Size(file int) (int64, error) {
+	stat, err := os.Stat(fileName(vl.path, file))
+	if err != nil {
+		return 0, err
+	}
+	return stat.Size(), nil
+}
+
+// Remove deletes a sealed file once none of its values are referenced anymore
+func (vl *ValueLog) Remove(file int) error {
+	vl.mu.Lock()
+	defer vl.mu.Unlock()
+
+	if file == vl.index {
+		return fmt.Errorf("value log file %d is still being written", file)
+	}
+	return os.Remove(fileName(vl.path, file))
+}
+
+func (vl *ValueLog) createNewFile() error {
+	if err := vl.currentFile.Close(); err != nil {
+		return err
+	}
+
+	file, err := os.Create(fileName(vl.path, vl.index+1))
+	if err != nil {
+		return err
+	}
+
+	vl.index++
+	vl.currentFile = file
+	vl.offset = 0
+	return nil
+}
+
+func serializeRecord(family, key, value []byte) []byte {
+	record := make([]byte, FAMILY_START, HEADER_SIZE+len(family)+len(key)+len(value))
+	binary.BigEndian.PutUint64(record[FAMILY_SIZE_START:KEY_SIZE_START], uint64(len(family)))
+	binary.BigEndian.PutUint64(record[KEY_SIZE_START:VALUE_SIZE_START], uint64(len(key)))
+	binary.BigEndian.PutUint64(record[VALUE_SIZE_START:FAMILY_START], uint64(len(value)))
+	record = append(record, family...)
+	record = append(record, key...)
+	record = append(record, value...)
+
+	copy(record[CRC_START:FAMILY_SIZE_START], hash.Crc32Byte(record[FAMILY_SIZE_START:]))
+	return record
+}
+
+// deserializeRecord returns the family, key and value of the record
+func deserializeRecord(record []byte) ([]byte, []byte, []byte, error) {
+	if len(record) < HEADER_SIZE {
+		return nil, nil, nil, io.ErrUnexpectedEOF
+	}
+
+	if !bytes.Equal(record[CRC_START:FAMILY_SIZE_START], hash.Crc32Byte(record[FAMILY_SIZE_START:])) {
+		return nil, nil, nil, ErrCorruptedRecord
+	}
+
+	familySize := int(binary.BigEndian.Uint64(record[FAMILY_SIZE_START:KEY_SIZE_START]))
+	keySize := int(binary.BigEndian.Uint64(record[KEY_SIZE_START:VALUE_SIZE_START]))
+	keyStart := FAMILY_START + familySize
+	if familySize < 0 || keySize < 0 || keyStart+keySize > len(record) {
+		return nil, nil, nil, ErrCorruptedRecord
+	}
+	return record[FAMILY_START:keyStart], record[keyStart : keyStart+keySize], record[keyStart+keySize:], nil
+}
+
+func fileName(path string, index int) string {
+	return filepath.Join(path, fmt.Sprintf("vlog_%05d.log", index))
+}
+
+// scanFolder returns the indexes of the value log files in the folder, sorted
+func scanFolder(path string) ([]int, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	re := regexp.MustCompile(`^vlog_(\d{5})\.log$`)
+
+	var files []int
+	for _, entry := range entries {
+		matches := re.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || len(matches) <= 1 {
+			continue
+		}
+		index, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, index)
+	}
+
+	sort.Ints(files)
+	return files, nil
+}
@@ -0,0 +1,79 @@
+package valuelog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestValueLogAppendRead(t *testing.T) {
+	vl, err := NewValueLog(t.TempDir(), 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vl.Close()
+
+	pointers := make([][]byte, 10)
+	for i := range pointers {
+		pointers[i], err = vl.Append([]byte("family"), []byte(fmt.Sprintf("key%d", i)), bytes.Repeat([]byte{byte(i)}, 40))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, pointer := range pointers {
+		value, err := vl.Read(pointer)
+		if err != nil || !bytes.Equal(value, bytes.Repeat([]byte{byte(i)}, 40)) {
+			t.Errorf("Read(%d) = %v, %v", i, value, err)
+		}
+	}
+
+	sealed, err := vl.SealedFiles()
+	if err != nil || len(sealed) == 0 {
+		t.Fatalf("SealedFiles() = %v, %v; want the files filled before the current one", sealed, err)
+	}
+
+	records, err := vl.Records(sealed[0])
+	if err != nil || len(records) == 0 {
+		t.Fatalf("Records(%d) = %v, %v", sealed[0], records, err)
+	}
+	if string(records[0].Family) != "family" || string(records[0].Key) != "key0" || !bytes.Equal(records[0].Pointer, pointers[0]) {
+		t.Errorf("Records(%d)[0] = %s/%s at %v; want family/key0 at %v", sealed[0], records[0].Family, records[0].Key, records[0].Pointer, pointers[0])
+	}
+
+	if err := vl.Remove(sealed[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vl.Read(pointers[0]); !os.IsNotExist(err) {
+		t.Errorf("Read() of a removed file = %v; want not exist", err)
+	}
+}
+
+func TestValueLogCorruptedRecord(t *testing.T) {
+	dir := t.TempDir()
+	vl, err := NewValueLog(dir, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vl.Close()
+
+	pointer, err := vl.Append(nil, []byte("key"), []byte("value"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := fileName(dir, 1)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := vl.Read(pointer); err != ErrCorruptedRecord {
+		t.Errorf("Read() = %v; want %v", err, ErrCorruptedRecord)
+	}
+}
@@ -1,20 +1,34 @@
 package writeaheadlog
 
 const (
-	CRC_SIZE        = 4
-	TIMESTAMP_SIZE  = 8
-	TOMBSTONE_SIZE  = 1
-	KEY_SIZE_SIZE   = 8
-	VALUE_SIZE_SIZE = 8
-	HEADER_SIZE     = CRC_SIZE + TIMESTAMP_SIZE + TOMBSTONE_SIZE + KEY_SIZE_SIZE + VALUE_SIZE_SIZE
+	CRC_SIZE         = 4
+	TIMESTAMP_SIZE   = 8
+	EXPIRY_SIZE      = 8
+	TOMBSTONE_SIZE   = 1
+	FAMILY_SIZE_SIZE = 8
+	KEY_SIZE_SIZE    = 8
+	VALUE_SIZE_SIZE  = 8
+	HEADER_SIZE      = CRC_SIZE + TIMESTAMP_SIZE + EXPIRY_SIZE + TOMBSTONE_SIZE + FAMILY_SIZE_SIZE + KEY_SIZE_SIZE + VALUE_SIZE_SIZE
 
-	CRC_START        = 0
-	TIMESTAMP_START  = CRC_START + CRC_SIZE
-	TOMBSTONE_START  = TIMESTAMP_START + TIMESTAMP_SIZE
-	KEY_SIZE_START   = TOMBSTONE_START + TOMBSTONE_SIZE
-	VALUE_SIZE_START = KEY_SIZE_START + KEY_SIZE_SIZE
-	KEY_START        = VALUE_SIZE_START + VALUE_SIZE_SIZE
+	CRC_START         = 0
+	TIMESTAMP_START   = CRC_START + CRC_SIZE
+	EXPIRY_START      = TIMESTAMP_START + TIMESTAMP_SIZE
+	TOMBSTONE_START   = EXPIRY_START + EXPIRY_SIZE
+	FAMILY_SIZE_START = TOMBSTONE_START + TOMBSTONE_SIZE
+	KEY_SIZE_START    = FAMILY_SIZE_START + FAMILY_SIZE_SIZE
+	VALUE_SIZE_START  = KEY_SIZE_START + KEY_SIZE_SIZE
+	FAMILY_START      = VALUE_SIZE_START + VALUE_SIZE_SIZE
 
 	WAL_PUT    = 0
 	WAL_DELETE = 1
+	WAL_MERGE  = 2
+
+	// the key holds the start and the value the end of the deleted range
+	WAL_RANGE_DELETE = 3
+
+	// the value holds a pointer to the value in the value log
+	WAL_POINTER = 4
+
+	// file in the WAL folder recording that every logged entry was flushed before shutting down
+	CLEAN_SHUTDOWN_FILE = "CLEAN_SHUTDOWN"
 )
@@ -9,30 +9,38 @@ import (
 )
 
 /*
-+---------------+-----------------+---------------+---------------+-----------------+-...-+--...--+
-|    CRC (4B)   | Timestamp (8B) | Tombstone(1B) | Key Size (8B) | Value Size (8B) | Key | Value |
-+---------------+-----------------+---------------+---------------+-----------------+-...-+--...--+
++---------------+-----------------+--------------+---------------+------------------+---------------+-----------------+---...--+-...-+--...--+
+|    CRC (4B)   | Timestamp (8B) | Expiry (8B) | Tombstone(1B) | Family Size (8B) | Key Size (8B) | Value Size (8B) | Family | Key | Value |
++---------------+-----------------+--------------+---------------+------------------+---------------+-----------------+---...--+-...-+--...--+
 CRC = 32bit hash computed over the payload using CRC
+Family Size = Length of the Family data
 Key Size = Length of the Key data
-Tombstone = If this record was deleted and has a value
+Tombstone = Operation of the record: 0 for put, 1 if this record was deleted and has no value, 2 for a merge operand, 3 for a range deletion where the key is the start and the value the end of the range, 4 for a put whose value is a pointer into the value log
 Value Size = Length of the Value data
+Family = Name of the column family the key belongs to, empty for the default keyspace
 Key = Key data
 Value = Value data
-Timestamp = Timestamp of the operation in seconds
+Timestamp = Timestamp of the operation in nanoseconds
+Expiry = Time in nanoseconds after which the entry is absent, 0 if it never expires
 */
 type WriteAheadLogEntry struct {
-	Key       []byte
-	Value     []byte
-	Timestamp time.Time
-	Tombstone bool
+	Family      []byte // column family of the key, empty for the default keyspace
+	Key         []byte
+	Value       []byte
+	Timestamp   time.Time
+	Expiry      int64
+	Tombstone   bool
+	Merge       bool
+	RangeDelete bool
+	Pointer     bool
 }
 
 // key:value are the only things we need to generate an entry
 // the rest is metadata which we can generate ourselves
 // NewEntry creates a new WriteAheadLogEntry
 func NewEntry(key, value []byte, operation int) (*WriteAheadLogEntry, error) {
-	if !(operation == WAL_DELETE || operation == WAL_PUT) {
-		return nil, errors.New("value must be 0 or 1")
+	if operation < WAL_PUT || operation > WAL_POINTER {
+		return nil, errors.New("value must be 0, 1, 2, 3 or 4")
 	}
 	if len(key) == 0 {
 		return nil, errors.New("key is an empty array")
@@ -44,54 +52,79 @@ func NewEntry(key, value []byte, operation int) (*WriteAheadLogEntry, error) {
 		return nil, errors.New("value is not provided while putting an entry")
 	}
 
-	tombstone := false
-	if operation == WAL_DELETE {
-		tombstone = true
-	}
-
 	return &WriteAheadLogEntry{
+		nil,
 		key,
 		value,
 		time.Now(),
-		tombstone,
+		0,
+		operation == WAL_DELETE,
+		operation == WAL_MERGE,
+		operation == WAL_RANGE_DELETE,
+		operation == WAL_POINTER,
 	}, nil
 }
 
 // RecoverEntry creates a new WriteAheadLogEntry from the given data
 // used when reading from segments
-func RecoverEntry(key, value []byte, timestamp time.Time, tombstone bool) *WriteAheadLogEntry {
+func RecoverEntry(family, key, value []byte, timestamp time.Time, expiry int64, operation int) *WriteAheadLogEntry {
 
 	return &WriteAheadLogEntry{
+		family,
 		key,
 		value,
 		timestamp,
-		tombstone,
+		expiry,
+		operation == WAL_DELETE,
+		operation == WAL_MERGE,
+		operation == WAL_RANGE_DELETE,
+		operation == WAL_POINTER,
 	}
 }
 
+// Operation returns the operation the entry was logged with
+func (entry *WriteAheadLogEntry) Operation() int {
+	if entry.Tombstone {
+		return WAL_DELETE
+	}
+	if entry.Merge {
+		return WAL_MERGE
+	}
+	if entry.RangeDelete {
+		return WAL_RANGE_DELETE
+	}
+	if entry.Pointer {
+		return WAL_POINTER
+	}
+	return WAL_PUT
+}
+
 // Serialize converts WriteAheadLogEntry to a byte array
 // Returns the byte array and the size of the byte array
 func (entry *WriteAheadLogEntry) Serialize() []byte {
 	crc := make([]byte, CRC_SIZE)
 	timestamp := make([]byte, TIMESTAMP_SIZE)
+	expiry := make([]byte, EXPIRY_SIZE)
 	tombstone := make([]byte, TOMBSTONE_SIZE)
+	familysize := make([]byte, FAMILY_SIZE_SIZE)
 	keysize := make([]byte, KEY_SIZE_SIZE)
 	valuesize := make([]byte, VALUE_SIZE_SIZE)
 
-	binary.BigEndian.PutUint64(timestamp, uint64(entry.Timestamp.Unix()))
+	binary.BigEndian.PutUint64(timestamp, uint64(entry.Timestamp.UnixNano()))
+	binary.BigEndian.PutUint64(expiry, uint64(entry.Expiry))
 
-	if entry.Tombstone {
-		tombstone[0] = 1
-	} else {
-		tombstone[0] = 0
-	}
+	tombstone[0] = byte(entry.Operation())
 
+	binary.BigEndian.PutUint64(familysize, uint64(len(entry.Family)))
 	binary.BigEndian.PutUint64(keysize, uint64(len(entry.Key)))
 	binary.BigEndian.PutUint64(valuesize, uint64(len(entry.Value)))
 
-	returnArray := append(timestamp, tombstone...)
+	returnArray := append(timestamp, expiry...)
+	returnArray = append(returnArray, tombstone...)
+	returnArray = append(returnArray, familysize...)
 	returnArray = append(returnArray, keysize...)
 	returnArray = append(returnArray, valuesize...)
+	returnArray = append(returnArray, entry.Family...)
 	returnArray = append(returnArray, entry.Key...)
 	returnArray = append(returnArray, entry.Value...)
 
@@ -103,8 +136,12 @@ func (entry *WriteAheadLogEntry) Serialize() []byte {
 }
 
 func (entry *WriteAheadLogEntry) Print() {
+	fmt.Println("Family: ", string(entry.Family))
 	fmt.Println("Key: ", string(entry.Key))
 	fmt.Println("Value: ", string(entry.Value))
 	fmt.Println("Timestamp: ", entry.Timestamp)
+	fmt.Println("Expiry: ", entry.Expiry)
 	fmt.Println("Tombstone: ", entry.Tombstone)
+	fmt.Println("Merge: ", entry.Merge)
+	fmt.Println("Range delete: ", entry.RangeDelete)
 }
@@ -45,9 +45,13 @@ func (wal *WriteAheadLog) NewWALReader() (*WALReader, error) {
 }
 
 func (reader *WALReader) loadKeyOrValue(size int) ([]byte, error) {
-	if size <= 0 {
+	if size < 0 {
 		return nil, fmt.Errorf("requested size to read is %d", size)
 	}
+	// deletes have no value
+	if size == 0 {
+		return []byte{}, nil
+	}
 
 	// Case 1: Enough bytes remaining in the current segment
 	if reader.BytesRemaining >= size {
@@ -104,6 +108,7 @@ func (reader *WALReader) loadKeyValFromMultipleSegments(size int) ([]byte, error
 	if _, err := reader.CurrentFile.Read(thirdBuffer); err != nil {
 		return nil, err
 	}
+	reader.BytesRemaining -= size
 
 	data = append(data, thirdBuffer...)
 
@@ -139,9 +144,9 @@ func (reader *WALReader) loadHeaderFromMultipleSegments() ([]byte, error) {
 
 	bytesToRead := HEADER_SIZE - reader.BytesRemaining
 
-	reader.openNextSegment()
-
-	fmt.Println(reader.BytesRemaining)
+	if err := reader.openNextSegment(); err != nil {
+		return nil, err
+	}
 
 	secondBuffer := make([]byte, bytesToRead)
 	if _, err := reader.CurrentFile.Read(secondBuffer); err != nil {
@@ -162,7 +167,12 @@ func (reader *WALReader) DeserializeEntry() (*WriteAheadLogEntry, error) {
 		return nil, err
 	}
 
-	timestamp, tombstone, keysize, valuesize := deserializeHeader(header)
+	timestamp, expiry, operation, familysize, keysize, valuesize := deserializeHeader(header)
+
+	family, err := reader.loadKeyOrValue(familysize)
+	if err != nil {
+		return nil, err
+	}
 
 	key, err := reader.loadKeyOrValue(keysize)
 	if err != nil {
@@ -175,17 +185,18 @@ func (reader *WALReader) DeserializeEntry() (*WriteAheadLogEntry, error) {
 	}
 
 	// check if the crc is correct
-	serEntry := append(append([]byte{}, header...), append(key, value...)...)
+	serEntry := append(append(append([]byte{}, header...), family...), append(key, value...)...)
 	if !checkCRC(serEntry) {
 		errorMsg := fmt.Sprintf("crc check failed")
+		errorMsg += fmt.Sprintf("\nfamily: %s", family)
 		errorMsg += fmt.Sprintf("\nkey: %s", key)
 		errorMsg += fmt.Sprintf("\nvalue: %s", value)
 		errorMsg += fmt.Sprintf("\ntimestamp: %s", timestamp)
-		errorMsg += fmt.Sprintf("\ntombstone: %t", tombstone)
+		errorMsg += fmt.Sprintf("\noperation: %d", operation)
 		return nil, errors.New(errorMsg)
 	}
 
-	return RecoverEntry(key, value, timestamp, tombstone), nil
+	return RecoverEntry(family, key, value, timestamp, expiry, operation), nil
 }
 
 func (reader *WALReader) Recover() ([]*WriteAheadLogEntry, error) {
@@ -16,11 +16,15 @@ import (
 // helper functions to deserialize the data
 
 func deserializeTimestamp(data []byte) time.Time {
-	return time.Unix(int64(binary.BigEndian.Uint64(data)), 0)
+	return time.Unix(0, int64(binary.BigEndian.Uint64(data)))
 }
 
-func deserializeTombstone(data []byte) bool {
-	return data[0] == 1
+func deserializeOperation(data []byte) int {
+	return int(data[0])
+}
+
+func deserializeFamilySize(data []byte) int {
+	return int(binary.BigEndian.Uint64(data))
 }
 
 func deserializeKeySize(data []byte) int {
@@ -35,13 +39,19 @@ func deserializeCRC(data []byte) uint32 {
 	return binary.BigEndian.Uint32(data)
 }
 
-func deserializeHeader(data []byte) (time.Time, bool, int, int) {
-	timestamp := deserializeTimestamp(data[CRC_SIZE : CRC_SIZE+TIMESTAMP_SIZE])
-	tombstone := deserializeTombstone(data[CRC_SIZE+TIMESTAMP_SIZE : CRC_SIZE+TIMESTAMP_SIZE+TOMBSTONE_SIZE])
-	keysize := deserializeKeySize(data[CRC_SIZE+TIMESTAMP_SIZE+TOMBSTONE_SIZE : CRC_SIZE+TIMESTAMP_SIZE+TOMBSTONE_SIZE+KEY_SIZE_SIZE])
-	valuesize := deserializeValueSize(data[CRC_SIZE+TIMESTAMP_SIZE+TOMBSTONE_SIZE+KEY_SIZE_SIZE : CRC_SIZE+TIMESTAMP_SIZE+TOMBSTONE_SIZE+KEY_SIZE_SIZE+VALUE_SIZE_SIZE])
+func deserializeExpiry(data []byte) int64 {
+	return int64(binary.BigEndian.Uint64(data))
+}
+
+func deserializeHeader(data []byte) (time.Time, int64, int, int, int, int) {
+	timestamp := deserializeTimestamp(data[TIMESTAMP_START:EXPIRY_START])
+	expiry := deserializeExpiry(data[EXPIRY_START:TOMBSTONE_START])
+	operation := deserializeOperation(data[TOMBSTONE_START:FAMILY_SIZE_START])
+	familysize := deserializeFamilySize(data[FAMILY_SIZE_START:KEY_SIZE_START])
+	keysize := deserializeKeySize(data[KEY_SIZE_START:VALUE_SIZE_START])
+	valuesize := deserializeValueSize(data[VALUE_SIZE_START:FAMILY_START])
 
-	return timestamp, tombstone, keysize, valuesize
+	return timestamp, expiry, operation, familysize, keysize, valuesize
 }
 
 func deserializeKeyOrValue(data []byte) string {
@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	fp "path/filepath"
+	"sync"
+	"time"
 )
 
 /* TODO:
@@ -15,16 +17,21 @@ import (
 */
 
 /*
-   +---------------+-----------------+---------------+---------------+-----------------+-...-+--...--+
-   |    CRC (4B)   | Timestamp (8B) | Tombstone(1B) | Key Size (8B) | Value Size (8B) | Key | Value |
-   +---------------+-----------------+---------------+---------------+-----------------+-...-+--...--+
+   +---------------+-----------------+--------------+---------------+------------------+---------------+-----------------+---...--+-...-+--...--+
+   |    CRC (4B)   | Timestamp (8B) | Expiry (8B) | Tombstone(1B) | Family Size (8B) | Key Size (8B) | Value Size (8B) | Family | Key | Value |
+   +---------------+-----------------+--------------+---------------+------------------+---------------+-----------------+---...--+-...-+--...--+
    CRC = 32bit hash computed over the payload using CRC
+   Family Size = Length of the Family data
    Key Size = Length of the Key data
-   Tombstone = If this record was deleted and has a value
+   Tombstone = Operation of the record: 0 for put, 1 if this record was deleted and has no value, 2 for a merge operand,
+               3 for a range deletion where the key is the start and the value the end of the range,
+               4 for a put whose value is a pointer into the value log
    Value Size = Length of the Value data
+   Family = Name of the column family the key belongs to, empty for the default keyspace
    Key = Key data
    Value = Value data
-   Timestamp = Timestamp of the operation in seconds
+   Timestamp = Timestamp of the operation in nanoseconds
+   Expiry = Time in nanoseconds after which the entry is absent, 0 if it never expires
 */
 
 type WriteAheadLog struct {
@@ -35,10 +42,16 @@ type WriteAheadLog struct {
 	Buffer         []byte // buffer for the entries
 	BytesRemaining int    // remaining bytes in the current segment
 	Path           string // contains path to the WAL folder
+
+	mu      sync.Mutex // guards the buffer and the segments
+	applyMu sync.Mutex // held while a batch logged by LogOrdered is applied, so the batches are applied in order
+	last    int64      // timestamp of the last batch logged by LogOrdered
 }
 
 func NewWriteAheadLog(filepath string, segmentSize int) (*WriteAheadLog, error) {
-	err := createWorkDir(filepath)
+	if err := createWorkDir(filepath); err != nil {
+		return nil, err
+	}
 	maxIndex, minIndex, err := ScanWALFolder(filepath)
 
 	if err != nil {
@@ -48,7 +61,7 @@ func NewWriteAheadLog(filepath string, segmentSize int) (*WriteAheadLog, error)
 	lastSegment := fmt.Sprintf("wal_%05d.log", maxIndex)
 	lastSegmentPath := fp.Join(filepath, lastSegment)
 
-	file := new(os.File)
+	var file *os.File
 	bytesRemaining := segmentSize
 
 	// if the last segment is empty, we don't need to create a new one
@@ -68,6 +81,13 @@ func NewWriteAheadLog(filepath string, segmentSize int) (*WriteAheadLog, error)
 			return nil, err
 		}
 		bytesRemaining = segmentSize - int(stat.Size())
+	} else {
+		// the last segment is full, the entries continue in the next one
+		maxIndex++
+		file, err = os.Create(fp.Join(filepath, fmt.Sprintf("wal_%05d.log", maxIndex)))
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &WriteAheadLog{
@@ -160,26 +180,135 @@ func (wal *WriteAheadLog) dump() error {
 	return nil
 }
 
-func (wal *WriteAheadLog) isRdyToDump() bool {
-	return len(wal.Buffer) >= wal.SegmentSize
-}
-
 // use this method when adding a new entry to the WAL
 func (wal *WriteAheadLog) Log(key, value []byte, operation int) error {
+	return wal.LogWithExpiry(key, value, operation, 0)
+}
+
+// LogWithExpiry adds an entry which expires at the given unix time in nanoseconds
+func (wal *WriteAheadLog) LogWithExpiry(key, value []byte, operation int, expiry int64) error {
+	return wal.LogAt(key, value, operation, time.Now(), expiry)
+}
+
+// LogAt adds an entry with an explicit timestamp, used when an existing entry is written again
+func (wal *WriteAheadLog) LogAt(key, value []byte, operation int, timestamp time.Time, expiry int64) error {
 	entry, err := NewEntry(key, value, operation)
 	if err != nil {
 		return err
 	}
+	entry.Timestamp = timestamp
+	entry.Expiry = expiry
 
-	wal.Buffer = append(wal.Buffer, entry.Serialize()...)
+	return wal.LogBatch([]*WriteAheadLogEntry{entry})
+}
+
+// LogBatch adds the entries with a single write, used when a write spans several records
+func (wal *WriteAheadLog) LogBatch(entries []*WriteAheadLogEntry) error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
 
-	if wal.isRdyToDump() {
-		err = wal.dump()
+	return wal.logBatch(entries)
+}
+
+// LogOrdered adds the entries with a single write and then calls apply with the timestamp they were logged with.
+// Every batch is stamped later than the batch logged before it and the batches are applied in the order they were logged,
+// so writers logging concurrently reach the memtables in the same order as the log.
+// The next batch is already logged while apply runs.
+func (wal *WriteAheadLog) LogOrdered(entries []*WriteAheadLogEntry, apply func(timestamp int64) error) error {
+	wal.mu.Lock()
+	timestamp := max(time.Now().UnixNano(), wal.last+1)
+	wal.last = timestamp
+	for _, entry := range entries {
+		entry.Timestamp = time.Unix(0, timestamp)
 	}
+	err := wal.logBatch(entries)
+
+	// the next batch can't be applied before this one, since it is logged after the apply lock is taken
+	wal.applyMu.Lock()
+	wal.mu.Unlock()
+	defer wal.applyMu.Unlock()
 
-	return err
+	if err != nil {
+		return err
+	}
+	return apply(timestamp)
+}
+
+func (wal *WriteAheadLog) logBatch(entries []*WriteAheadLogEntry) error {
+	for _, entry := range entries {
+		wal.Buffer = append(wal.Buffer, entry.Serialize()...)
+	}
+	// the entries are handed to the OS right away, so they survive a crash of the process
+	return wal.dump()
+}
+
+// Sync writes the buffered entries to the current segment and flushes it to the disk
+func (wal *WriteAheadLog) Sync() error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	return wal.sync()
+}
+
+func (wal *WriteAheadLog) sync() error {
+	if err := wal.dump(); err != nil {
+		return err
+	}
+	return wal.CurrentFile.Sync()
+}
+
+// Close syncs the log and closes the current segment
+func (wal *WriteAheadLog) Close() error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
+	if err := wal.sync(); err != nil {
+		return err
+	}
+	return wal.CurrentFile.Close()
+}
+
+// MarkCleanShutdown records that every logged entry was flushed to the sstables,
+// so the log doesn't have to be replayed on the next start
+func (wal *WriteAheadLog) MarkCleanShutdown() error {
+	file, err := os.Create(filepath.Join(wal.Path, CLEAN_SHUTDOWN_FILE))
+	if err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// DiscardIfClean removes the segments of a log which was shut down cleanly, together with the marker.
+// It reports whether the log was shut down cleanly.
+func DiscardIfClean(path string) (bool, error) {
+	marker := filepath.Join(path, CLEAN_SHUTDOWN_FILE)
+	if _, err := os.Stat(marker); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	segments, err := filepath.Glob(filepath.Join(path, "wal_*.log"))
+	if err != nil {
+		return false, err
+	}
+	// the marker goes last, so a crash in between discards the remaining segments on the next start
+	for _, segment := range segments {
+		if err := os.Remove(segment); err != nil {
+			return false, err
+		}
+	}
+	return true, os.Remove(marker)
 }
 
 func (wal *WriteAheadLog) DumpTest() error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+
 	return wal.dump()
 }
@@ -57,17 +57,22 @@ func main() {
 		panic(err)
 	}
 
-	cli.ClearConsole()
-	fmt.Println("do you want to restore data from the log? (Y/n): ")
-	var choice byte
-	fmt.Scanln(&choice)
-	fmt.Scanln(choice)
-	if choice != 'n' {
-		engine.Restore(*config)
-		fmt.Println("Data restored")
-		fmt.Scanln()
+	// after a clean shutdown everything is already in the sstables
+	if !engine.CleanShutdown() {
+		cli.ClearConsole()
+		fmt.Println("do you want to restore data from the log? (Y/n): ")
+		var choice byte
+		fmt.Scanln(&choice)
+		fmt.Scanln(choice)
+		if choice != 'n' {
+			if err := engine.Restore(*config); err != nil {
+				panic(err)
+			}
+			fmt.Println("Data restored")
+			fmt.Scanln()
+		}
+		DeleteAllFiles(walDir)
 	}
-	DeleteAllFiles(walDir)
 	cli.ClearConsole()
 	fmt.Println("Filling DB with test data...")
 	engine.FillEngine(500)
@@ -119,6 +124,10 @@ func main() {
 			fmt.Println("DB filled with test data")
 			fmt.Scanln()
 		case 6:
+			if err := engine.Close(); err != nil {
+				fmt.Println("Error while shutting down:", err)
+				fmt.Scanln()
+			}
 			return
 		}
 	}
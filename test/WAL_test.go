@@ -3,6 +3,7 @@ package test
 import (
 	tb "NoSQLDB/lib/write-ahead-log"
 	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
@@ -183,3 +184,138 @@ func TestWALReaderLargeEntry(t *testing.T) {
 		t.Errorf("expected value length %d, got %d", len(value), len(entry.Value))
 	}
 }
+
+func TestWALReaderExpiry(t *testing.T) {
+	wal, teardown := setupWAL(t)
+	defer teardown()
+
+	expiry := int64(1234567890)
+	if err := wal.LogWithExpiry([]byte("session"), []byte("token"), 0, expiry); err != nil {
+		t.Fatalf("failed to log entry: %v", err)
+	}
+	if err := wal.DumpTest(); err != nil {
+		t.Fatalf("failed to dump WAL: %v", err)
+	}
+
+	reader, err := wal.NewWALReader()
+	if err != nil {
+		t.Fatalf("failed to create WAL reader: %v", err)
+	}
+
+	entries, err := reader.Recover()
+	if err != nil {
+		t.Fatalf("failed to recover entries from WAL: %v", err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	if entries[0].Expiry != expiry {
+		t.Errorf("expected expiry %d, got %d", expiry, entries[0].Expiry)
+	}
+}
+
+func TestWALReaderDeletesAcrossSegments(t *testing.T) {
+	wal, teardown := setupWAL(t)
+	defer teardown()
+
+	// enough entries to span several segments, with deletes having no value in between
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		if err := wal.Log(key, []byte("value"), tb.WAL_PUT); err != nil {
+			t.Fatalf("failed to log entry: %v", err)
+		}
+		if i%3 == 0 {
+			if err := wal.Log(key, nil, tb.WAL_DELETE); err != nil {
+				t.Fatalf("failed to log delete: %v", err)
+			}
+		}
+	}
+
+	reader, err := wal.NewWALReader()
+	if err != nil {
+		t.Fatalf("failed to create WAL reader: %v", err)
+	}
+	entries, err := reader.Recover()
+	if err != nil {
+		t.Fatalf("failed to recover entries from WAL: %v", err)
+	}
+
+	if len(entries) != 134 {
+		t.Fatalf("expected 134 entries, got %d", len(entries))
+	}
+	deletes := 0
+	for _, entry := range entries {
+		if entry.Tombstone {
+			deletes++
+		}
+	}
+	if deletes != 34 {
+		t.Errorf("expected 34 deletes, got %d", deletes)
+	}
+	if last := entries[len(entries)-1]; string(last.Key) != "key099" || !last.Tombstone {
+		t.Errorf("expected the delete of key099 last, got %s", last.Key)
+	}
+}
+
+func TestWALReopenFullSegment(t *testing.T) {
+	wal, teardown := setupWAL(t)
+	defer teardown()
+
+	// exactly fills the first segment
+	value := bytes.Repeat([]byte("v"), testSegmentSize-tb.HEADER_SIZE-len("key1"))
+	if err := wal.Log([]byte("key1"), value, tb.WAL_PUT); err != nil {
+		t.Fatalf("failed to log entry: %v", err)
+	}
+	if err := wal.Close(); err != nil {
+		t.Fatalf("failed to close WAL: %v", err)
+	}
+
+	wal, err := tb.NewWriteAheadLog(testFilePath, testSegmentSize)
+	if err != nil {
+		t.Fatalf("failed to reopen WAL: %v", err)
+	}
+	if err := wal.Log([]byte("key2"), []byte("value2"), tb.WAL_PUT); err != nil {
+		t.Fatalf("failed to log entry after reopening: %v", err)
+	}
+
+	reader, err := wal.NewWALReader()
+	if err != nil {
+		t.Fatalf("failed to create WAL reader: %v", err)
+	}
+	entries, err := reader.Recover()
+	if err != nil {
+		t.Fatalf("failed to recover entries from WAL: %v", err)
+	}
+	if len(entries) != 2 || string(entries[1].Key) != "key2" {
+		t.Fatalf("expected key1 and key2, got %d entries", len(entries))
+	}
+}
+
+func TestWALCleanShutdown(t *testing.T) {
+	wal, teardown := setupWAL(t)
+	defer teardown()
+
+	if err := wal.Log([]byte("key1"), []byte("value1"), tb.WAL_PUT); err != nil {
+		t.Fatalf("failed to log entry: %v", err)
+	}
+
+	if clean, err := tb.DiscardIfClean(testFilePath); clean || err != nil {
+		t.Fatalf("DiscardIfClean() before shutting down = %v, %v; want false", clean, err)
+	}
+
+	if err := wal.Close(); err != nil {
+		t.Fatalf("failed to close WAL: %v", err)
+	}
+	if err := wal.MarkCleanShutdown(); err != nil {
+		t.Fatalf("failed to mark clean shutdown: %v", err)
+	}
+
+	if clean, err := tb.DiscardIfClean(testFilePath); !clean || err != nil {
+		t.Fatalf("DiscardIfClean() after shutting down = %v, %v; want true", clean, err)
+	}
+	files, _ := filepath.Glob(filepath.Join(testFilePath, "*"))
+	if len(files) != 0 {
+		t.Errorf("expected the WAL folder to be empty, found %v", files)
+	}
+}